@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/agent"
+	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/ui"
+	"golang.org/x/term"
+)
+
+const minColumnsWidth = 120
+
+var (
+	compareSystemA    string
+	compareSystemB    string
+	compareModelA     string
+	compareModelB     string
+	compareJudge      bool
+	compareJudgeModel string
+	compareSeed       int
+)
+
+type compareSide struct {
+	label  string
+	system string
+	model  config.ModelAlias
+	result agent.Completion
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare [prompt...]",
+	Short: "Run a prompt with two system prompts or models and compare the answers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prompt, err := ui.GatherInput(args, ui.InputOptions{})
+		if err != nil {
+			return fmt.Errorf("input error: %w", err)
+		}
+		if strings.TrimSpace(prompt) == "" {
+			return fmt.Errorf("a prompt is required")
+		}
+
+		models := make([]config.ModelAlias, 3)
+		for i, name := range []string{compareModelA, compareModelB, compareJudgeModel} {
+			if name != "" {
+				models[i] = cfg.ResolveModel(name)
+			}
+		}
+
+		sides := []*compareSide{
+			{label: "A", model: models[0]},
+			{label: "B", model: models[1]},
+		}
+		for i, path := range []string{compareSystemA, compareSystemB} {
+			sides[i].system = cfg.SystemInstructions
+			if path == "" {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read system prompt: %w", err)
+			}
+			sides[i].system = strings.TrimSpace(string(data))
+		}
+
+		aiAgent, err := agent.New(cfg, false, nil)
+		if err != nil {
+			return fmt.Errorf("error initializing agent: %w", err)
+		}
+		defer aiAgent.Close()
+
+		var seed *int
+		if compareSeed != 0 {
+			seed = &compareSeed
+		}
+
+		ctx := context.Background()
+		ui.Print(os.Stderr, ui.ColorBlue, "Running both configurations...\n")
+		var wg sync.WaitGroup
+		for _, side := range sides {
+			wg.Add(1)
+			go func(side *compareSide) {
+				defer wg.Done()
+				side.result = aiAgent.Complete(ctx, side.system, side.model, prompt, seed)
+			}(side)
+		}
+		wg.Wait()
+
+		width, _, _ := term.GetSize(int(os.Stdout.Fd()))
+		printComparison(sides, compareSystemA, compareSystemB, width)
+
+		if !compareJudge {
+			return nil
+		}
+		if sides[0].result.Err != nil || sides[1].result.Err != nil {
+			return fmt.Errorf("cannot judge: at least one run failed")
+		}
+
+		ui.Print(os.Stderr, ui.ColorBlue, "Asking the judge model...\n")
+		verdict := aiAgent.Judge(ctx, models[2], prompt, sides[0].result.Content, sides[1].result.Content)
+		if verdict.Err != nil {
+			return fmt.Errorf("judge failed: %w", verdict.Err)
+		}
+		ui.Printf(os.Stdout, "", "\n%s=== Judge (%s) ===%s\n%s\n", ui.ColorGreen, verdict.Model, ui.ColorReset, verdict.Content)
+		ui.Printf(os.Stdout, ui.ColorBlue, "%s\n", usageLine(verdict))
+		return nil
+	},
+}
+
+func printComparison(sides []*compareSide, systemA, systemB string, width int) {
+	for i, path := range []string{systemA, systemB} {
+		name := path
+		switch {
+		case name != "":
+		case sides[i].system != "":
+			name = "configured system prompt"
+		default:
+			name = "no system prompt"
+		}
+		ui.Printf(os.Stdout, ui.ColorBlue, "%s: %s, %s\n", sides[i].label, sides[i].result.Model, name)
+	}
+
+	bodies := make([]string, len(sides))
+	for i, side := range sides {
+		if side.result.Err != nil {
+			bodies[i] = "Error: " + side.result.Err.Error()
+		} else {
+			bodies[i] = side.result.Content
+		}
+	}
+
+	if width < minColumnsWidth {
+		for i, side := range sides {
+			ui.Printf(os.Stdout, "", "\n%s=== %s ===%s\n%s\n", ui.ColorGreen, side.label, ui.ColorReset, bodies[i])
+			ui.Printf(os.Stdout, ui.ColorBlue, "%s\n", usageLine(side.result))
+		}
+		return
+	}
+
+	colWidth := (width - 3) / 2
+	left := wrapText(bodies[0], colWidth)
+	right := wrapText(bodies[1], colWidth)
+
+	ui.Print(os.Stdout, "", "\n")
+	ui.Printf(os.Stdout, "", "%s%s%s │ %s%s%s\n", ui.ColorGreen, padRight("=== A ===", colWidth), ui.ColorReset, ui.ColorGreen, "=== B ===", ui.ColorReset)
+	for i := 0; i < len(left) || i < len(right); i++ {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		ui.Printf(os.Stdout, "", "%s │ %s\n", padRight(l, colWidth), r)
+	}
+	ui.Printf(os.Stdout, "", "%s%s%s │ %s%s%s\n", ui.ColorBlue, padRight(usageLine(sides[0].result), colWidth), ui.ColorReset, ui.ColorBlue, usageLine(sides[1].result), ui.ColorReset)
+}
+
+func usageLine(c agent.Completion) string {
+	return fmt.Sprintf("%s, %d prompt + %d completion tokens", c.Elapsed.Round(10*time.Millisecond), c.Usage.PromptTokens, c.Usage.CompletionTokens)
+}
+
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for utf8.RuneCountInString(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func init() {
+	compareCmd.Flags().StringVar(&compareSystemA, "system-a", "", "File with the system prompt for run A (default: the configured instructions)")
+	compareCmd.Flags().StringVar(&compareSystemB, "system-b", "", "File with the system prompt for run B (default: the configured instructions)")
+	compareCmd.Flags().StringVar(&compareModelA, "model-a", "", "Model for run A (default: the configured model)")
+	compareCmd.Flags().StringVar(&compareModelB, "model-b", "", "Model for run B (default: the configured model)")
+	compareCmd.Flags().BoolVar(&compareJudge, "judge", false, "Ask a third model which answer better satisfies the prompt")
+	compareCmd.Flags().StringVar(&compareJudgeModel, "judge-model", "", "Model used by --judge (default: the configured model)")
+	compareCmd.Flags().IntVar(&compareSeed, "seed", 1, "Seed sent with both runs to reduce sampling noise (0 to omit)")
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var defaultedFlags = make(map[string]bool)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configEffectiveCmd = &cobra.Command{
+	Use:   "effective",
+	Short: "Print the merged configuration and where each value came from",
+	Run: func(cmd *cobra.Command, args []string) {
+		status := "not found"
+		if _, err := os.Stat(cfg.FilePath); err == nil {
+			status = "loaded"
+		}
+		ui.Printf(os.Stdout, "", "Config file: %s (%s)\n\n", cfg.FilePath, status)
+
+		printEntries(os.Stdout, cfg.Entries())
+
+		if len(cfg.Defaults) == 0 {
+			return
+		}
+
+		ui.Print(os.Stdout, "", "\nFlag defaults:\n")
+		for _, name := range sortedKeys(cfg.Defaults) {
+			flags := cfg.Defaults[name]
+			for _, flag := range sortedKeys(flags) {
+				ui.Printf(os.Stdout, "", "%-20s --%s=%v [%s]\n", name, flag, flags[flag], config.SourceFile)
+			}
+		}
+	},
+}
+
+func printEntries(w io.Writer, entries []config.Entry) {
+	for _, e := range entries {
+		value := e.Value
+		if value == "" {
+			value = "(unset)"
+		}
+		value = strings.ReplaceAll(value, "\n", "\\n")
+		if len(value) > 60 {
+			value = value[:57] + "..."
+		}
+		fmt.Fprintf(w, "%-20s %-60s [%s]\n", e.Key, value, e.Source)
+	}
+}
+
+func init() {
+	configCmd.AddCommand(configEffectiveCmd)
+}
+
+func loadConfig(cmd *cobra.Command, args []string) error {
+	loaded, err := config.LoadProfile(profileFlag)
+	if err != nil {
+		return err
+	}
+	cfg = loaded
+	cfg.Command = commandKey(cmd)
+
+	if err := validateDefaults(cfg.Defaults); err != nil {
+		return err
+	}
+	if err := applyDefaults(cmd, cfg.Defaults[commandKey(cmd)]); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("model") {
+		cfg.UseModel(modelFlag, flagSource("model"))
+	}
+	return nil
+}
+
+func commandKey(cmd *cobra.Command) string {
+	if !cmd.HasParent() {
+		return "root"
+	}
+	return strings.Join(strings.Fields(strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")), ".")
+}
+
+func findCommand(key string) *cobra.Command {
+	if key == "root" {
+		return rootCmd
+	}
+	cmd, _, err := rootCmd.Find(strings.Split(key, "."))
+	if err != nil || cmd == rootCmd {
+		return nil
+	}
+	return cmd
+}
+
+func validateDefaults(defaults map[string]map[string]interface{}) error {
+	for _, key := range sortedKeys(defaults) {
+		cmd := findCommand(key)
+		if cmd == nil {
+			return fmt.Errorf("config defaults: unknown command %q", key)
+		}
+		for _, name := range sortedKeys(defaults[key]) {
+			if cmd.Flags().Lookup(name) == nil {
+				return fmt.Errorf("config defaults: unknown flag %q for command %q", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+func applyDefaults(cmd *cobra.Command, defaults map[string]interface{}) error {
+	for _, name := range sortedKeys(defaults) {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+
+		values := []interface{}{defaults[name]}
+		if list, ok := defaults[name].([]interface{}); ok {
+			values = list
+		}
+
+		for _, v := range values {
+			if err := cmd.Flags().Set(name, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("config defaults: invalid value for --%s: %w", name, err)
+			}
+		}
+		defaultedFlags[name] = true
+	}
+	return nil
+}
+
+func flagSource(name string) config.Source {
+	if defaultedFlags[name] {
+		return config.SourceFile
+	}
+	return config.SourceFlag
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/memory"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var memoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "Manage the project memory the agent reads and appends to",
+}
+
+var memoryShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the project memory file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := memory.Path(cfg.MemoryFile)
+		content, err := memory.Read(path)
+		if err != nil {
+			return err
+		}
+		if content == "" {
+			ui.Printf(os.Stdout, "", "No project memory at %s\n", path)
+			return nil
+		}
+		ui.Printf(os.Stdout, ui.ColorBlue, "%s\n", path)
+		ui.Print(os.Stdout, "", content)
+		return nil
+	},
+}
+
+var memoryEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the project memory file in the editor",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := memory.Path(cfg.MemoryFile)
+		content, err := memory.Read(path)
+		if err != nil {
+			return err
+		}
+		chooseEditor(&cfg)
+		edited, err := ui.OpenEditor(cfg.Editor, content)
+		if err != nil {
+			return err
+		}
+		if edited == content {
+			return nil
+		}
+		if err := memory.Write(path, edited); err != nil {
+			return err
+		}
+		ui.Printf(os.Stdout, ui.ColorGreen, "Saved %s\n", path)
+		return nil
+	},
+}
+
+var memoryClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the project memory file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := memory.Path(cfg.MemoryFile)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			ui.Printf(os.Stdout, "", "No project memory at %s\n", path)
+			return nil
+		}
+		if !confirm(fmt.Sprintf("Delete %s? [Y/n] ", path)) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to delete memory file: %w", err)
+		}
+		ui.Printf(os.Stdout, ui.ColorGreen, "Deleted %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	memoryCmd.AddCommand(memoryShowCmd)
+	memoryCmd.AddCommand(memoryEditCmd)
+	memoryCmd.AddCommand(memoryClearCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/agent"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	ui.RedirectStdout(&buf)
+	defer ui.RedirectStdout(os.Stdout)
+	fn()
+	ui.FinishOutput()
+	return buf.String()
+}
+
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\ngot:\n%q\nwant:\n%q", path, got, want)
+	}
+}
+
+func TestGoldenOutput(t *testing.T) {
+	colors := []*string{&ui.ColorRed, &ui.ColorGreen, &ui.ColorBlue, &ui.ColorReset}
+	saved := make([]string, len(colors))
+	for i, c := range colors {
+		saved[i], *c = *c, ""
+	}
+	t.Cleanup(func() {
+		for i, c := range colors {
+			*c = saved[i]
+		}
+	})
+
+	sides := func() []*compareSide {
+		return []*compareSide{
+			{label: "A", system: "Be terse.", result: agent.Completion{
+				Model:   "gpt-4o",
+				Content: "Use a map keyed by path so repeated lookups stay cheap.",
+				Usage:   openai.Usage{PromptTokens: 12, CompletionTokens: 11},
+				Elapsed: 1234 * time.Millisecond,
+			}},
+			{label: "B", result: agent.Completion{
+				Model:   "gpt-4o-mini",
+				Err:     errors.New("rate limited"),
+				Elapsed: 50 * time.Millisecond,
+			}},
+		}
+	}
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"voices", listVoices},
+		{"compare_stacked", func() { printComparison(sides(), "", "b.txt", 0) }},
+		{"compare_columns", func() { printComparison(sides(), "", "b.txt", 130) }},
+		{"usage_rows", func() {
+			printUsageRow(usageRow{Key: "gpt-4o", Runs: 3, PromptTokens: 1200, CompletionTokens: 340, CostUSD: 0.0123})
+			printUsageRow(usageRow{Key: "a-very-long-model-name-that-needs-truncating", Runs: 1, PromptTokens: 10, CompletionTokens: 5, Unpriced: 1})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkGolden(t, tt.name, captureStdout(t, tt.fn))
+		})
+	}
+}
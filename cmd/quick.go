@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yuriiter/ai/pkg/config"
+)
+
+const quickPromptLimit = 280
+
+var (
+	codeHintRegex    = regexp.MustCompile("```|[{};]\\s*$|\\b(func|def|class|import|return|SELECT|const|var)\\b")
+	complexHintRegex = regexp.MustCompile(`(?i)\b(explain|why|compare|analy[sz]e|design|implement|refactor|debug|review|step[- ]by[- ]step|write|plan|prove|optimi[sz]e|architecture|trade-?offs?)\b`)
+)
+
+func classifyPrompt(prompt string) (bool, string) {
+	prompt = strings.TrimSpace(prompt)
+	switch {
+	case prompt == "":
+		return false, "no prompt to classify"
+	case len(prompt) > quickPromptLimit:
+		return false, fmt.Sprintf("prompt longer than %d characters", quickPromptLimit)
+	case strings.Count(prompt, "\n") >= 3:
+		return false, "multi-line prompt"
+	case codeHintRegex.MatchString(prompt):
+		return false, "prompt contains code"
+	case complexHintRegex.MatchString(prompt):
+		return false, fmt.Sprintf("prompt asks to %s", strings.ToLower(complexHintRegex.FindString(prompt)))
+	}
+	return true, "short prompt without code"
+}
+
+func explicitFeatures() string {
+	switch {
+	case interactiveFlag:
+		return "interactive session"
+	case agentFlag || len(mcpFlags) > 0:
+		return "tools requested"
+	case len(ragFlags) > 0 || corpusFlag != "":
+		return "RAG requested"
+	case len(globFlags) > 0 || len(attachFlags) > 0 || stdinTypeFlag != "text":
+		return "files attached"
+	case generateImageFlag != "":
+		return "image generation"
+	case loadSessionFlag != "":
+		return "session loaded"
+	}
+	return ""
+}
+
+func applyQuick(c *config.Config) error {
+	if c.QuickModel == "" {
+		return fmt.Errorf("--quick needs a model: set quick_model in %s or AI_QUICK_MODEL", c.FilePath)
+	}
+	c.UseModel(c.QuickModel, c.Source("quick_model"))
+	c.MaxTokens = c.QuickMaxTokens
+	c.RagGlobs = nil
+	c.Corpus = ""
+	agentFlag = false
+	mcpFlags = nil
+	return nil
+}
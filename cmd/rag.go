@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/agent"
+	"github.com/yuriiter/ai/pkg/memory"
+	"github.com/yuriiter/ai/pkg/rag"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var (
+	ragIndexCorpusFlag  string
+	ragWatchCorpusFlag  string
+	ragExportCorpusFlag string
+	ragExportOutFlag    string
+	ragImportCorpusFlag string
+	ragImportRootFlag   string
+	ragWatchInterval    time.Duration
+	ragWatchDebounce    time.Duration
+	ragQueryCacheFlag   string
+	ragQueryCorpusFlag  string
+	ragQueryTopFlag     int
+	ragBenchChunksFlag  int
+	ragBenchLengthFlag  int
+	ragBenchWorkersFlag int
+	ragBenchBatchFlag   int
+)
+
+type ragQueryRequest struct {
+	Query string `json:"query"`
+	TopK  int    `json:"top_k"`
+}
+
+type ragQueryResult struct {
+	Filename  string  `json:"filename"`
+	StartLine int     `json:"start_line,omitempty"`
+	EndLine   int     `json:"end_line,omitempty"`
+	Score     float64 `json:"score"`
+	Text      string  `json:"text"`
+}
+
+type ragQueryResponse struct {
+	Query   string           `json:"query"`
+	Results []ragQueryResult `json:"results"`
+	Error   string           `json:"error,omitempty"`
+}
+
+var ragCmd = &cobra.Command{
+	Use:   "rag",
+	Short: "Manage named RAG corpora",
+}
+
+var ragIndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Build or rebuild the embedding index of a named corpus",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		corpus, err := cfg.ResolveCorpus(ragIndexCorpusFlag)
+		if err != nil {
+			return err
+		}
+		cfg.Corpus = ragIndexCorpusFlag
+		cfg.Verbose = verboseFlag
+		cfg.RagGlobs = corpus.Patterns
+
+		aiAgent, err := agent.New(cfg, false, nil)
+		if err != nil {
+			return fmt.Errorf("error initializing agent: %w", err)
+		}
+		defer aiAgent.Close()
+
+		return aiAgent.IndexRAG(context.Background())
+	},
+}
+
+var ragWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Keep the index of a named corpus up to date as its files change",
+	Long: "Polls the corpus patterns for changes, waits for bursts of edits to settle, re-embeds only the changed files,\n" +
+		"and replaces the cache file atomically so other invocations always read a complete index.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		corpus, err := cfg.ResolveCorpus(ragWatchCorpusFlag)
+		if err != nil {
+			return err
+		}
+		if ragWatchInterval <= 0 || ragWatchDebounce <= 0 {
+			return fmt.Errorf("--interval and --debounce must be positive")
+		}
+		cfg.Corpus = ragWatchCorpusFlag
+		cfg.Verbose = verboseFlag
+		cfg.RagGlobs = corpus.Patterns
+
+		aiAgent, err := agent.New(cfg, false, nil)
+		if err != nil {
+			return fmt.Errorf("error initializing agent: %w", err)
+		}
+		defer aiAgent.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return aiAgent.WatchRAG(ctx, ragWatchInterval, ragWatchDebounce)
+	},
+}
+
+var ragQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Answer retrieval queries from stdin with ranked chunks as JSON lines",
+	Long: "Reads one query per line from stdin, either as plain text or as JSON like {\"query\": \"...\", \"top_k\": 5},\n" +
+		"and writes one JSON object per query to stdout. The embedding model stays loaded between queries.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cachePath := ragQueryCacheFlag
+		switch {
+		case cachePath != "" && ragQueryCorpusFlag != "":
+			return fmt.Errorf("--cache cannot be combined with --corpus")
+		case ragQueryCorpusFlag != "":
+			if _, err := cfg.ResolveCorpus(ragQueryCorpusFlag); err != nil {
+				return err
+			}
+			cachePath = rag.CorpusCachePath(ragQueryCorpusFlag)
+		case cachePath == "":
+			return fmt.Errorf("either --cache or --corpus is required")
+		}
+
+		if ragQueryTopFlag < 1 {
+			return fmt.Errorf("--top must be at least 1, got %d", ragQueryTopFlag)
+		}
+
+		rag.Output = os.Stderr
+		engine, err := rag.New()
+		if err != nil {
+			return err
+		}
+		endpoint := cfg.EmbeddingsEndpoint()
+		engine.APIKey, engine.BaseURL = endpoint.ApiKey, endpoint.BaseURL
+		if _, err := engine.LoadEmbeddings(cachePath); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		enc := json.NewEncoder(os.Stdout)
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			req := ragQueryRequest{Query: line, TopK: ragQueryTopFlag}
+			var resp ragQueryResponse
+			if strings.HasPrefix(line, "{") {
+				req.Query = ""
+				if err := json.Unmarshal([]byte(line), &req); err != nil {
+					resp.Error = fmt.Sprintf("invalid request: %v", err)
+				}
+				switch {
+				case req.TopK < 0:
+					resp.Error = fmt.Sprintf("top_k must be at least 1, got %d", req.TopK)
+				case req.TopK == 0:
+					req.TopK = ragQueryTopFlag
+				}
+			}
+			resp.Query = req.Query
+			resp.Results = []ragQueryResult{}
+
+			if resp.Error == "" {
+				results, err := engine.SearchScored(ctx, req.Query, req.TopK)
+				if err != nil {
+					resp.Error = err.Error()
+				}
+				for _, r := range results {
+					resp.Results = append(resp.Results, ragQueryResult{Filename: r.Filename, StartLine: r.StartLine, EndLine: r.EndLine, Score: r.Score, Text: r.Text})
+				}
+			}
+
+			if err := enc.Encode(resp); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	},
+}
+
+var ragCorporaCmd = &cobra.Command{
+	Use:   "corpora",
+	Short: "Inspect named RAG corpora",
+}
+
+var ragCorporaListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured corpora and whether their indexes are up to date",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(cfg.Corpora) == 0 {
+			ui.Printf(os.Stdout, "", "No corpora defined in %s\n", cfg.FilePath)
+			return
+		}
+
+		for _, name := range sortedKeys(cfg.Corpora) {
+			corpus := cfg.Corpora[name]
+			cachePath := rag.CorpusCachePath(name)
+
+			status := ui.ColorRed + "not indexed" + ui.ColorReset
+			if info, err := os.Stat(cachePath); err == nil {
+				engine := &rag.Engine{}
+				agent.ConfigureRAG(engine, cfg)
+				agent.UseCorpus(engine, cfg, name, corpus)
+				if valid, reason := engine.ValidateCache(cachePath, corpus.Patterns); valid {
+					status = fmt.Sprintf("%sfresh%s (indexed %s)", ui.ColorGreen, ui.ColorReset, info.ModTime().Format("2006-01-02 15:04"))
+				} else {
+					status = fmt.Sprintf("%sstale%s: %s", ui.ColorRed, ui.ColorReset, reason)
+				}
+			}
+
+			ui.Printf(os.Stdout, "", "%-16s %s\n", name, status)
+			ui.Printf(os.Stdout, "", "%-16s patterns: %v | chunk: %d/%d | embedder: %s\n", "", corpus.Patterns, corpus.ChunkSize, corpus.ChunkOverlap, cmp.Or(corpus.Embedder, cfg.EmbeddingProvider))
+			if len(corpus.Types) > 0 {
+				ui.Printf(os.Stdout, "", "%-16s types: %s\n", "", strings.Join(rag.NormalizeTypes(corpus.Types), ", "))
+			}
+		}
+	},
+}
+
+var ragExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Package the index of a named corpus into a portable .ragpack file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := cfg.ResolveCorpus(ragExportCorpusFlag); err != nil {
+			return err
+		}
+		cachePath := rag.CorpusCachePath(ragExportCorpusFlag)
+		if _, err := os.Stat(cachePath); err != nil {
+			return fmt.Errorf("corpus %q is not indexed; run 'ai rag index --corpus %s' first", ragExportCorpusFlag, ragExportCorpusFlag)
+		}
+
+		out := ragExportOutFlag
+		if out == "" {
+			out = ragExportCorpusFlag + ".ragpack"
+		}
+		manifest, err := rag.ExportPack(cachePath, out)
+		if err != nil {
+			return err
+		}
+		ui.Printf(os.Stdout, ui.ColorGreen, "Exported corpus %s to %s (%d chunks, %d files, model %s)\n", ragExportCorpusFlag, out, manifest.Chunks, len(manifest.Files), manifest.Model)
+		return nil
+	},
+}
+
+var ragImportCmd = &cobra.Command{
+	Use:   "import <file.ragpack>",
+	Short: "Install a .ragpack file as the index of a named corpus",
+	Long: "Checks that the pack was built with the same embedding settings as the local configuration, maps its paths onto\n" +
+		"the directory it was exported from (or the project root, or --root), and reports files that are missing or differ\n" +
+		"from the ones that were indexed.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifest, err := rag.ReadPackManifest(args[0])
+		if err != nil {
+			return err
+		}
+		name := ragImportCorpusFlag
+		if name == "" {
+			name = manifest.Corpus
+		}
+		if name == "" {
+			return fmt.Errorf("%s does not name a corpus; pass --corpus", args[0])
+		}
+		corpus, err := cfg.ResolveCorpus(name)
+		if err != nil {
+			return err
+		}
+
+		rag.Output = os.Stdout
+		engine := &rag.Engine{}
+		agent.ConfigureRAG(engine, cfg)
+		agent.UseCorpus(engine, cfg, name, corpus)
+		_, drift, err := engine.ImportPack(args[0], rag.CorpusCachePath(name), packRoot(manifest))
+		if err != nil {
+			return err
+		}
+
+		if len(drift.Missing) == 0 && len(drift.Modified) == 0 {
+			ui.Printf(os.Stdout, ui.ColorGreen, "All %d files match the indexed content.\n", len(manifest.Files))
+			return nil
+		}
+		ui.Printf(os.Stdout, ui.ColorRed, "%d missing and %d modified file(s) differ from the pack; they are re-indexed on next use:\n", len(drift.Missing), len(drift.Modified))
+		for _, f := range drift.Missing {
+			ui.Printf(os.Stdout, "", "  missing:  %s\n", f)
+		}
+		for _, f := range drift.Modified {
+			ui.Printf(os.Stdout, "", "  modified: %s\n", f)
+		}
+		return nil
+	},
+}
+
+func packRoot(manifest *rag.PackManifest) string {
+	if ragImportRootFlag != "" {
+		return ragImportRootFlag
+	}
+	if info, err := os.Stat(manifest.Root); err == nil && info.IsDir() {
+		return manifest.Root
+	}
+	return memory.ProjectRoot()
+}
+
+var ragMergeCmd = &cobra.Command{
+	Use:   "merge <out.gob> <in.gob> <in.gob>...",
+	Short: "Combine several embedding caches into one without re-embedding",
+	Long: "Writes the chunks of every input cache into a single cache that can be searched with 'ai rag query --cache'.\n" +
+		"All inputs must share the same embedding model, dimension, metric, normalization, and prefixes.",
+	Args: cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return rag.MergeCaches(args[0], args[1:])
+	},
+}
+
+var ragBenchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure embedding throughput on a synthetic workload",
+	Long: "Embeds --chunks synthetic chunks of --length characters with the configured embedding model and reports\n" +
+		"total time, chunks/sec, and memory. Use it to tune RAG_EMBED_WORKERS and embed_batch_size before a full ingest.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if ragBenchChunksFlag <= 0 || ragBenchLengthFlag <= 0 {
+			return fmt.Errorf("--chunks and --length must be positive")
+		}
+		workers := cfg.EmbedWorkers
+		if cmd.Flags().Changed("workers") {
+			workers = ragBenchWorkersFlag
+		}
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		batchSize := cfg.EmbedBatchSize
+		if cmd.Flags().Changed("batch") {
+			batchSize = ragBenchBatchFlag
+		}
+		if batchSize <= 0 {
+			return fmt.Errorf("batch size must be positive")
+		}
+
+		_, docPrefix := rag.DefaultPrefixes(cfg.EmbeddingModel)
+		if prefix, ok := cfg.EmbeddingPrefixes[cfg.EmbeddingModel]; ok {
+			docPrefix = prefix.Document
+		}
+		texts := benchTexts(ragBenchChunksFlag, ragBenchLengthFlag, docPrefix)
+
+		rag.Output = os.Stderr
+		ctx := context.Background()
+		loadStart := time.Now()
+		var embedder rag.Embedder
+		if cfg.EmbeddingProvider == rag.ProviderOpenAI {
+			endpoint := cfg.EmbeddingsEndpoint()
+			embedder = rag.NewRemoteEmbedder(endpoint.ApiKey, endpoint.BaseURL, cfg.EmbeddingModel)
+		} else {
+			local, err := rag.NewLocalEmbedder(ctx, cfg.EmbeddingModel)
+			if err != nil {
+				return err
+			}
+			local.Workers = workers
+			embedder = local
+		}
+		loadTime := time.Since(loadStart)
+
+		var before runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		start := time.Now()
+		for i := 0; i < len(texts); i += batchSize {
+			end := min(i+batchSize, len(texts))
+			if _, err := embedder.Embed(ctx, texts[i:end]); err != nil {
+				return err
+			}
+		}
+		elapsed := time.Since(start)
+
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+
+		ui.Printf(os.Stdout, "", "Model:        %s (%s)\n", cfg.EmbeddingModel, cfg.EmbeddingProvider)
+		ui.Printf(os.Stdout, "", "Workload:     %d chunks x %d chars, batch %d, %d workers\n", len(texts), ragBenchLengthFlag, batchSize, workers)
+		ui.Printf(os.Stdout, "", "Model load:   %s\n", loadTime.Round(time.Millisecond))
+		ui.Printf(os.Stdout, "", "Embedding:    %s\n", elapsed.Round(time.Millisecond))
+		ui.Printf(os.Stdout, "", "Throughput:   %s%.1f chunks/sec%s\n", ui.ColorGreen, float64(len(texts))/elapsed.Seconds(), ui.ColorReset)
+		ui.Printf(os.Stdout, "", "Memory:       %d MB in use, %d MB allocated during run\n", after.Sys>>20, (after.TotalAlloc-before.TotalAlloc)>>20)
+		return nil
+	},
+}
+
+func benchTexts(n, length int, prefix string) []string {
+	words := strings.Fields("the index stores embeddings for every chunk of each source file so that retrieval " +
+		"can rank passages by similarity to the query before the model answers with the relevant context")
+	texts := make([]string, n)
+	for i := range texts {
+		var sb strings.Builder
+		sb.WriteString(prefix)
+		for j := i; sb.Len() < len(prefix)+length; j++ {
+			sb.WriteString(words[(j*7+i)%len(words)])
+			sb.WriteByte(' ')
+		}
+		texts[i] = sb.String()[:len(prefix)+length]
+	}
+	return texts
+}
+
+func init() {
+	ragIndexCmd.Flags().StringVar(&ragIndexCorpusFlag, "corpus", "", "Name of the corpus to index")
+	ragIndexCmd.MarkFlagRequired("corpus")
+
+	ragWatchCmd.Flags().StringVar(&ragWatchCorpusFlag, "corpus", "", "Name of the corpus to watch")
+	ragWatchCmd.Flags().DurationVar(&ragWatchInterval, "interval", 2*time.Second, "How often to check the corpus files for changes")
+	ragWatchCmd.Flags().DurationVar(&ragWatchDebounce, "debounce", time.Second, "How long changes must stay quiet before re-indexing")
+	ragWatchCmd.MarkFlagRequired("corpus")
+
+	ragQueryCmd.Flags().StringVar(&ragQueryCacheFlag, "cache", "", "Path to an embedding cache file")
+	ragQueryCmd.Flags().StringVar(&ragQueryCorpusFlag, "corpus", "", "Name of an indexed corpus to query instead of --cache")
+	ragQueryCmd.Flags().IntVar(&ragQueryTopFlag, "top", 3, "Number of results per query unless the request sets top_k")
+
+	ragExportCmd.Flags().StringVar(&ragExportCorpusFlag, "corpus", "", "Name of the corpus to export")
+	ragExportCmd.Flags().StringVar(&ragExportOutFlag, "out", "", "Output file (default: <corpus>.ragpack)")
+	ragExportCmd.MarkFlagRequired("corpus")
+	ragImportCmd.Flags().StringVar(&ragImportCorpusFlag, "corpus", "", "Corpus to install the pack as (default: the corpus it was exported from)")
+	ragImportCmd.Flags().StringVar(&ragImportRootFlag, "root", "", "Directory the pack's file paths are relative to (default: the directory it was exported from if it exists here, otherwise the project root)")
+
+	ragBenchCmd.Flags().IntVar(&ragBenchChunksFlag, "chunks", 200, "Number of synthetic chunks to embed")
+	ragBenchCmd.Flags().IntVar(&ragBenchLengthFlag, "length", 800, "Length of each synthetic chunk in characters")
+	ragBenchCmd.Flags().IntVar(&ragBenchWorkersFlag, "workers", 0, "Embedding workers (default: embed_workers or the number of CPUs)")
+	ragBenchCmd.Flags().IntVar(&ragBenchBatchFlag, "batch", 0, "Chunks per embedding batch (default: embed_batch_size)")
+
+	ragCorporaCmd.AddCommand(ragCorporaListCmd)
+	ragCmd.AddCommand(ragIndexCmd)
+	ragCmd.AddCommand(ragWatchCmd)
+	ragCmd.AddCommand(ragQueryCmd)
+	ragCmd.AddCommand(ragCorporaCmd)
+	ragCmd.AddCommand(ragExportCmd)
+	ragCmd.AddCommand(ragImportCmd)
+	ragCmd.AddCommand(ragMergeCmd)
+	ragCmd.AddCommand(ragBenchCmd)
+}
@@ -3,13 +3,24 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	openai "github.com/sashabaranov/go-openai"
 	"github.com/spf13/cobra"
 	"github.com/yuriiter/ai/pkg/agent"
 	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/notify"
+	"github.com/yuriiter/ai/pkg/rag"
+	"github.com/yuriiter/ai/pkg/tokenizer"
 	"github.com/yuriiter/ai/pkg/ui"
 	"github.com/yuriiter/ai/pkg/voice"
 	"golang.org/x/term"
@@ -24,54 +35,296 @@ var (
 	temperatureFlag   float32
 	mcpFlags          []string
 	ragFlags          []string
+	ragTypesFlag      []string
+	profileFlag       string
+	modelFlag         string
 	ragTopKFlag       int
 	saveSessionFlag   string
 	loadSessionFlag   string
+	historyFlag       string
+	jsonFlag          bool
+	stdinTypeFlag     string
 	voiceFlag         bool
 	globFlags         []string
 	attachFlags       []string
 	generateImageFlag string
 	imageSizeFlag     string
+	speakFlag         bool
+	decodeBase64Flag  bool
+	noDetectBase64    bool
+	encodeBase64Flag  bool
+	toolOnlyFlag      string
+	toolChoiceFlag    string
+	waitForToolsFlag  bool
+	textToolsFlag     bool
+	extraFlags        []string
+	debugFlag         bool
+	listVoicesFlag    bool
+	noAtExpansionFlag bool
+	expandFlag        bool
+	quickFlag         string
+	nameFlag          string
+	ragHierarchical   bool
+	ragVerifyFlag     bool
+	ragSeedFlag       bool
+	dryRunFlag        bool
+	resumeLastFlag    bool
+	corpusFlag        string
+	transcriptOutFlag string
+	verboseFlag       bool
+	promptURLFlag     string
+	toolRetriesFlag   int
+	noSystemFlag      bool
+	noAutoRagFlag     bool
+	yesFlag           bool
+	yesDestructive    bool
+	summarizeToolOut  bool
+	toolImagesFlag    bool
+	budgetTokensFlag  int
+	budgetUSDFlag     float64
+	autoContinueFlag  int
+	streamIdleFlag    time.Duration
+	promptPrefixFlag  string
+	speakOutFlag      string
 )
 
+var cfg config.Config
+
 var rootCmd = &cobra.Command{
 	Use:   "ai [prompt...]",
 	Short: "A CLI AI Agent with optional MCP, RAG, and Image Generation support",
+	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg := config.Load()
+		if listVoicesFlag {
+			listVoices()
+			return
+		}
+
+		if cmd.Flags().Changed("steps") {
+			cfg.MaxSteps = stepsFlag
+			cfg.SetSource("max_steps", flagSource("steps"))
+		}
+		if cmd.Flags().Changed("temperature") {
+			cfg.Temperature = temperatureFlag
+			cfg.SetSource("temperature", flagSource("temperature"))
+		}
+		if cmd.Flags().Changed("tool-retries") {
+			cfg.ToolRetries = toolRetriesFlag
+			cfg.SetSource("tool_retries", flagSource("tool-retries"))
+		}
+		if cmd.Flags().Changed("rag-top") {
+			cfg.RagTopK = ragTopKFlag
+			cfg.SetSource("rag_top_k", flagSource("rag-top"))
+		}
+		if cmd.Flags().Changed("stream-idle-timeout") {
+			cfg.StreamIdleTimeout = streamIdleFlag
+			cfg.SetSource("stream_idle_timeout", flagSource("stream-idle-timeout"))
+		}
+		if cmd.Flags().Changed("prompt-prefix-file") {
+			cfg.PromptPrefixFile = promptPrefixFlag
+			cfg.SetSource("prompt_prefix_file", flagSource("prompt-prefix-file"))
+		}
+		if cmd.Flags().Changed("name") {
+			cfg.AssistantName = nameFlag
+			cfg.SetSource("assistant_name", flagSource("name"))
+		}
+		ui.SetAssistantName(cfg.AssistantName)
+
+		for _, name := range []string{"agent", "mcp", "rag", "corpus", "memory", "no-system"} {
+			if cmd.Flags().Changed(name) {
+				cfg.SetSource(name, flagSource(name))
+			}
+		}
 
-		cfg.MaxSteps = stepsFlag
-		cfg.RetainHistory = memoryFlag
-		cfg.Temperature = temperatureFlag
+		cfg.RetainHistory = memoryFlag || jsonFlag
 		cfg.RagGlobs = ragFlags
-		cfg.RagTopK = ragTopKFlag
+		cfg.RagTypes = rag.NormalizeTypes(ragTypesFlag)
+		cfg.RagHierarchical = ragHierarchical
+		cfg.RagVerify = ragVerifyFlag
+		cfg.RagSeed = ragSeedFlag
+		if noDetectBase64 {
+			cfg.DetectBase64 = false
+			cfg.SetSource("detect_base64", flagSource("no-detect-base64"))
+		}
+		if noAutoRagFlag {
+			cfg.AutoRag = false
+			cfg.SetSource("auto_rag", flagSource("no-auto-rag"))
+		}
+		cfg.DryRun = dryRunFlag
+		cfg.Command = "prompt"
+		if interactiveFlag {
+			cfg.Command = "interactive"
+		}
+		cfg.Session = saveSessionFlag
+		if cfg.Session == "" {
+			cfg.Session = loadSessionFlag
+		}
+		if corpusFlag != "" {
+			if len(ragFlags) > 0 {
+				ui.Print(os.Stderr, ui.ColorRed, "--corpus cannot be combined with --rag\n")
+				os.Exit(1)
+			}
+			if len(cfg.RagTypes) > 0 {
+				ui.Print(os.Stderr, ui.ColorRed, "--corpus cannot be combined with --rag-types; set types in the corpus instead\n")
+				os.Exit(1)
+			}
+			corpus, err := cfg.ResolveCorpus(corpusFlag)
+			if err != nil {
+				ui.Printf(os.Stderr, ui.ColorRed, "%v\n", err)
+				os.Exit(1)
+			}
+			cfg.Corpus = corpusFlag
+			cfg.RagGlobs = corpus.Patterns
+		}
 		cfg.ContextGlobs = globFlags
 		cfg.AttachGlobs = attachFlags
 		cfg.GenerateImage = generateImageFlag
 		cfg.ImageSize = imageSizeFlag
+		cfg.AttachAsBase64 = encodeBase64Flag
+		cfg.ToolOnly = toolOnlyFlag
+		cfg.ToolChoice = toolChoiceFlag
+		cfg.WaitForTools = waitForToolsFlag
+		cfg.TextTools = textToolsFlag
+		cfg.Debug = debugFlag
+		cfg.Verbose = verboseFlag
+		cfg.NoSystem = noSystemFlag
+		cfg.NoAtExpansion = noAtExpansionFlag
+		cfg.ExpandMacros = expandFlag
+		cfg.AssumeYes = yesFlag
+		cfg.SummarizeToolOutput = summarizeToolOut
+		cfg.ToolImages = toolImagesFlag
+		cfg.BudgetTokens = budgetTokensFlag
+		cfg.BudgetUSD = budgetUSDFlag
+		cfg.AutoContinue = autoContinueFlag
+		cfg.YesDestructive = yesDestructive
+
+		if err := applyExtraFlags(extraFlags); err != nil {
+			ui.Printf(os.Stderr, ui.ColorRed, "%v\n", err)
+			os.Exit(1)
+		}
+
+		if toolOnlyFlag != "" && toolOnlyFlag != "text" && toolOnlyFlag != "json" {
+			ui.Printf(os.Stderr, ui.ColorRed, "Invalid --tool-only format %q (expected text or json)\n", toolOnlyFlag)
+			os.Exit(1)
+		}
+		if toolChoiceFlag != "" && toolChoiceFlag != "auto" && !agentFlag {
+			ui.Print(os.Stderr, ui.ColorRed, "--tool-choice requires --agent\n")
+			os.Exit(1)
+		}
+		if stdinTypeFlag != "text" && (interactiveFlag || !ui.IsStdinPiped()) {
+			ui.Print(os.Stderr, ui.ColorRed, "--stdin-type needs data piped to stdin and cannot be combined with -i\n")
+			os.Exit(1)
+		}
+		if speakOutFlag != "" && !speakFlag {
+			ui.Print(os.Stderr, ui.ColorRed, "--speak-out requires --speak\n")
+			os.Exit(1)
+		}
+		if jsonFlag && (interactiveFlag || speakFlag || generateImageFlag != "") {
+			ui.Print(os.Stderr, ui.ColorRed, "--json cannot be combined with -i, --speak, or --generate-image\n")
+			os.Exit(1)
+		}
+		if toolOnlyFlag != "" && !agentFlag {
+			ui.Print(os.Stderr, ui.ColorRed, "--tool-only requires --agent\n")
+			os.Exit(1)
+		}
+		if cfg.RagTopK < 1 {
+			ui.Printf(os.Stderr, ui.ColorRed, "--rag-top must be at least 1, got %d\n", cfg.RagTopK)
+			os.Exit(1)
+		}
+		if jsonFlag {
+			ui.RedirectStdout(os.Stderr)
+			rag.Output = os.Stderr
+		}
+
+		if editorFlag {
+			chooseEditor(&cfg)
+		}
+		if speakFlag || voiceFlag {
+			choosePlayer(&cfg)
+		}
+
+		inputOpts := ui.InputOptions{
+			UseEditor:    editorFlag,
+			EditorCmd:    cfg.Editor,
+			DecodeBase64: decodeBase64Flag,
+			DetectBase64: cfg.DetectBase64,
+			PromptURL:    promptURLFlag,
+			BinaryStdin:  stdinTypeFlag != "text",
+		}
+
+		defer func() {
+			if exitCode == 0 && editorFile != "" {
+				os.Remove(editorFile)
+			}
+		}()
+
+		var earlyPrompt *string
+		quick := quickFlag == "on"
+		switch quickFlag {
+		case "on", "off":
+		case "auto":
+			reason := explicitFeatures()
+			if reason == "" {
+				p, err := gatherPrompt(args, inputOpts)
+				if err != nil {
+					ui.Printf(os.Stderr, "", "Input error: %v\n", err)
+					os.Exit(1)
+				}
+				earlyPrompt = &p
+				quick, reason = classifyPrompt(p)
+			}
+			route := "full"
+			if quick {
+				route = "quick"
+			}
+			ui.Printf(os.Stderr, ui.ColorBlue, "[Route: %s (%s)]\n", route, reason)
+		default:
+			ui.Printf(os.Stderr, ui.ColorRed, "Invalid --quick value %q (expected on, off, or auto)\n", quickFlag)
+			os.Exit(1)
+		}
+		if quick {
+			if err := applyQuick(&cfg); err != nil {
+				ui.Printf(os.Stderr, ui.ColorRed, "%v\n", err)
+				os.Exit(1)
+			}
+			if cfg.Verbose {
+				ui.Printf(os.Stderr, ui.ColorBlue, "[Quick mode: %s, max %d tokens, no tools or RAG]\n", cfg.Model, cfg.MaxTokens)
+			}
+		}
 
 		aiAgent, err := agent.New(cfg, agentFlag, mcpFlags)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%sError initializing agent: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+			ui.Printf(os.Stderr, ui.ColorRed, "Error initializing agent: %v\n", err)
 			os.Exit(1)
 		}
 		defer aiAgent.Close()
 
+		if cfg.Verbose {
+			printSummary(aiAgent)
+		}
+
+		notifier, err := notify.New(cfg.Notify, time.Duration(cfg.NotifyAfter)*time.Second)
+		if err != nil {
+			ui.Printf(os.Stderr, ui.ColorRed, "Warning: %v\n", err)
+		} else {
+			aiAgent.OnEvent(notifier.Handle)
+		}
+
 		ctx := context.Background()
 
 		if generateImageFlag != "" {
-			prompt, err := ui.GatherInput(args, editorFlag, cfg.Editor)
+			prompt, err := ui.GatherInput(args, inputOpts)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Input error: %v\n", err)
+				ui.Printf(os.Stderr, "", "Input error: %v\n", err)
 				os.Exit(1)
 			}
 			if strings.TrimSpace(prompt) == "" {
-				fmt.Fprintf(os.Stderr, "%sPrompt is required to generate an image.%s\n", ui.ColorRed, ui.ColorReset)
+				ui.Print(os.Stderr, ui.ColorRed, "Prompt is required to generate an image.\n")
 				os.Exit(1)
 			}
 
 			if err := aiAgent.GenerateImage(ctx, prompt, generateImageFlag); err != nil {
-				fmt.Fprintf(os.Stderr, "\n%sImage Generation Error: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+				ui.Printf(os.Stderr, ui.ColorRed, "\nImage Generation Error: %v\n", err)
 				os.Exit(1)
 			}
 			return
@@ -79,42 +332,80 @@ var rootCmd = &cobra.Command{
 
 		if len(globFlags) > 0 {
 			if err := aiAgent.LoadContextFiles(ctx, globFlags); err != nil {
-				fmt.Fprintf(os.Stderr, "%sError loading context files: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+				ui.Printf(os.Stderr, ui.ColorRed, "Error loading context files: %v\n", err)
 				os.Exit(1)
 			}
 		}
 
 		if loadSessionFlag != "" {
 			if err := aiAgent.LoadSession(loadSessionFlag); err != nil {
-				fmt.Fprintf(os.Stderr, "%sError loading session: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+				ui.Printf(os.Stderr, ui.ColorRed, "Error loading session: %v\n", err)
+				os.Exit(1)
+			}
+			ui.Printf(os.Stdout, ui.ColorGreen, "Session loaded from %s\n", loadSessionFlag)
+		}
+
+		if stdinTypeFlag != "text" {
+			data, err := ui.ReadStdin()
+			if err == nil {
+				err = aiAgent.AttachData(stdinTypeFlag, data)
+			}
+			if err != nil {
+				ui.Printf(os.Stderr, ui.ColorRed, "--stdin-type: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if historyFlag != "" {
+			if err := aiAgent.LoadHistory(historyFlag); err != nil {
+				ui.Printf(os.Stderr, ui.ColorRed, "Error loading history: %v\n", err)
 				os.Exit(1)
 			}
-			fmt.Printf("%sSession loaded from %s%s\n", ui.ColorGreen, loadSessionFlag, ui.ColorReset)
 		}
 
 		if saveSessionFlag != "" {
 			defer func() {
 				if err := aiAgent.SaveSession(saveSessionFlag); err != nil {
-					fmt.Fprintf(os.Stderr, "%sError saving session: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+					ui.Printf(os.Stderr, ui.ColorRed, "Error saving session: %v\n", err)
 				} else {
-					fmt.Printf("%sSession saved to %s%s\n", ui.ColorGreen, saveSessionFlag, ui.ColorReset)
+					ui.Printf(os.Stdout, ui.ColorGreen, "Session saved to %s\n", saveSessionFlag)
 				}
 			}()
 		}
 
-		if len(ragFlags) > 0 {
+		if len(cfg.RagGlobs) > 0 {
 			if err := aiAgent.InitializeRAG(ctx); err != nil {
-				fmt.Fprintf(os.Stderr, "%sRAG Initialization Error: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+				ui.Printf(os.Stderr, ui.ColorRed, "RAG Initialization Error: %v\n", err)
 				os.Exit(1)
 			}
+		} else {
+			aiAgent.AutoRAG()
 		}
 
-		prompt, err := ui.GatherInput(args, editorFlag, cfg.Editor)
+		var prompt string
+		if earlyPrompt != nil {
+			prompt = *earlyPrompt
+		} else {
+			prompt, err = gatherPrompt(args, inputOpts)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Input error: %v\n", err)
+			ui.Printf(os.Stderr, "", "Input error: %v\n", err)
 			os.Exit(1)
 		}
 
+		var savedPromptPath string
+		if editorFlag && !interactiveFlag {
+			prompt = checkPromptSize(prompt, inputOpts)
+			if editorFile != "" {
+				os.WriteFile(editorFile, []byte(prompt), 0600)
+			}
+			if strings.TrimSpace(prompt) != "" {
+				if savedPromptPath, err = ui.SaveLastPrompt(prompt); err != nil {
+					ui.Printf(os.Stderr, ui.ColorRed, "Warning: failed to save prompt: %v\n", err)
+				}
+			}
+		}
+
 		if interactiveFlag {
 			if voiceFlag {
 				startVoiceInteractive(ctx, aiAgent, prompt)
@@ -129,13 +420,255 @@ var rootCmd = &cobra.Command{
 			os.Exit(0)
 		}
 
-		if err := aiAgent.RunTurn(ctx, prompt, true); err != nil {
-			fmt.Fprintf(os.Stderr, "\nAPI Error: %v\n", err)
-			os.Exit(1)
+		if speakFlag {
+			response, err := aiAgent.RunTurnCapture(ctx, prompt)
+			writeTranscript(aiAgent, transcriptOutFlag)
+			if err != nil {
+				exitCode = reportTurnError(err, savedPromptPath)
+				return
+			}
+			speakResponse(ctx, cfg, response)
+			return
+		}
+
+		if jsonFlag {
+			if exitCode = runJSONTurn(ctx, aiAgent, prompt); exitCode != 0 {
+				reportSavedPrompt("")
+			}
+			return
+		}
+
+		turnCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		err = aiAgent.RunTurn(turnCtx, prompt, true)
+		stop()
+		ui.FinishOutput()
+		writeTranscript(aiAgent, transcriptOutFlag)
+		if err != nil {
+			exitCode = reportTurnError(err, savedPromptPath)
 		}
 	},
 }
 
+const (
+	exitEmptyResponse = 3
+	exitInterrupted   = 130
+)
+
+var (
+	exitCode   int
+	argsParsed bool
+	editorFile string
+)
+
+func gatherPrompt(args []string, opts ui.InputOptions) (string, error) {
+	if resumeLastFlag {
+		return ui.LoadLastPrompt()
+	}
+	if !opts.UseEditor {
+		return ui.GatherInput(args, opts)
+	}
+
+	opts.UseEditor = false
+	initial, err := ui.GatherInput(args, opts)
+	if err != nil {
+		return "", err
+	}
+	prompt, path, err := ui.EditFile(opts.EditorCmd, initial)
+	editorFile = path
+	return prompt, err
+}
+
+func printSummary(aiAgent *agent.Agent) {
+	entries := aiAgent.Summary()
+	if loadSessionFlag != "" {
+		entries = append(entries, config.Entry{Key: "load_session", Value: loadSessionFlag, Source: flagSource("load-session")})
+	}
+	if saveSessionFlag != "" {
+		entries = append(entries, config.Entry{Key: "save_session", Value: saveSessionFlag, Source: flagSource("save-session")})
+	}
+
+	ui.Printf(os.Stderr, ui.ColorBlue, "Active configuration (%s):\n", cfg.FilePath)
+	printEntries(os.Stderr, entries)
+	ui.Print(os.Stderr, "", "\n")
+}
+
+func turnExitCode(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.Is(err, agent.ErrEmptyResponse):
+		return exitEmptyResponse
+	}
+	return 1
+}
+
+func reportTurnError(err error, savedPromptPath string) int {
+	ui.FinishOutput()
+	switch {
+	case errors.Is(err, context.Canceled):
+		ui.Print(os.Stderr, ui.ColorRed, "(interrupted)\n")
+	case errors.Is(err, agent.ErrEmptyResponse):
+		ui.Print(os.Stderr, ui.ColorRed, "(no content returned)\n")
+	case errors.Is(err, agent.ErrBudgetExceeded):
+		ui.Printf(os.Stderr, ui.ColorRed, "\nBudget exceeded: %s\n", strings.TrimPrefix(err.Error(), agent.ErrBudgetExceeded.Error()+": "))
+	default:
+		ui.Printf(os.Stderr, "", "\nAPI Error: %v\n", err)
+	}
+	reportSavedPrompt(savedPromptPath)
+	return turnExitCode(err)
+}
+
+type jsonTurn struct {
+	Response string                         `json:"response"`
+	Empty    bool                           `json:"empty,omitempty"`
+	Error    string                         `json:"error,omitempty"`
+	History  []openai.ChatCompletionMessage `json:"history"`
+}
+
+func runJSONTurn(ctx context.Context, ai *agent.Agent, prompt string) int {
+	response, err := ai.RunTurnCapture(ctx, prompt)
+	ui.FinishOutput()
+	writeTranscript(ai, transcriptOutFlag)
+	return writeJSONTurn(os.Stdout, response, err, ai.History())
+}
+
+func writeJSONTurn(w io.Writer, response string, err error, history []openai.ChatCompletionMessage) int {
+	result := jsonTurn{Response: strings.TrimSuffix(response, "\n"), History: history}
+	if err != nil {
+		result.Error = err.Error()
+		result.Empty = errors.Is(err, agent.ErrEmptyResponse)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(result); encErr != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Error writing JSON: %v\n", encErr)
+		return 1
+	}
+	if err != nil {
+		return turnExitCode(err)
+	}
+	return 0
+}
+
+func listVoices() {
+	ui.Print(os.Stdout, ui.ColorBlue, "OpenAI voices:\n")
+	for _, v := range voice.Voices {
+		if v == voice.DefaultVoice {
+			ui.Printf(os.Stdout, "", "  %s %s(default)%s\n", v, ui.ColorGreen, ui.ColorReset)
+			continue
+		}
+		ui.Printf(os.Stdout, "", "  %s\n", v)
+	}
+}
+
+func applyExtraFlags(extras []string) error {
+	for _, kv := range extras {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("invalid --extra %q (expected key=value)", kv)
+		}
+		if cfg.ExtraBody == nil {
+			cfg.ExtraBody = make(map[string]json.RawMessage)
+		}
+		cfg.ExtraBody[strings.TrimSpace(key)] = agent.ParseExtraValue(value)
+		cfg.SetSource("extra_body", config.SourceFlag)
+	}
+	return nil
+}
+
+func newVoiceManager(cfg config.Config) (*voice.Manager, error) {
+	endpoint := cfg.VoiceEndpoint()
+	if err := endpoint.Validate("voice"); err != nil {
+		return nil, err
+	}
+	return voice.NewManager(endpoint.ApiKey, endpoint.BaseURL, endpoint.Model)
+}
+
+func speakResponse(ctx context.Context, cfg config.Config, response string) {
+	text := voice.SpeakableText(response)
+	if text == "" {
+		return
+	}
+
+	vm, err := newVoiceManager(cfg)
+	if err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Warning: speech unavailable: %v\n", err)
+		return
+	}
+	defer vm.Close()
+	vm.Player = cfg.Player
+	vm.Out = speakOutFlag
+	vm.Progress = func(segment, total int) {
+		if total > 1 {
+			ui.SetProgress(fmt.Sprintf("Synthesizing speech, part %d of %d", segment, total))
+		}
+	}
+	defer ui.ClearProgress()
+
+	if err := vm.Speak(ctx, text); err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Warning: failed to speak response: %v\n", err)
+	} else if speakOutFlag != "" {
+		ui.Printf(os.Stderr, ui.ColorGreen, "Narration saved to %s\n", speakOutFlag)
+	}
+}
+
+func checkPromptSize(prompt string, opts ui.InputOptions) string {
+	for cfg.ContextWindow > 0 && len(prompt) > cfg.ContextWindow {
+		t := tokenizer.ForModel(cfg.Model)
+		tokens := t.Count(prompt)
+		if tokens <= cfg.ContextWindow {
+			break
+		}
+		ui.Printf(os.Stderr, ui.ColorRed, "Warning: the prompt is %s tokens, above the %d token context window.\n", tokenizer.Format(t, tokens), cfg.ContextWindow)
+		if !confirm("Reopen the editor to shorten it? [Y/n] ") {
+			break
+		}
+		edited, err := ui.OpenEditor(opts.EditorCmd, prompt)
+		if err != nil {
+			ui.Printf(os.Stderr, ui.ColorRed, "%v\n", err)
+			break
+		}
+		prompt = edited
+	}
+	return prompt
+}
+
+func confirm(question string) bool {
+	input, err := getInteractiveInput()
+	if err != nil {
+		return false
+	}
+	if input != os.Stdin {
+		defer input.Close()
+	}
+
+	ui.Print(os.Stderr, "", question)
+	answer, _ := bufio.NewReader(input).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+func writeTranscript(ai *agent.Agent, filename string) {
+	if filename == "" {
+		return
+	}
+	if err := ai.ExportTranscript(filename); err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Error exporting transcript: %v\n", err)
+		return
+	}
+	ui.Printf(os.Stdout, ui.ColorGreen, "Transcript exported to %s\n", filename)
+}
+
+func reportSavedPrompt(path string) {
+	if editorFile != "" {
+		ui.Printf(os.Stderr, ui.ColorBlue, "The edited prompt is kept in %s\n", editorFile)
+	}
+	if path == "" {
+		return
+	}
+	ui.Printf(os.Stderr, ui.ColorBlue, "Your prompt was saved to %s. Retry it with: ai --resume-last\n", path)
+}
+
 func getInteractiveInput() (*os.File, error) {
 	if ui.IsStdinPiped() {
 		f, err := os.Open("/dev/tty")
@@ -148,11 +681,11 @@ func getInteractiveInput() (*os.File, error) {
 }
 
 func startInteractive(ctx context.Context, ai *agent.Agent, initialCtx string) {
-	fmt.Println("Interactive Mode. Type 'exit' to quit.")
+	ui.Print(os.Stdout, "", "Interactive Mode. Type 'exit' to quit.\n")
 
 	inputFile, err := getInteractiveInput()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		ui.Printf(os.Stderr, "", "%v\n", err)
 		return
 	}
 	defer func() {
@@ -163,13 +696,13 @@ func startInteractive(ctx context.Context, ai *agent.Agent, initialCtx string) {
 
 	if memoryFlag && strings.TrimSpace(initialCtx) != "" {
 		ai.AddContext(initialCtx)
-		fmt.Printf("%s[Loaded initial context into memory]%s\n", ui.ColorGreen, ui.ColorReset)
+		ui.Print(os.Stdout, ui.ColorGreen, "[Loaded initial context into memory]\n")
 		initialCtx = ""
 	}
 
 	scanner := bufio.NewScanner(inputFile)
 	for {
-		fmt.Printf("\n%s>> %s", ui.ColorBlue, ui.ColorReset)
+		ui.Print(os.Stdout, ui.ColorBlue, "\n>> ")
 		if !scanner.Scan() {
 			break
 		}
@@ -177,6 +710,29 @@ func startInteractive(ctx context.Context, ai *agent.Agent, initialCtx string) {
 		if text == "exit" || text == "quit" {
 			break
 		}
+		if strings.HasPrefix(text, "/export") {
+			filename := strings.TrimSpace(strings.TrimPrefix(text, "/export"))
+			if filename == "" {
+				ui.Print(os.Stdout, ui.ColorRed, "Usage: /export <file.md>\n")
+				continue
+			}
+			writeTranscript(ai, filename)
+			continue
+		}
+		if fields := strings.Fields(text); len(fields) > 0 && fields[0] == "/model" {
+			if len(fields) == 1 {
+				ui.Printf(os.Stdout, ui.ColorBlue, "[Model: %s]\n", ai.Model())
+			} else {
+				ai.SetModel(fields[1])
+				ui.Printf(os.Stdout, ui.ColorGreen, "[Switched to %s]\n", ai.Model())
+			}
+			continue
+		}
+		if strings.TrimSpace(text) == "/tokens" {
+			tokens, t := ai.ContextTokens()
+			ui.Printf(os.Stdout, ui.ColorBlue, "[Context: %s of %d tokens (%s)]\n", tokenizer.Format(t, tokens), cfg.ContextWindow, t.Name())
+			continue
+		}
 
 		finalPrompt := text
 
@@ -184,27 +740,71 @@ func startInteractive(ctx context.Context, ai *agent.Agent, initialCtx string) {
 			finalPrompt = fmt.Sprintf("CONTEXT:\n%s\n\nUSER QUERY:\n%s", initialCtx, text)
 		}
 
-		if err := ai.RunTurn(ctx, finalPrompt, true); err != nil {
-			fmt.Printf("Error: %v\n", err)
+		var response string
+		terminated, err := interruptible(ctx, func(turnCtx context.Context) (err error) {
+			if speakFlag {
+				response, err = ai.RunTurnCapture(turnCtx, finalPrompt)
+				return err
+			}
+			return ai.RunTurn(turnCtx, finalPrompt, true)
+		})
+		ui.FinishOutput()
+		switch {
+		case errors.Is(err, context.Canceled):
+			ui.Print(os.Stderr, ui.ColorRed, "(interrupted)\n")
+			if terminated {
+				exitCode = exitInterrupted
+				return
+			}
+		case errors.Is(err, agent.ErrEmptyResponse):
+			ui.Print(os.Stderr, ui.ColorRed, "(no content returned)\n")
+		case err != nil:
+			ui.Printf(os.Stdout, "", "Error: %v\n", err)
+		case speakFlag:
+			speakResponse(ctx, cfg, response)
 		}
 	}
 }
 
+// interruptible runs one interactive turn so that Ctrl+C cancels only that
+// turn. It reports whether the turn was stopped by SIGTERM instead, in which
+// case the session should end.
+func interruptible(ctx context.Context, run func(context.Context) error) (bool, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	turnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var terminated atomic.Bool
+	go func() {
+		select {
+		case sig := <-signals:
+			terminated.Store(sig == syscall.SIGTERM)
+			cancel()
+		case <-turnCtx.Done():
+		}
+	}()
+	err := run(turnCtx)
+	return terminated.Load(), err
+}
+
 func startVoiceInteractive(ctx context.Context, ai *agent.Agent, initialCtx string) {
-	fmt.Println("Voice Mode Enabled.")
-	fmt.Println("Press SPACE to start recording. Press SPACE again to stop and send.")
-	fmt.Println("Press Ctrl+C to quit.")
+	ui.Print(os.Stdout, "", "Voice Mode Enabled.\n")
+	ui.Print(os.Stdout, "", "Press SPACE to start recording. Press SPACE again to stop and send.\n")
+	ui.Print(os.Stdout, "", "Press Ctrl+C to quit.\n")
 
-	vm, err := voice.NewManager(config.Load().ApiKey)
+	vm, err := newVoiceManager(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to init voice manager: %v\n", err)
+		ui.Printf(os.Stderr, "", "Failed to init voice manager: %v\n", err)
 		os.Exit(1)
 	}
 	defer vm.Close()
+	vm.Player = cfg.Player
 
 	inputFile, err := getInteractiveInput()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		ui.Printf(os.Stderr, "", "%v\n", err)
 		return
 	}
 	defer func() {
@@ -215,7 +815,7 @@ func startVoiceInteractive(ctx context.Context, ai *agent.Agent, initialCtx stri
 
 	oldState, err := term.MakeRaw(int(inputFile.Fd()))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to set raw terminal: %v\n", err)
+		ui.Printf(os.Stderr, "", "Failed to set raw terminal: %v\n", err)
 		os.Exit(1)
 	}
 	defer term.Restore(int(inputFile.Fd()), oldState)
@@ -228,7 +828,7 @@ func startVoiceInteractive(ctx context.Context, ai *agent.Agent, initialCtx stri
 	}
 
 	for {
-		fmt.Printf("\r\033[K[WAITING] Press SPACE to speak...")
+		ui.Print(os.Stdout, "", "\r\033[K[WAITING] Press SPACE to speak...")
 
 		for {
 			r, _, err := screenReader.ReadRune()
@@ -243,28 +843,28 @@ func startVoiceInteractive(ctx context.Context, ai *agent.Agent, initialCtx stri
 			}
 		}
 
-		fmt.Printf("\r\033[K[RECORDING] Speak now (Press SPACE to stop)...")
+		ui.Print(os.Stdout, "", "\r\033[K[RECORDING] Speak now (Press SPACE to stop)...")
 
 		audioData, err := vm.RecordUntilSpace(screenReader)
 		if err != nil {
-			fmt.Printf("\r\033[KError recording: %v\n", err)
+			ui.Printf(os.Stdout, "", "\r\033[KError recording: %v\n", err)
 			continue
 		}
 
-		fmt.Printf("\r\033[K[PROCESSING] Transcribing...")
+		ui.Print(os.Stdout, "", "\r\033[K[PROCESSING] Transcribing...")
 		text, err := vm.Transcribe(ctx, audioData)
 		if err != nil {
-			fmt.Printf("\r\033[KTranscription error: %v\n", err)
+			ui.Printf(os.Stdout, "", "\r\033[KTranscription error: %v\n", err)
 			continue
 		}
 
 		if strings.TrimSpace(text) == "" {
-			fmt.Printf("\r\033[KNo speech detected.\n")
+			ui.Print(os.Stdout, "", "\r\033[KNo speech detected.\n")
 			continue
 		}
 
 		term.Restore(int(inputFile.Fd()), oldState)
-		fmt.Printf("\r\033[K\n%sYou (Voice): %s%s\n", ui.ColorBlue, text, ui.ColorReset)
+		ui.Printf(os.Stdout, "", "\r\033[K\n%sYou (Voice): %s%s\n", ui.ColorBlue, text, ui.ColorReset)
 
 		finalPrompt := text
 		if !memoryFlag && initialCtx != "" {
@@ -275,38 +875,106 @@ func startVoiceInteractive(ctx context.Context, ai *agent.Agent, initialCtx stri
 		term.MakeRaw(int(inputFile.Fd()))
 
 		if err != nil {
-			fmt.Printf("Agent Error: %v\n", err)
+			ui.Printf(os.Stdout, "", "Agent Error: %v\n", err)
 			continue
 		}
 
-		fmt.Printf("\r\033[K[SPEAKING] Generating audio...")
+		ui.Print(os.Stdout, "", "\r\033[K[SPEAKING] Generating audio...")
 		if err := vm.Speak(ctx, response); err != nil {
-			fmt.Printf("\r\033[KError speaking: %v\n", err)
+			ui.Printf(os.Stdout, "", "\r\033[KError speaking: %v\n", err)
 		}
 	}
 }
 
-func Execute() {
+func Execute() int {
 	rootCmd.Flags().BoolVarP(&editorFlag, "editor", "e", false, "Open editor to compose prompt")
 	rootCmd.Flags().BoolVarP(&interactiveFlag, "interactive", "i", false, "Start interactive chat")
 	rootCmd.Flags().BoolVarP(&agentFlag, "agent", "a", false, "Enable agentic capabilities (tools)")
 	rootCmd.Flags().BoolVarP(&memoryFlag, "memory", "m", false, "Retain conversation history between turns")
 	rootCmd.Flags().IntVar(&stepsFlag, "steps", 10, "Maximum number of agentic steps allowed")
+	rootCmd.Flags().IntVar(&autoContinueFlag, "auto-continue", 0, "Ask for the rest of a response cut off by the token limit, up to N times")
+	rootCmd.Flags().Lookup("auto-continue").NoOptDefVal = "3"
+	rootCmd.Flags().StringVar(&nameFlag, "name", "", "Label the assistant's output and transcript entries with this name, e.g. researcher")
+	rootCmd.Flags().StringVar(&quickFlag, "quick", "off", "Answer with quick_model, without tools or RAG: on, off, or auto to route by prompt")
+	rootCmd.Flags().Lookup("quick").NoOptDefVal = "on"
+	rootCmd.Flags().IntVar(&budgetTokensFlag, "budget-tokens", 0, "Stop the agent before the next request once this many tokens are used in a turn (0 = no limit)")
+	rootCmd.Flags().Float64Var(&budgetUSDFlag, "budget-usd", 0, "Stop the agent before the next request once the turn costs this much, using model_prices from the config (0 = no limit)")
+	rootCmd.Flags().DurationVar(&streamIdleFlag, "stream-idle-timeout", 2*time.Minute, "Abort a streaming response when no data arrives for this long, keeping the partial output (0 = wait forever)")
 	rootCmd.Flags().Float32VarP(&temperatureFlag, "temperature", "t", 1.0, "Set model temperature (0.0 - 2.0)")
 	rootCmd.Flags().StringArrayVar(&mcpFlags, "mcp", []string{}, "Command to start an MCP server")
+	rootCmd.Flags().BoolVar(&noAutoRagFlag, "no-auto-rag", false, "Do not load the project's RAG cache automatically, even if auto_rag is enabled")
+	rootCmd.Flags().BoolVar(&noSystemFlag, "no-system", false, "Send the prompt without any system message")
+	rootCmd.Flags().BoolVar(&expandFlag, "expand", false, "Expand @include:<file>, @env:VAR, @date, and @cwd macros in the prompt before sending")
+	rootCmd.Flags().BoolVar(&noAtExpansionFlag, "no-at-expansion", false, "Do not inline files referenced as @path in the prompt")
 	rootCmd.Flags().StringArrayVar(&ragFlags, "rag", []string{}, "Glob patterns for RAG documents (can be used multiple times)")
+	rootCmd.Flags().StringVar(&corpusFlag, "corpus", "", "Use a named RAG corpus from the config file")
+	rootCmd.Flags().BoolVar(&ragHierarchical, "rag-hierarchical", false, "Summarize each RAG document and search summaries before chunks")
+	rootCmd.Flags().BoolVar(&ragVerifyFlag, "rag-verify", false, "Check the answer's claims against the retrieved RAG chunks and list unsupported ones")
+	rootCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Print the messages that would be sent, including rendered RAG context, without calling the API")
+	rootCmd.Flags().BoolVar(&ragSeedFlag, "seed-files", false, "Start with a stale RAG cache right away and index changed files in the background")
+	rootCmd.Flags().StringVar(&promptPrefixFlag, "prompt-prefix-file", "", "Prepend the contents of this file to every user message sent (not stored in history)")
+	rootCmd.Flags().StringVar(&promptURLFlag, "prompt-url", "", "Fetch the prompt from an http(s) URL (combined with arguments and stdin)")
+	rootCmd.Flags().StringSliceVar(&ragTypesFlag, "rag-types", nil, "Only index RAG files with these extensions, comma-separated (e.g. go,md,pdf)")
 	rootCmd.Flags().IntVar(&ragTopKFlag, "rag-top", 3, "Number of RAG context chunks to retrieve")
+	rootCmd.Flags().StringVar(&transcriptOutFlag, "transcript-out", "", "Write a readable Markdown transcript of the run to a file")
 	rootCmd.Flags().StringVar(&saveSessionFlag, "save-session", "", "Save chat history to a Markdown file")
 	rootCmd.Flags().StringVar(&loadSessionFlag, "session", "", "Load chat history from a Markdown file")
+	rootCmd.Flags().StringVar(&historyFlag, "history", "", "Start from the messages in a JSON file (an array of {role, content} objects); nothing is written back")
+	rootCmd.Flags().StringVar(&stdinTypeFlag, "stdin-type", "text", "Treat stdin as text, image, audio, or a specific image/* or audio/* MIME type and attach it to the prompt")
+	rootCmd.Flags().BoolVar(&jsonFlag, "json", false, "Print the response and the updated history as JSON on stdout; other output goes to stderr")
+	rootCmd.Flags().BoolVar(&listVoicesFlag, "list-voices", false, "List available text-to-speech voices and exit")
+	rootCmd.PersistentFlags().BoolVar(&verboseFlag, "verbose", false, "Print additional progress details")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Use the api_key, base_url, and model of a named profile from the config file")
+	rootCmd.PersistentFlags().StringVarP(&modelFlag, "model", "M", "", "Model or model alias to use for this run, overriding the config")
 	rootCmd.Flags().BoolVar(&voiceFlag, "voice", false, "Enable voice interaction (requires --interactive)")
 	rootCmd.Flags().StringArrayVar(&globFlags, "glob", []string{}, "Glob patterns to include files as context")
+	rootCmd.Flags().BoolVar(&resumeLastFlag, "resume-last", false, "Send the last prompt composed in the editor again")
+	rootCmd.Flags().BoolVar(&speakFlag, "speak", false, "Read the response aloud after it completes")
+	rootCmd.Flags().StringVar(&speakOutFlag, "speak-out", "", "Also save the spoken response as an MP3 file (requires --speak)")
+	rootCmd.Flags().BoolVar(&decodeBase64Flag, "decode-base64", false, "Decode base64-encoded stdin before sending (detected automatically when unambiguous)")
+	rootCmd.Flags().BoolVar(&noDetectBase64, "no-detect-base64", false, "Send stdin as is, even if it looks like base64-encoded text")
+	rootCmd.Flags().StringVar(&toolOnlyFlag, "tool-only", "", "Return the first successful tool result directly instead of a model answer (text or json)")
+	rootCmd.Flags().Lookup("tool-only").NoOptDefVal = "text"
+	rootCmd.Flags().StringArrayVar(&extraFlags, "extra", []string{}, "Extra top-level request field as key=value (value may be JSON)")
+	rootCmd.Flags().BoolVar(&debugFlag, "debug", false, "Print debugging details such as unknown response fields")
+	rootCmd.Flags().BoolVar(&textToolsFlag, "text-tools", false, "Describe tools in the prompt and parse tool calls from message text (for models without native tool support)")
+	rootCmd.Flags().BoolVar(&summarizeToolOut, "summarize-tool-output", false, "Summarize oversized tool outputs for the model and keep the full output on disk")
+	rootCmd.Flags().BoolVar(&toolImagesFlag, "tool-images", false, "Send images returned by tools back to the model (requires a vision-capable model)")
+	rootCmd.Flags().IntVar(&toolRetriesFlag, "tool-retries", 2, "Retries for tool calls that fail with transient errors")
+	rootCmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Run tools without asking for confirmation (destructive tools still ask)")
+	rootCmd.Flags().BoolVar(&yesDestructive, "yes-destructive", false, "Also run tools marked destructive without asking for confirmation")
+	rootCmd.Flags().BoolVar(&waitForToolsFlag, "wait-for-tools", false, "Wait for all MCP servers to connect before the first request")
+	rootCmd.Flags().StringVar(&toolChoiceFlag, "tool-choice", "auto", "Tool use policy for the first step: auto, none, required, or a tool name")
+	rootCmd.Flags().BoolVar(&encodeBase64Flag, "encode-base64", false, "Inline attached files into the prompt as base64 text instead of binary parts")
 
 	rootCmd.Flags().StringArrayVar(&attachFlags, "attach", []string{}, "Glob patterns for files to attach to the request (images, documents, etc.)")
 	rootCmd.Flags().StringVar(&generateImageFlag, "generate-image", "", "Generate an image instead of text and save it to this path")
 	rootCmd.Flags().StringVar(&imageSizeFlag, "image-size", "1:1", "Target size/aspect ratio for the generated image (e.g., 16:9, 1:1)")
 
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		argsParsed = true
+		return loadConfig(cmd, args)
+	}
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(ragCmd)
+	rootCmd.AddCommand(toolsCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(sweepCmd)
+	rootCmd.AddCommand(memoryCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(sessionsCmd)
+	rootCmd.AddCommand(usageCmd)
+
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(1)
+		ui.Printf(os.Stderr, "", "%v\n", err)
+		if !argsParsed && len(os.Args) > 2 {
+			if sub, _, findErr := rootCmd.Find(os.Args[1:2]); findErr == nil && sub != rootCmd && sub.Name() == os.Args[1] {
+				ui.Printf(os.Stderr, "", "To send a prompt that starts with %q, put -- before it: ai -- %s\n", os.Args[1], strings.Join(os.Args[1:], " "))
+			}
+		}
+		return 1
 	}
+	return exitCode
 }
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/agent"
+)
+
+func TestWriteJSONTurn(t *testing.T) {
+	history := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}
+	tests := []struct {
+		name     string
+		response string
+		err      error
+		code     int
+		want     jsonTurn
+	}{
+		{"ok", "hello\n", nil, 0, jsonTurn{Response: "hello"}},
+		{"empty", "", agent.ErrEmptyResponse, exitEmptyResponse, jsonTurn{Empty: true, Error: agent.ErrEmptyResponse.Error()}},
+		{"interrupted", "", fmt.Errorf("stream: %w", context.Canceled), exitInterrupted, jsonTurn{Error: "stream: context canceled"}},
+		{"api error", "", errors.New("boom"), 1, jsonTurn{Error: "boom"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if code := writeJSONTurn(&buf, tt.response, tt.err, history); code != tt.code {
+				t.Errorf("exit code %d, want %d", code, tt.code)
+			}
+			var got jsonTurn
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+			}
+			if got.Response != tt.want.Response || got.Empty != tt.want.Empty || got.Error != tt.want.Error {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if len(got.History) != 1 {
+				t.Errorf("history has %d messages, want 1", len(got.History))
+			}
+		})
+	}
+}
+
+func TestInterruptibleCancelsOnlyTheTurn(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not delivered to the process on Windows")
+	}
+	for _, tt := range []struct {
+		sig        os.Signal
+		terminated bool
+	}{{os.Interrupt, false}, {syscall.SIGTERM, true}} {
+		t.Run(tt.sig.String(), func(t *testing.T) {
+			ctx := context.Background()
+			terminated, err := interruptible(ctx, func(turnCtx context.Context) error {
+				self, _ := os.FindProcess(os.Getpid())
+				if err := self.Signal(tt.sig); err != nil {
+					return err
+				}
+				<-turnCtx.Done()
+				return turnCtx.Err()
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("got %v, want the turn to be canceled", err)
+			}
+			if terminated != tt.terminated {
+				t.Errorf("terminated = %v, want %v", terminated, tt.terminated)
+			}
+			if ctx.Err() != nil {
+				t.Error("the session context was canceled too")
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/agent"
+	"github.com/yuriiter/ai/pkg/memory"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+const (
+	runBufferBytes = 256 * 1024
+	runOutputBytes = 16 * 1024
+	versionTimeout = 5 * time.Second
+)
+
+const diagnoseSystemPrompt = "You diagnose failed shell commands. Using the command, its exit code, the end of its output, and the environment, " +
+	"explain the most likely cause in a few sentences, quoting the error lines that show it. Then give a concrete fix: commands to run or code to change. " +
+	"If the output is not enough to tell, say what to check next instead of guessing."
+
+const diagnoseToolsPrompt = " You can read files in the working directory with read_file; open the files and lines the errors point at before answering."
+
+var (
+	runLines int
+	runAgent bool
+)
+
+var toolchains = []struct {
+	markers []string
+	command []string
+}{
+	{[]string{"go.mod"}, []string{"go", "version"}},
+	{[]string{"package.json"}, []string{"node", "--version"}},
+	{[]string{"package.json"}, []string{"npm", "--version"}},
+	{[]string{"Cargo.toml"}, []string{"cargo", "--version"}},
+	{[]string{"pyproject.toml", "requirements.txt", "setup.py"}, []string{"python3", "--version"}},
+	{[]string{"pom.xml", "build.gradle", "build.gradle.kts"}, []string{"java", "-version"}},
+	{[]string{"Gemfile"}, []string{"ruby", "--version"}},
+	{[]string{"CMakeLists.txt"}, []string{"cmake", "--version"}},
+	{[]string{"Makefile"}, []string{"make", "--version"}},
+}
+
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > runBufferBytes {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-runBufferBytes:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) lastLines(n int) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	text := strings.TrimRight(strings.ReplaceAll(string(t.buf), "\r\n", "\n"), "\n")
+	lines := strings.Split(text, "\n")
+	cut := len(lines) > n
+	if cut {
+		lines = lines[len(lines)-n:]
+	}
+	text = strings.Join(lines, "\n")
+	if len(text) > runOutputBytes {
+		text = text[len(text)-runOutputBytes:]
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+		cut = true
+	}
+	return text, cut
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run [flags] -- command [args...]",
+	Short: "Run a command and ask the model to diagnose it if it fails",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if runLines < 1 {
+			return fmt.Errorf("--lines must be at least 1")
+		}
+
+		output := &tailBuffer{}
+		child := exec.Command(args[0], args[1:]...)
+		child.Stdin = os.Stdin
+		closeTerminal := attachTerminal(child, output)
+		if closeTerminal == nil {
+			child.Stdout = io.MultiWriter(os.Stdout, output)
+			child.Stderr = io.MultiWriter(os.Stderr, output)
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		err := child.Run()
+		signal.Stop(signals)
+		if closeTerminal != nil {
+			closeTerminal()
+		}
+
+		code := 0
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, &exitErr):
+			code = exitErr.ExitCode()
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				code = 128 + int(status.Signal())
+			} else if code < 0 {
+				code = 1
+			}
+		default:
+			code = 127
+			fmt.Fprintf(output, "%v\n", err)
+			ui.Printf(os.Stderr, ui.ColorRed, "%v\n", err)
+		}
+
+		if len(signals) == 0 {
+			diagnoseFailure(args, code, output)
+		}
+		exitCode = code
+		return nil
+	},
+}
+
+// attachTerminal connects the child's stdout and stderr to a pseudo-terminal
+// when both are terminals, so the child keeps its colours and progress output
+// while everything it prints is still captured. It returns nil when the
+// output should go through pipes instead.
+func attachTerminal(child *exec.Cmd, output *tailBuffer) func() {
+	if !ui.IsStdoutTTY() || !ui.IsStderrTTY() {
+		return nil
+	}
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return nil
+	}
+	pty.InheritSize(os.Stdout, ptmx)
+	child.Stdout, child.Stderr = tty, tty
+
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(os.Stdout, output), ptmx)
+		close(copied)
+	}()
+	return func() {
+		tty.Close()
+		<-copied
+		ptmx.Close()
+	}
+}
+
+func diagnoseFailure(args []string, code int, output *tailBuffer) {
+	ui.Printf(os.Stderr, ui.ColorBlue, "\n[Command failed with exit code %d; asking for a diagnosis]\n", code)
+
+	c := cfg
+	c.SystemInstructions = diagnoseSystemPrompt
+	if runAgent {
+		c.SystemInstructions += diagnoseToolsPrompt
+	}
+	c.RetainHistory = false
+
+	aiAgent, err := agent.New(c, runAgent, nil)
+	if err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Error initializing agent: %v\n", err)
+		return
+	}
+	defer aiAgent.Close()
+
+	cwd, _ := os.Getwd()
+	if runAgent {
+		agent.RegisterFileTools(aiAgent.Registry, cwd)
+	}
+
+	if err := aiAgent.RunTurn(context.Background(), failurePrompt(args, code, cwd, output), true); err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Error: %v\n", err)
+	}
+}
+
+func failurePrompt(args []string, code int, cwd string, output *tailBuffer) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The command `%s` failed with exit code %d.\n\n", strings.Join(args, " "), code)
+
+	sb.WriteString("Environment:\n")
+	fmt.Fprintf(&sb, "- OS: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&sb, "- Working directory: %s\n", cwd)
+	if shell := os.Getenv("SHELL"); shell != "" {
+		fmt.Fprintf(&sb, "- Shell: %s\n", shell)
+	}
+	for _, version := range toolchainVersions(cwd) {
+		fmt.Fprintf(&sb, "- %s\n", version)
+	}
+
+	text, cut := output.lastLines(runLines)
+	if strings.TrimSpace(text) == "" {
+		sb.WriteString("\nThe command printed no output.\n")
+	} else {
+		heading := "Output"
+		if cut {
+			heading = "End of the output"
+		}
+		fmt.Fprintf(&sb, "\n%s:\n```\n%s\n```\n", heading, text)
+	}
+	return agent.Redact(sb.String(), cfg.ApiKey, cfg.Chat.ApiKey, cfg.Embeddings.ApiKey, cfg.Voice.ApiKey)
+}
+
+func toolchainVersions(cwd string) []string {
+	dirs := []string{cwd}
+	if root := memory.ProjectRoot(); root != cwd {
+		dirs = append(dirs, root)
+	}
+
+	var versions []string
+	seen := make(map[string]bool)
+	for _, tc := range toolchains {
+		name := tc.command[0]
+		if seen[name] || !hasMarker(dirs, tc.markers) {
+			continue
+		}
+		seen[name] = true
+		if version := commandVersion(tc.command); version != "" {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+func hasMarker(dirs, markers []string) bool {
+	for _, dir := range dirs {
+		for _, marker := range markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func commandVersion(command []string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(bytes.TrimSpace(out)), "\n")
+	if !strings.Contains(strings.ToLower(line), strings.ToLower(command[0])) {
+		line = command[0] + " " + line
+	}
+	return strings.TrimSpace(line)
+}
+
+func init() {
+	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().IntVar(&runLines, "lines", 100, "Number of trailing output lines sent for diagnosis")
+	runCmd.Flags().BoolVarP(&runAgent, "agent", "a", false, "Let the model read files in the working directory to refine the diagnosis")
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/agent"
+	"github.com/yuriiter/ai/pkg/tokenizer"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var (
+	importFormat       string
+	importConversation string
+	importOut          string
+	importNoCompact    bool
+)
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage saved chat sessions",
+}
+
+var sessionsImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import a conversation exported from ChatGPT, Claude, or as OpenAI JSONL into a session file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if importFormat == "" {
+			return fmt.Errorf("--format is required (%s)", strings.Join(agent.ImportFormats, ", "))
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		convs, err := agent.ParseExport(data, importFormat)
+		if err != nil {
+			return err
+		}
+		conv, err := agent.SelectConversation(convs, importConversation)
+		if err != nil {
+			return err
+		}
+		if len(convs) > 1 && importConversation == "" {
+			ui.Printf(os.Stderr, ui.ColorBlue, "The export has %d conversations; importing the most recent, %q. Use --conversation to pick another.\n", len(convs), conv.Title)
+		}
+		if len(conv.Messages) == 0 {
+			return fmt.Errorf("conversation %q has no text messages to import", conv.Title)
+		}
+
+		out := importOut
+		if out == "" {
+			out = sessionFileName(conv.Title)
+			if _, err := os.Stat(out); err == nil {
+				return fmt.Errorf("%s already exists; choose another name with --out", out)
+			}
+		}
+
+		aiAgent, err := agent.New(cfg, false, nil)
+		if err != nil {
+			return fmt.Errorf("error initializing agent: %w", err)
+		}
+		defer aiAgent.Close()
+
+		messages := conv.Messages
+		compacted := 0
+		if !importNoCompact {
+			messages, compacted, err = aiAgent.CompactHistory(context.Background(), messages, cfg.ContextWindow/2)
+			if err != nil {
+				return fmt.Errorf("failed to compact the conversation (use --no-compact to import it as is): %w", err)
+			}
+		}
+
+		aiAgent.SetHistory(messages)
+		if err := aiAgent.SaveSession(out); err != nil {
+			return err
+		}
+
+		t := tokenizer.ForModel(cfg.Model)
+		ui.Printf(os.Stdout, ui.ColorGreen, "Imported %q into %s\n", conv.Title, out)
+		ui.Printf(os.Stdout, "", "  Messages imported: %d\n", len(conv.Messages))
+		ui.Printf(os.Stdout, "", "  Messages skipped:  %d\n", conv.SkippedMessages)
+		if conv.DroppedParts > 0 {
+			ui.Printf(os.Stdout, "", "  Unsupported parts dropped: %d (images, files, tool calls)\n", conv.DroppedParts)
+		}
+		if compacted > 0 {
+			ui.Printf(os.Stdout, "", "  Compacted: %d earlier messages summarized to fit half of the %d token context window\n", compacted, cfg.ContextWindow)
+		}
+		ui.Printf(os.Stdout, "", "  Tokens: %s\n", tokenizer.Format(t, tokenizer.CountTokens(t, messages)))
+		ui.Printf(os.Stdout, "", "Continue it with: ai -i --session %s\n", out)
+		return nil
+	},
+}
+
+func sessionFileName(title string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+	if slug == "" {
+		slug = "imported-session"
+	}
+	return slug + ".md"
+}
+
+func init() {
+	sessionsImportCmd.Flags().StringVar(&importFormat, "format", "", "Export format: chatgpt, claude, or openai-jsonl")
+	sessionsImportCmd.Flags().StringVar(&importConversation, "conversation", "", "Conversation to import from a multi-conversation export, by title or 1-based index (default: the most recent)")
+	sessionsImportCmd.Flags().StringVar(&importOut, "out", "", "Session file to write (default: derived from the conversation title)")
+	sessionsImportCmd.Flags().BoolVar(&importNoCompact, "no-compact", false, "Keep every message even if the conversation exceeds the context budget")
+	sessionsCmd.AddCommand(sessionsImportCmd)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/ui"
+	"github.com/yuriiter/ai/pkg/voice"
+)
+
+const pickTimeout = 15 * time.Second
+
+var editorCandidates = []string{"vim", "nano", "vi", "nvim", "emacs", "micro", "hx"}
+
+func chooseEditor(c *config.Config) {
+	if c.Source("editor") != config.SourceDefault || ui.IsStdinPiped() {
+		return
+	}
+
+	var found []string
+	defaultIdx := 0
+	for _, name := range editorCandidates {
+		if _, err := exec.LookPath(name); err == nil {
+			if name == c.Editor {
+				defaultIdx = len(found)
+			}
+			found = append(found, name)
+		}
+	}
+	if len(found) < 2 {
+		return
+	}
+
+	choice := found[ui.Pick("No editor is configured. Choose one (saved to the config file):", found, defaultIdx, pickTimeout)]
+	c.Editor = choice
+	saveChoice(c, "editor", choice)
+}
+
+func choosePlayer(c *config.Config) {
+	if c.Source("player") != config.SourceDefault || ui.IsStdinPiped() {
+		return
+	}
+
+	found := voice.PlayerCandidates()
+	if len(found) < 2 {
+		return
+	}
+
+	choice := found[ui.Pick("No audio player is configured. Choose one (saved to the config file):", found, 0, pickTimeout)]
+	c.Player = choice
+	saveChoice(c, "player", choice)
+}
+
+func saveChoice(c *config.Config, key, value string) {
+	if err := config.SaveValue(c.FilePath, key, value); err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Warning: failed to save %s: %v\n", key, err)
+		return
+	}
+	c.SetSource(key, config.SourceFile)
+	ui.Printf(os.Stderr, ui.ColorGreen, "Saved %s = %q to %s\n", key, value, c.FilePath)
+}
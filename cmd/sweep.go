@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/agent"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var (
+	sweepParam    string
+	sweepValues   []string
+	sweepSamples  int
+	sweepParallel int
+	sweepOut      string
+)
+
+type sweepRun struct {
+	value    string
+	sample   int
+	sampling agent.Sampling
+	result   agent.Completion
+}
+
+var sweepCmd = &cobra.Command{
+	Use:   "sweep [prompt...]",
+	Short: "Run a prompt once per value of temperature, top_p, or model and report the answers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sweepSamples < 1 {
+			return fmt.Errorf("--n must be at least 1")
+		}
+		if sweepParallel < 1 {
+			return fmt.Errorf("--parallel must be at least 1")
+		}
+		settings, err := sweepSettings(sweepParam, sweepValues)
+		if err != nil {
+			return err
+		}
+
+		prompt, err := ui.GatherInput(args, ui.InputOptions{})
+		if err != nil {
+			return fmt.Errorf("input error: %w", err)
+		}
+		if strings.TrimSpace(prompt) == "" {
+			return fmt.Errorf("a prompt is required")
+		}
+
+		var runs []*sweepRun
+		for i, sampling := range settings {
+			for n := 1; n <= sweepSamples; n++ {
+				runs = append(runs, &sweepRun{value: sweepValues[i], sample: n, sampling: sampling})
+			}
+		}
+
+		var out io.Writer = os.Stdout
+		if sweepOut != "" {
+			f, err := os.Create(sweepOut)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+
+		aiAgent, err := agent.New(cfg, false, nil)
+		if err != nil {
+			return fmt.Errorf("error initializing agent: %w", err)
+		}
+		defer aiAgent.Close()
+
+		ctx := context.Background()
+		ui.Printf(os.Stderr, ui.ColorBlue, "Running %d requests (%d at a time)...\n", len(runs), sweepParallel)
+		start := time.Now()
+		slots := make(chan struct{}, sweepParallel)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		done := 0
+		for _, run := range runs {
+			wg.Add(1)
+			go func(run *sweepRun) {
+				defer wg.Done()
+				slots <- struct{}{}
+				run.result = aiAgent.CompleteWith(ctx, cfg.SystemInstructions, prompt, run.sampling, nil)
+				<-slots
+
+				mu.Lock()
+				done++
+				ui.SetProgress(fmt.Sprintf("%d/%d requests done", done, len(runs)))
+				mu.Unlock()
+			}(run)
+		}
+		wg.Wait()
+		ui.ClearProgress()
+
+		writeSweepReport(out, prompt, runs, time.Since(start))
+		if sweepOut != "" {
+			ui.Printf(os.Stderr, ui.ColorGreen, "Report written to %s\n", sweepOut)
+		}
+		return nil
+	},
+}
+
+func sweepSettings(param string, values []string) ([]agent.Sampling, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("--values is required")
+	}
+
+	settings := make([]agent.Sampling, len(values))
+	for i, raw := range values {
+		switch param {
+		case "model":
+			if raw == "" {
+				return nil, fmt.Errorf("--values contains an empty model name")
+			}
+			settings[i].Model = cfg.ResolveModel(raw)
+		case "temperature", "top_p":
+			limit := 2.0
+			if param == "top_p" {
+				limit = 1
+			}
+			v, err := strconv.ParseFloat(raw, 32)
+			if err != nil || v < 0 || v > limit {
+				return nil, fmt.Errorf("invalid %s value %q (expected a number from 0 to %g)", param, raw, limit)
+			}
+			f := float32(v)
+			if param == "temperature" {
+				settings[i].Temperature = &f
+			} else {
+				settings[i].TopP = &f
+			}
+		default:
+			return nil, fmt.Errorf("unsupported --param %q (expected temperature, top_p, or model)", param)
+		}
+	}
+	return settings, nil
+}
+
+func writeSweepReport(w io.Writer, prompt string, runs []*sweepRun, elapsed time.Duration) {
+	fmt.Fprintf(w, "# Sweep: %s\n\n", sweepParam)
+	fmt.Fprintf(w, "_Model: %s | Temperature: %g | Runs: %d | Total time: %s_\n\n", cfg.Model, cfg.Temperature, len(runs), elapsed.Round(10*time.Millisecond))
+	fmt.Fprintf(w, "## Prompt\n\n%s\n", strings.TrimSpace(prompt))
+
+	var failed int
+	for _, run := range runs {
+		fmt.Fprintf(w, "\n## %s\n\n", sweepLabel(run))
+		if run.result.Err != nil {
+			failed++
+			fmt.Fprintf(w, "**Error:** %v\n", run.result.Err)
+			continue
+		}
+		fmt.Fprintf(w, "_%s_\n\n%s\n", usageLine(run.result), run.result.Content)
+	}
+
+	fmt.Fprintf(w, "\n## Summary\n\n| %s | Sample | Time | Prompt tokens | Completion tokens | Status |\n| :--- | ---: | ---: | ---: | ---: | :--- |\n", sweepParam)
+	var prompts, completions int
+	for _, run := range runs {
+		status := "ok"
+		if run.result.Err != nil {
+			status = "failed"
+		}
+		prompts += run.result.Usage.PromptTokens
+		completions += run.result.Usage.CompletionTokens
+		fmt.Fprintf(w, "| %s | %d | %s | %d | %d | %s |\n", run.value, run.sample, run.result.Elapsed.Round(10*time.Millisecond),
+			run.result.Usage.PromptTokens, run.result.Usage.CompletionTokens, status)
+	}
+	fmt.Fprintf(w, "\n%d of %d runs succeeded, %d prompt + %d completion tokens in total.\n", len(runs)-failed, len(runs), prompts, completions)
+}
+
+func sweepLabel(run *sweepRun) string {
+	label := fmt.Sprintf("%s = %s", sweepParam, run.value)
+	if sweepSamples > 1 {
+		label += fmt.Sprintf(" (sample %d of %d)", run.sample, sweepSamples)
+	}
+	return label
+}
+
+func init() {
+	sweepCmd.Flags().StringVar(&sweepParam, "param", "temperature", "Parameter to vary: temperature, top_p, or model")
+	sweepCmd.Flags().StringSliceVar(&sweepValues, "values", nil, "Comma-separated values to try, e.g. 0,0.3,0.7,1.0")
+	sweepCmd.Flags().IntVar(&sweepSamples, "n", 1, "Samples per value")
+	sweepCmd.Flags().IntVar(&sweepParallel, "parallel", 4, "Maximum number of requests in flight at once")
+	sweepCmd.Flags().StringVar(&sweepOut, "out", "", "Write the Markdown report to a file instead of stdout")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/mcp"
+	"github.com/yuriiter/ai/pkg/tools"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var toolsListMCPFlags []string
+
+var toolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "Inspect the tools provided by MCP servers",
+}
+
+var toolsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List MCP tools with the annotations their servers declare",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mcp.MaxMessageBytes = cfg.MCPMaxMessageMB << 20
+		reg := tools.NewRegistry()
+		defer reg.Close()
+
+		for _, serverCmd := range toolsListMCPFlags {
+			if err := reg.LoadMCPTools(serverCmd); err != nil {
+				return fmt.Errorf("failed to load MCP server '%s': %w", serverCmd, err)
+			}
+		}
+
+		entries := reg.List()
+		if len(entries) == 0 {
+			ui.Print(os.Stdout, "", "No tools found\n")
+			return nil
+		}
+
+		for _, t := range entries {
+			labels := t.Annotations.Labels()
+			color := ui.ColorGreen
+			switch {
+			case t.Annotations.Destructive():
+				color = ui.ColorRed
+			case len(labels) == 0:
+				labels = []string{"no annotations"}
+				color = ui.ColorBlue
+			}
+			ui.Printf(os.Stdout, "", "%s%s%s [%s]\n", color, t.Definition.Name, ui.ColorReset, strings.Join(labels, ", "))
+			if t.Annotations.Title != "" {
+				ui.Printf(os.Stdout, "", "  title: %s\n", t.Annotations.Title)
+			}
+			if desc, _, _ := strings.Cut(strings.TrimSpace(t.Definition.Description), "\n"); desc != "" {
+				ui.Printf(os.Stdout, "", "  %s\n", desc)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	toolsListCmd.Flags().StringArrayVar(&toolsListMCPFlags, "mcp", []string{}, "Command to start an MCP server (can be used multiple times)")
+	toolsListCmd.MarkFlagRequired("mcp")
+
+	toolsCmd.AddCommand(toolsListCmd)
+}
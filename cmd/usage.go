@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yuriiter/ai/pkg/agent"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var (
+	usageSince string
+	usageBy    string
+	usageJSON  bool
+)
+
+type usageRow struct {
+	Key              string  `json:"key"`
+	Runs             int     `json:"runs"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	Unpriced         int     `json:"unpriced_runs"`
+}
+
+func (r *usageRow) add(rec agent.UsageRecord) {
+	r.Runs++
+	r.PromptTokens += rec.PromptTokens
+	r.CompletionTokens += rec.CompletionTokens
+	if rec.CostUSD != nil {
+		r.CostUSD += *rec.CostUSD
+	} else {
+		r.Unpriced++
+	}
+}
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report token usage and spend recorded by earlier runs",
+}
+
+var usageReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Print token usage and estimated cost grouped by model, day, or command",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		since, err := parseSince(usageSince, now)
+		if err != nil {
+			return err
+		}
+
+		var keyOf func(agent.UsageRecord) string
+		switch usageBy {
+		case "model":
+			keyOf = func(r agent.UsageRecord) string { return r.Model }
+		case "day":
+			keyOf = func(r agent.UsageRecord) string { return r.Time.Local().Format("2006-01-02") }
+		case "command":
+			keyOf = func(r agent.UsageRecord) string { return r.Command }
+		default:
+			return fmt.Errorf("--by must be model, day, or command")
+		}
+
+		path := agent.UsageLogPath()
+		records, skipped, err := agent.LoadUsage(path, since)
+		if err != nil {
+			return err
+		}
+		if skipped > 0 {
+			ui.Printf(os.Stderr, ui.ColorRed, "Skipped %d unreadable lines in %s\n", skipped, path)
+		}
+
+		groups := make(map[string]*usageRow)
+		var total usageRow
+		total.Key = "total"
+		versions := make(map[string]bool)
+		for _, rec := range records {
+			key := keyOf(rec)
+			if key == "" {
+				key = "(unknown)"
+			}
+			if groups[key] == nil {
+				groups[key] = &usageRow{Key: key}
+			}
+			groups[key].add(rec)
+			total.add(rec)
+			if rec.PriceVersion != "" {
+				versions[rec.PriceVersion] = true
+			}
+		}
+
+		rows := make([]usageRow, 0, len(groups))
+		for _, row := range groups {
+			rows = append(rows, *row)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			if usageBy == "day" {
+				return rows[i].Key < rows[j].Key
+			}
+			if rows[i].CostUSD != rows[j].CostUSD {
+				return rows[i].CostUSD > rows[j].CostUSD
+			}
+			return rows[i].PromptTokens+rows[i].CompletionTokens > rows[j].PromptTokens+rows[j].CompletionTokens
+		})
+
+		if usageJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(map[string]interface{}{
+				"since":          since.Format(time.RFC3339),
+				"by":             usageBy,
+				"rows":           rows,
+				"total":          total,
+				"price_versions": sortedKeys(versions),
+			})
+		}
+
+		if len(records) == 0 {
+			ui.Printf(os.Stdout, "", "No usage recorded since %s (%s)\n", since.Format("2006-01-02 15:04"), path)
+			return nil
+		}
+
+		ui.Printf(os.Stdout, "", "Usage since %s, by %s\n\n", since.Format("2006-01-02 15:04"), usageBy)
+		ui.Printf(os.Stdout, "", "%-32s %6s %12s %12s %10s\n", strings.ToUpper(usageBy), "RUNS", "PROMPT", "COMPLETION", "COST")
+		for _, row := range rows {
+			printUsageRow(row)
+		}
+		ui.Print(os.Stdout, "", strings.Repeat("-", 76)+"\n")
+		printUsageRow(total)
+
+		if total.Unpriced > 0 {
+			ui.Printf(os.Stdout, ui.ColorBlue, "\n%d of the runs used models with no price in model_prices; their cost is not included.\n", total.Unpriced)
+		}
+		if len(versions) > 1 {
+			ui.Printf(os.Stdout, ui.ColorBlue, "Costs were estimated with %d different price tables; each run keeps the prices in effect when it ran.\n", len(versions))
+		}
+		return nil
+	},
+}
+
+func printUsageRow(row usageRow) {
+	key := row.Key
+	if len(key) > 32 {
+		key = key[:29] + "..."
+	}
+	cost := fmt.Sprintf("$%.4f", row.CostUSD)
+	if row.Unpriced == row.Runs {
+		cost = "-"
+	}
+	ui.Printf(os.Stdout, "", "%-32s %6d %12d %12d %10s\n", key, row.Runs, row.PromptTokens, row.CompletionTokens, cost)
+}
+
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" || value == "all" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (use a duration like 30d or 12h, a date like 2025-01-31, or all)", value)
+}
+
+func init() {
+	usageReportCmd.Flags().StringVar(&usageSince, "since", "30d", "Only include runs newer than this: a duration like 30d or 12h, a date (YYYY-MM-DD), or all")
+	usageReportCmd.Flags().StringVar(&usageBy, "by", "model", "Group by model, day, or command")
+	usageReportCmd.Flags().BoolVar(&usageJSON, "json", false, "Print the report as JSON")
+	usageCmd.AddCommand(usageReportCmd)
+}
@@ -1,7 +1,11 @@
 package main
 
-import "github.com/yuriiter/ai/cmd"
+import (
+	"os"
+
+	"github.com/yuriiter/ai/cmd"
+)
 
 func main() {
-	cmd.Execute()
+	os.Exit(cmd.Execute())
 }
@@ -13,53 +13,60 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/mcp"
+	"github.com/yuriiter/ai/pkg/memory"
 	"github.com/yuriiter/ai/pkg/rag"
+	"github.com/yuriiter/ai/pkg/tokenizer"
 	"github.com/yuriiter/ai/pkg/tools"
 	"github.com/yuriiter/ai/pkg/ui"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
+const ragTopDocs = 3
+
+var closeTimeout = 5 * time.Second
+
 type Agent struct {
-	client      *openai.Client
+	client      ChatCompleter
 	config      config.Config
 	history     []openai.ChatCompletionMessage
+	pendingData []dataAttachment
 	Registry    *tools.Registry
 	RagEngine   *rag.Engine
 	agenticMode bool
+	mcpServers  []string
+	handlers    []EventHandler
+
+	toolsReady    chan struct{}
+	toolsErr      error
+	toolsReported bool
+
+	transcript   []transcriptTurn
+	summaryUsage openai.Usage
+
+	unknownToolStreak int
+	reasoningNoted    atomic.Bool
+
+	tokenizer    tokenizer.Tokenizer
+	promptPrefix string
 }
 
 func New(cfg config.Config, agenticMode bool, mcpServers []string) (*Agent, error) {
-	clientConfig := openai.DefaultConfig(cfg.ApiKey)
-	if cfg.BaseURL != "" {
-		clientConfig.BaseURL = cfg.BaseURL
-	}
-
-	client := openai.NewClientWithConfig(clientConfig)
-	reg := tools.NewRegistry()
+	chat := cfg.ChatEndpoint()
+	cfg.ApiKey, cfg.BaseURL, cfg.Model = chat.ApiKey, chat.BaseURL, chat.Model
 
-	if agenticMode {
-		for _, serverCmd := range mcpServers {
-			if serverCmd == "" {
-				continue
-			}
-			fmt.Printf("%sConnecting to MCP: %s...%s\n", ui.ColorBlue, serverCmd, ui.ColorReset)
-			if err := reg.LoadMCPTools(serverCmd); err != nil {
-				return nil, fmt.Errorf("failed to load MCP server '%s': %w", serverCmd, err)
-			}
-		}
+	rag.Converters = cfg.Converters
+	rag.Verbose = cfg.Verbose
+	mcp.MaxMessageBytes = cfg.MCPMaxMessageMB << 20
 
-		toolsList := reg.GetOpenAITools()
-		var names []string
-		for _, t := range toolsList {
-			names = append(names, t.Function.Name)
-		}
-		if len(names) > 0 {
-			fmt.Printf("%sLoaded Tools: %s%s\n", ui.ColorGreen, strings.Join(names, ", "), ui.ColorReset)
-		}
-	}
+	client := newClient(cfg)
+	reg := tools.NewRegistry()
+	reg.Retries = cfg.ToolRetries
 
 	sysPrompt := cfg.SystemInstructions
 	if sysPrompt == "" {
@@ -77,27 +84,84 @@ func New(cfg config.Config, agenticMode bool, mcpServers []string) (*Agent, erro
 		}
 	}
 
+	if agenticMode {
+		memoryPath := memory.Path(cfg.MemoryFile)
+		registerMemoryTools(reg, memoryPath)
+		if sysPrompt != "" {
+			sysPrompt += memoryContext(memoryPath)
+		}
+	}
+
+	var promptPrefix string
+	if cfg.PromptPrefixFile != "" {
+		data, err := os.ReadFile(cfg.PromptPrefixFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt prefix: %w", err)
+		}
+		promptPrefix = strings.TrimSpace(ui.NormalizeInput(string(data)))
+	}
+
 	ragEngine, err := rag.New()
 	if err != nil {
 		return nil, fmt.Errorf("failed to init RAG engine: %w", err)
 	}
 
+	ragEngine.ExtractWorkers = cfg.ExtractWorkers
+	ConfigureRAG(ragEngine, cfg)
+
 	agent := &Agent{
 		client:      client,
 		config:      cfg,
 		history:     make([]openai.ChatCompletionMessage, 0),
 		Registry:    reg,
 		agenticMode: agenticMode,
+		mcpServers:  mcpServers,
 		RagEngine:   ragEngine,
+
+		promptPrefix: promptPrefix,
 	}
 
-	if sysPrompt != "" {
+	if sysPrompt != "" && !cfg.NoSystem {
 		agent.history = append(agent.history, openai.ChatCompletionMessage{
 			Role:    openai.ChatMessageRoleSystem,
 			Content: sysPrompt,
 		})
 	}
 
+	agent.toolsReady = make(chan struct{})
+	if !agenticMode {
+		close(agent.toolsReady)
+		return agent, nil
+	}
+
+	if !agent.mustWaitForTools() {
+		if len(mcpServers) > 0 {
+			ui.Printf(os.Stdout, ui.ColorBlue, "Connecting to %d MCP server(s) in the background...\n", len(mcpServers))
+		}
+		go func() {
+			defer close(agent.toolsReady)
+			agent.toolsErr = agent.loadTools(mcpServers, false)
+		}()
+		return agent, nil
+	}
+
+	defer close(agent.toolsReady)
+	if err := agent.loadTools(mcpServers, true); err != nil {
+		reg.Close()
+		return nil, err
+	}
+	agent.toolsReported = true
+	agent.reportTools()
+
+	switch cfg.ToolChoice {
+	case "", "auto", "none", "required":
+	default:
+		if !reg.Has(cfg.ToolChoice) {
+			reg.Close()
+			return nil, fmt.Errorf("--tool-choice: unknown tool %q (available: %s)", cfg.ToolChoice, strings.Join(reg.Names(), ", "))
+		}
+	}
+
 	return agent, nil
 }
 
@@ -117,16 +181,43 @@ func (a *Agent) getAttachmentURIs() ([]string, error) {
 			return nil, fmt.Errorf("failed to read attached file %s: %w", f, err)
 		}
 		uris = append(uris, uri)
-		fmt.Printf("%sAttached file: %s%s\n", ui.ColorBlue, f, ui.ColorReset)
+		ui.Printf(os.Stdout, ui.ColorBlue, "Attached file: %s\n", f)
 	}
 	return uris, nil
 }
 
+func (a *Agent) getInlineAttachments() (string, error) {
+	if len(a.config.AttachGlobs) == 0 {
+		return "", nil
+	}
+	files := rag.FindFiles(a.config.AttachGlobs)
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files found matching patterns: %v", a.config.AttachGlobs)
+	}
+
+	var sb strings.Builder
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read attached file %s: %w", f, err)
+		}
+		sb.WriteString(fmt.Sprintf("\n\n--- ATTACHMENT: %s (%s, base64) ---\n%s\n", filepath.Base(f), mimeTypeForPath(f), base64.StdEncoding.EncodeToString(b)))
+		ui.Printf(os.Stdout, ui.ColorBlue, "Attached file (base64): %s\n", f)
+	}
+	return sb.String(), nil
+}
+
 func fileToDataURI(path string) (string, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
 	}
+
+	b64 := base64.StdEncoding.EncodeToString(b)
+	return fmt.Sprintf("data:%s;base64,%s", mimeTypeForPath(path), b64), nil
+}
+
+func mimeTypeForPath(path string) string {
 	ext := strings.ToLower(filepath.Ext(path))
 	mime := "application/octet-stream"
 
@@ -144,9 +235,7 @@ func fileToDataURI(path string) (string, error) {
 	case ".txt", ".md":
 		mime = "text/plain"
 	}
-
-	b64 := base64.StdEncoding.EncodeToString(b)
-	return fmt.Sprintf("data:%s;base64,%s", mime, b64), nil
+	return mime
 }
 
 func (a *Agent) GenerateImage(ctx context.Context, prompt string, outputPath string) error {
@@ -155,7 +244,7 @@ func (a *Agent) GenerateImage(ctx context.Context, prompt string, outputPath str
 		return err
 	}
 
-	fmt.Printf("%sInitiating Image Generation...%s\n", ui.ColorBlue, ui.ColorReset)
+	ui.Printf(os.Stdout, ui.ColorBlue, "Initiating Image Generation...\n")
 
 	reqBody := map[string]interface{}{
 		"prompt":          prompt,
@@ -227,7 +316,7 @@ func (a *Agent) GenerateImage(ctx context.Context, prompt string, outputPath str
 		return fmt.Errorf("failed to write image to %s: %w", outputPath, err)
 	}
 
-	fmt.Printf("%sImage successfully saved to %s%s\n", ui.ColorGreen, outputPath, ui.ColorReset)
+	ui.Printf(os.Stdout, ui.ColorGreen, "Image successfully saved to %s\n", outputPath)
 	return nil
 }
 
@@ -240,7 +329,7 @@ func (a *Agent) LoadContextFiles(ctx context.Context, globs []string) error {
 		return fmt.Errorf("no files found matching globs: %v", globs)
 	}
 
-	fmt.Printf("%sLoading context from %d files...%s\n", ui.ColorBlue, len(files), ui.ColorReset)
+	ui.Printf(os.Stdout, ui.ColorBlue, "Loading context from %d files...\n", len(files))
 
 	var sb strings.Builder
 	sb.WriteString("CONTEXT FROM FILES:\n\n")
@@ -248,7 +337,7 @@ func (a *Agent) LoadContextFiles(ctx context.Context, globs []string) error {
 	for _, file := range files {
 		content, err := rag.ExtractText(file)
 		if err != nil {
-			fmt.Printf("Warning: Failed to read %s: %v\n", file, err)
+			ui.Printf(os.Stdout, "", "Warning: Failed to read %s: %v\n", file, err)
 			continue
 		}
 		if strings.TrimSpace(content) == "" {
@@ -336,7 +425,7 @@ func (a *Agent) LoadSession(filename string) error {
 			continue
 		}
 
-		if strings.HasPrefix(line, "# ") && !strings.HasPrefix(line, "## role:") {
+		if currentRole == "" && strings.HasPrefix(line, "# ") {
 			continue
 		}
 
@@ -358,70 +447,423 @@ func (a *Agent) InitializeRAG(ctx context.Context) error {
 		return nil
 	}
 
-	cachePath := rag.GetDefaultCachePath(a.config.RagGlobs)
+	cachePath, err := a.prepareRAG()
+	if err != nil {
+		return err
+	}
 
 	if a.RagEngine.CacheExists(cachePath) {
-		fmt.Printf("%sFound embedding cache, validating...%s\n", ui.ColorBlue, ui.ColorReset)
+		ui.Printf(os.Stdout, ui.ColorBlue, "Found embedding cache, validating...\n")
 
 		valid, reason := a.RagEngine.ValidateCache(cachePath, a.config.RagGlobs)
 
 		if valid {
-			fmt.Printf("%sCache is valid, loading...%s\n", ui.ColorGreen, ui.ColorReset)
+			ui.Printf(os.Stdout, ui.ColorGreen, "Cache is valid, loading...\n")
 			if _, err := a.RagEngine.LoadEmbeddings(cachePath); err != nil {
-				fmt.Printf("%sCache load failed: %v, regenerating...%s\n", ui.ColorRed, err, ui.ColorReset)
+				ui.Printf(os.Stdout, ui.ColorRed, "Cache load failed: %v, regenerating...\n", err)
+			} else if a.config.RagHierarchical && len(a.RagEngine.Summaries) == 0 {
+				return a.rebuildSummaries(ctx, cachePath)
 			} else {
 				return nil
 			}
 		} else {
-			fmt.Printf("%sCache is stale: %s%s\n", ui.ColorRed, reason, ui.ColorReset)
-			fmt.Printf("%sRegenerating embeddings...%s\n", ui.ColorBlue, ui.ColorReset)
+			ui.Printf(os.Stdout, ui.ColorRed, "Cache is stale: %s\n", reason)
+			if a.config.RagSeed && a.seedRAG(ctx, cachePath) {
+				return nil
+			}
+			var summarize rag.SummarizeFunc
+			if a.config.RagHierarchical {
+				summarize = a.summarizeDocument
+			}
+			err := a.RagEngine.UpdateFromCache(ctx, cachePath, a.config.RagGlobs, summarize)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, rag.ErrIncompatibleCache) {
+				return err
+			}
+			ui.Printf(os.Stdout, ui.ColorBlue, "Cannot update the cache (%v), regenerating embeddings...\n", err)
+			a.RagEngine.Chunks, a.RagEngine.Summaries = nil, nil
 		}
 	} else {
-		fmt.Printf("%sNo cache found, generating embeddings...%s\n", ui.ColorBlue, ui.ColorReset)
+		ui.Printf(os.Stdout, ui.ColorBlue, "No cache found, generating embeddings...\n")
+	}
+
+	return a.indexRAG(ctx, cachePath)
+}
+
+func (a *Agent) rebuildSummaries(ctx context.Context, cachePath string) error {
+	if err := a.RagEngine.BuildSummaries(ctx, a.summarizeDocument); err != nil {
+		return err
+	}
+	if err := a.RagEngine.SaveEmbeddings(cachePath, a.config.RagGlobs); err != nil {
+		ui.Printf(os.Stdout, ui.ColorRed, "Warning: Failed to save cache: %v\n", err)
+	}
+	return nil
+}
+
+func (a *Agent) AutoRAG() bool {
+	if !a.config.AutoRag || len(a.config.RagGlobs) > 0 || a.config.Corpus != "" {
+		return false
+	}
+	globs, types, cachePath, ok := rag.ProjectCache()
+	if !ok {
+		return false
+	}
+
+	a.RagEngine.Settings.Types = types
+	if valid, reason := a.RagEngine.ValidateCache(cachePath, globs); !valid {
+		a.RagEngine.Settings.Types = nil
+		ui.Printf(os.Stderr, ui.ColorBlue, "[Not using the project RAG cache (%s); run with --rag \"%s\" to refresh it]\n", reason, strings.Join(globs, `" --rag "`))
+		return false
+	}
+	if _, err := a.RagEngine.LoadEmbeddings(cachePath); err != nil {
+		a.RagEngine.Settings.Types = nil
+		ui.Printf(os.Stderr, ui.ColorRed, "[Not using the project RAG cache: %v]\n", err)
+		return false
+	}
+	a.config.RagGlobs, a.config.RagTypes = globs, types
+	return true
+}
+
+func (a *Agent) seedRAG(ctx context.Context, cachePath string) bool {
+	if a.config.RagHierarchical {
+		ui.Printf(os.Stdout, ui.ColorRed, "--seed-files is not supported with --rag-hierarchical\n")
+		return false
+	}
+
+	changed, removed, err := a.RagEngine.StaleFiles(cachePath, a.config.RagGlobs)
+	if err != nil {
+		ui.Printf(os.Stdout, ui.ColorRed, "Cannot reuse the cache: %v\n", err)
+		return false
+	}
+	if _, err := a.RagEngine.LoadEmbeddings(cachePath); err != nil {
+		ui.Printf(os.Stdout, ui.ColorRed, "Cache load failed: %v\n", err)
+		return false
+	}
+
+	ui.Printf(os.Stdout, ui.ColorBlue, "Indexing %d changed and %d removed file(s) in the background; searches use the cached embeddings until then.\n",
+		len(changed), len(removed))
+	go func() {
+		if err := a.RagEngine.Refresh(ctx, changed, removed); err != nil {
+			ui.Printf(os.Stderr, ui.ColorRed, "\n[RAG background indexing failed: %v]\n", err)
+			return
+		}
+		if err := a.RagEngine.SaveEmbeddings(cachePath, a.config.RagGlobs); err != nil {
+			ui.Printf(os.Stderr, ui.ColorRed, "\n[RAG background indexing finished, but the cache could not be saved: %v]\n", err)
+		}
+	}()
+	return true
+}
+
+func printSources(results []rag.Result) {
+	var locations []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if loc := r.Location(); !seen[loc] {
+			seen[loc] = true
+			locations = append(locations, loc)
+		}
+	}
+	ui.Printf(os.Stdout, ui.ColorBlue, "[Sources: %s]\n", strings.Join(locations, ", "))
+}
+
+func (a *Agent) renderRagContext(query string, results []rag.Result) (string, error) {
+	tmpl, err := a.config.RagTemplateFor(a.config.Corpus)
+	if err != nil {
+		return "", err
+	}
+
+	data := config.RagContext{Query: query}
+	for i, r := range results {
+		data.Chunks = append(data.Chunks, config.RagChunk{
+			Index:     i + 1,
+			Filename:  r.Filename,
+			Location:  r.Location(),
+			StartLine: r.StartLine,
+			EndLine:   r.EndLine,
+			Section:   r.Section,
+			Text:      r.Text,
+			Score:     r.Score,
+		})
+		data.TotalTokens += a.tokens().Count(r.Text)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("rag template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+func (a *Agent) IndexRAG(ctx context.Context) error {
+	cachePath, err := a.prepareRAG()
+	if err != nil {
+		return err
+	}
+	return a.indexRAG(ctx, cachePath)
+}
+
+func (a *Agent) prepareRAG() (string, error) {
+	if a.config.Corpus == "" {
+		a.RagEngine.Settings.Types = a.config.RagTypes
+		if err := rag.RememberProject(a.config.RagGlobs, a.config.RagTypes); err != nil && a.config.Verbose {
+			ui.Printf(os.Stderr, ui.ColorRed, "[Could not record the project RAG cache: %v]\n", err)
+		}
+		return rag.GetDefaultCachePath(a.config.RagGlobs, a.config.RagTypes), nil
+	}
+
+	corpus, err := a.config.ResolveCorpus(a.config.Corpus)
+	if err != nil {
+		return "", err
+	}
+	UseCorpus(a.RagEngine, a.config, a.config.Corpus, corpus)
+	return rag.CorpusCachePath(a.config.Corpus), nil
+}
+
+func ConfigureRAG(engine *rag.Engine, cfg config.Config) {
+	engine.Metric = cfg.RagMetric
+	engine.Normalize = cfg.RagNormalize
+	engine.Boilerplate = rag.BoilerplateFilter(cfg.RagBoilerplate)
+	engine.Provider = cfg.EmbeddingProvider
+	engine.Model = cfg.EmbeddingModel
+	endpoint := cfg.EmbeddingsEndpoint()
+	engine.APIKey, engine.BaseURL = endpoint.ApiKey, endpoint.BaseURL
+	engine.EmbedWorkers = cfg.EmbedWorkers
+	engine.EmbedBatchSize = cfg.EmbedBatchSize
+	setEmbeddingPrefixes(engine, cfg)
+}
+
+func setEmbeddingPrefixes(engine *rag.Engine, cfg config.Config) {
+	engine.QueryPrefix, engine.DocPrefix = rag.DefaultPrefixes(engine.Model)
+	if prefix, ok := cfg.EmbeddingPrefixes[engine.Model]; ok {
+		engine.QueryPrefix, engine.DocPrefix = prefix.Query, prefix.Document
+	}
+}
+
+func UseCorpus(engine *rag.Engine, cfg config.Config, name string, corpus config.Corpus) {
+	if corpus.Embedder == "" {
+		corpus.Embedder = cfg.EmbeddingProvider
+	}
+	engine.Settings = CorpusSettings(name, corpus)
+	if corpus.Embedder != engine.Provider {
+		engine.Provider = corpus.Embedder
+		engine.Model = rag.DefaultModel(corpus.Embedder)
+		setEmbeddingPrefixes(engine, cfg)
+	}
+}
+
+func CorpusSettings(name string, corpus config.Corpus) rag.IndexSettings {
+	return rag.IndexSettings{
+		Corpus:       name,
+		Patterns:     corpus.Patterns,
+		ChunkSize:    corpus.ChunkSize,
+		ChunkOverlap: corpus.ChunkOverlap,
+		Embedder:     corpus.Embedder,
+		Types:        corpus.Types,
+	}
+}
+
+func (a *Agent) WatchRAG(ctx context.Context, interval, debounce time.Duration) error {
+	cachePath, err := a.prepareRAG()
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := a.RagEngine.StaleFiles(cachePath, a.config.RagGlobs); err != nil {
+		ui.Printf(os.Stdout, ui.ColorBlue, "Cannot reuse the cache (%v), indexing from scratch...\n", err)
+		if err := a.indexRAG(ctx, cachePath); err != nil {
+			return err
+		}
+	} else if _, err := a.RagEngine.LoadEmbeddings(cachePath); err != nil {
+		return err
 	}
 
+	return a.RagEngine.Watch(ctx, cachePath, a.config.RagGlobs, interval, debounce)
+}
+
+func (a *Agent) indexRAG(ctx context.Context, cachePath string) error {
 	if err := a.RagEngine.IngestGlobs(ctx, a.config.RagGlobs); err != nil {
 		return err
 	}
 
+	if a.config.RagHierarchical {
+		a.RagEngine.Summaries = nil
+		if err := a.RagEngine.BuildSummaries(ctx, a.summarizeDocument); err != nil {
+			return err
+		}
+	}
+
 	if err := a.RagEngine.SaveEmbeddings(cachePath, a.config.RagGlobs); err != nil {
-		fmt.Printf("%sWarning: Failed to save cache: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+		ui.Printf(os.Stdout, ui.ColorRed, "Warning: Failed to save cache: %v\n", err)
 	}
 
 	return nil
 }
 
+func (a *Agent) summarizeDocument(ctx context.Context, filename, content string) (string, error) {
+	req := openai.ChatCompletionRequest{
+		Model: a.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    a.instructionRole(),
+				Content: "Summarize the document in 3-5 sentences. Cover its main topics and the kinds of questions it can answer. Output only the summary.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("Document: %s\n\n%s", filename, content),
+			},
+		},
+		Temperature: 0.2,
+	}
+
+	resp, err := a.chatCompletion(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	a.recordUsage(req.Model, resp.Usage)
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("api returned empty response (no choices)")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+func (a *Agent) toolChoice() any {
+	switch a.config.ToolChoice {
+	case "", "auto":
+		return nil
+	case "none", "required":
+		return a.config.ToolChoice
+	}
+	return openai.ToolChoice{
+		Type:     openai.ToolTypeFunction,
+		Function: openai.ToolFunction{Name: a.config.ToolChoice},
+	}
+}
+
 func (a *Agent) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	select {
+	case <-a.toolsReady:
+	case <-ctx.Done():
+		ui.Printf(os.Stderr, ui.ColorRed, "[MCP servers still connecting after %s, closing without waiting]\n", closeTimeout)
+	}
 	if a.Registry != nil {
 		a.Registry.Close()
 	}
+	if err := tools.RemoveOutputDir(); err != nil && a.config.Verbose {
+		ui.Printf(os.Stderr, ui.ColorRed, "[Could not remove tool output files: %v]\n", err)
+	}
+}
+
+func (a *Agent) historyBudget() int {
+	budget := a.config.MaxContextTokens
+	window := a.config.ContextWindow - a.config.MaxTokens
+	if a.config.ContextWindow > 0 && window > 0 && (budget <= 0 || window < budget) {
+		budget = window
+	}
+	return budget
 }
 
 func (a *Agent) pruneHistory() {
-	const maxHistory = 10
-	if len(a.history) <= maxHistory {
+	budget := a.historyBudget()
+	if budget <= 0 {
+		return
+	}
+	size := 0
+	for _, msg := range a.history {
+		size += len(msg.Content) + len(msg.Role)
+		for _, part := range msg.MultiContent {
+			size += len(part.Text)
+		}
+		for _, call := range msg.ToolCalls {
+			size += len(call.Function.Name) + len(call.Function.Arguments)
+		}
+	}
+	if size <= budget {
 		return
 	}
 
-	var newHistory []openai.ChatCompletionMessage
+	start := 0
 	if len(a.history) > 0 && a.history[0].Role == openai.ChatMessageRoleSystem {
-		newHistory = append(newHistory, a.history[0])
-		remaining := a.history[len(a.history)-(maxHistory-1):]
-		newHistory = append(newHistory, remaining...)
-	} else {
-		newHistory = a.history[len(a.history)-maxHistory:]
+		start = 1
+	}
+	for len(a.history)-start > 1 && tokenizer.CountTokens(a.tokens(), a.history) > budget {
+		a.history = append(a.history[:start], a.history[start+1:]...)
+		for len(a.history)-start > 1 && a.history[start].Role == openai.ChatMessageRoleTool {
+			a.history = append(a.history[:start], a.history[start+1:]...)
+		}
+	}
+}
+
+type ChatCompleter interface {
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatStream, error)
+}
+
+type ChatStream interface {
+	Recv() (openai.ChatCompletionStreamResponse, error)
+	Close() error
+}
+
+type openaiCompleter struct {
+	*openai.Client
+}
+
+func (c openaiCompleter) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatStream, error) {
+	stream, err := c.Client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func newClient(cfg config.Config) ChatCompleter {
+	clientConfig := openai.DefaultConfig(cfg.ApiKey)
+	if cfg.BaseURL != "" {
+		clientConfig.BaseURL = cfg.BaseURL
+	}
+	clientConfig.HTTPClient = newHTTPClient(cfg.ExtraBody, cfg.Debug, cfg.Verbose)
+	return openaiCompleter{openai.NewClientWithConfig(clientConfig)}
+}
+
+func (a *Agent) Model() string {
+	return a.config.Model
+}
+
+func (a *Agent) SetModel(name string) {
+	cfg := a.config
+	cfg.UseModel(name, config.SourceFlag)
+	chat := cfg.ChatEndpoint()
+	cfg.ApiKey, cfg.BaseURL, cfg.Model = chat.ApiKey, chat.BaseURL, chat.Model
+	if cfg.ApiKey != a.config.ApiKey || cfg.BaseURL != a.config.BaseURL {
+		a.client = newClient(cfg)
+	}
+	a.config = cfg
+	a.tokenizer = nil
+	a.reasoningNoted.Store(false)
+}
+
+func (a *Agent) tokens() tokenizer.Tokenizer {
+	if a.tokenizer == nil {
+		a.tokenizer = tokenizer.ForModel(a.config.Model)
 	}
-	a.history = newHistory
+	return a.tokenizer
+}
+
+func (a *Agent) ContextTokens() (int, tokenizer.Tokenizer) {
+	return tokenizer.CountTokens(a.tokens(), a.history), a.tokens()
 }
 
 func (a *Agent) generateSearchKeywords(ctx context.Context, userQuery string) string {
-	fmt.Printf("%sGenerating search keywords...%s ", ui.ColorBlue, ui.ColorReset)
+	ui.Printf(os.Stdout, ui.ColorBlue, "Generating search keywords... ")
 
 	req := openai.ChatCompletionRequest{
 		Model: a.config.Model,
 		Messages: []openai.ChatCompletionMessage{
 			{
-				Role: openai.ChatMessageRoleSystem,
+				Role: a.instructionRole(),
 				Content: "You are a retrieval assistant. Your goal is to rewrite the user's input into a concise, information-dense search query for a vector database. " +
 					"Remove conversational filler. Keep all technical terms, names, and specific requirements. " +
 					"Output ONLY the distilled search text."},
@@ -434,23 +876,26 @@ func (a *Agent) generateSearchKeywords(ctx context.Context, userQuery string) st
 		MaxTokens:   150,
 	}
 
-	resp, err := a.client.CreateChatCompletion(ctx, req)
+	resp, err := a.chatCompletion(ctx, req)
+	if err == nil {
+		a.recordUsage(req.Model, resp.Usage)
+	}
 	if err != nil || len(resp.Choices) == 0 {
-		fmt.Println("(failed, using original query)")
+		ui.Print(os.Stdout, "", "(failed, using original query)\n")
 		return userQuery
 	}
 
 	keywords := strings.TrimSpace(resp.Choices[0].Message.Content)
-	fmt.Printf("[%s]\n", keywords)
+	ui.Printf(os.Stdout, "", "[%s]\n", keywords)
 	return keywords
 }
 
 func (a *Agent) RunTurnCapture(ctx context.Context, prompt string) (string, error) {
 	var capturedOutput strings.Builder
 
-	err := a.runTurnInternal(ctx, prompt, func(s string) {
+	err := a.runTurnInternal(ctx, prompt, false, func(s string) {
 		capturedOutput.WriteString(s)
-		fmt.Print(s)
+		ui.PrintAgentMessage(s)
 	})
 
 	if err != nil {
@@ -460,12 +905,104 @@ func (a *Agent) RunTurnCapture(ctx context.Context, prompt string) (string, erro
 }
 
 func (a *Agent) RunTurn(ctx context.Context, prompt string, streaming bool) error {
-	return a.runTurnInternal(ctx, prompt, func(s string) {
+	return a.runTurnInternal(ctx, prompt, streaming, func(s string) {
 		ui.PrintAgentMessage(s)
 	})
 }
 
-func (a *Agent) runTurnInternal(ctx context.Context, prompt string, printFn func(string)) error {
+func (a *Agent) runTurnInternal(ctx context.Context, prompt string, streaming bool, printFn func(string)) error {
+	if err := a.config.ChatEndpoint().Validate("chat"); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	a.emit(Event{Type: EventTurnStart, Prompt: prompt})
+
+	var answer strings.Builder
+	err := a.runTurnSteps(ctx, prompt, streaming, func(s string) {
+		answer.WriteString(s)
+		printFn(s)
+	})
+
+	if err != nil {
+		a.emit(Event{Type: EventTurnError, Prompt: prompt, Err: err, Elapsed: time.Since(start)})
+	} else {
+		a.emit(Event{Type: EventTurnComplete, Prompt: prompt, Content: answer.String(), Elapsed: time.Since(start)})
+	}
+	return err
+}
+
+func (a *Agent) instructionRole() string {
+	if a.config.NoSystem {
+		return openai.ChatMessageRoleUser
+	}
+	return openai.ChatMessageRoleSystem
+}
+
+func withSystemMessage(history []openai.ChatCompletionMessage, content, role string) []openai.ChatCompletionMessage {
+	msg := openai.ChatCompletionMessage{Role: role, Content: content}
+
+	messages := make([]openai.ChatCompletionMessage, 0, len(history)+1)
+	if len(history) > 0 && history[0].Role == openai.ChatMessageRoleSystem {
+		messages = append(messages, history[0], msg)
+		return append(messages, history[1:]...)
+	}
+	messages = append(messages, msg)
+	return append(messages, history...)
+}
+
+func withPromptPrefix(history []openai.ChatCompletionMessage, idx int, prefix string) []openai.ChatCompletionMessage {
+	if prefix == "" {
+		return history
+	}
+
+	messages := append([]openai.ChatCompletionMessage(nil), history...)
+	msg := &messages[idx]
+	if len(msg.MultiContent) > 0 {
+		msg.MultiContent = append([]openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: prefix}}, msg.MultiContent...)
+	} else {
+		msg.Content = prefix + "\n\n" + msg.Content
+	}
+	return messages
+}
+
+const emptyResponseNudge = "Please provide your answer to the user."
+
+const continuePrompt = "Your previous message was cut off. Continue exactly where it stopped, without repeating anything."
+
+var (
+	ErrEmptyResponse         = errors.New("the model returned an empty response")
+	ErrStepLimit             = errors.New("agent step limit reached")
+	ErrRateLimited           = errors.New("rate limited")
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	ErrStreamIdle            = errors.New("streaming response stalled")
+	ErrNoAPIKey              = config.ErrNoAPIKey
+)
+
+func apiError(err error) error {
+	status, code, message := 0, "", ""
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status, code, message = apiErr.HTTPStatusCode, fmt.Sprint(apiErr.Code), apiErr.Message
+	case errors.As(err, &reqErr):
+		status, message = reqErr.HTTPStatusCode, string(reqErr.Body)
+	}
+
+	message = strings.ToLower(message)
+	switch {
+	case status == http.StatusTooManyRequests || code == "rate_limit_exceeded":
+		return fmt.Errorf("api error: %w: %w", ErrRateLimited, err)
+	case code == "context_length_exceeded" || strings.Contains(message, "context length") ||
+		strings.Contains(message, "maximum context") || strings.Contains(message, "too many tokens"):
+		return fmt.Errorf("api error: %w: %w", ErrContextLengthExceeded, err)
+	}
+	return fmt.Errorf("api error: %w", err)
+}
+
+func (a *Agent) runTurnSteps(ctx context.Context, prompt string, streaming bool, printFn func(string)) error {
+	a.pruneHistory()
 	historyStartLen := len(a.history)
 
 	defer func() {
@@ -474,31 +1011,80 @@ func (a *Agent) runTurnInternal(ctx context.Context, prompt string, printFn func
 		}
 	}()
 
-	a.pruneHistory()
+	a.unknownToolStreak = 0
+
+	turnStart := len(a.history)
+	rawPrompt := prompt
+	defer func() {
+		a.recordTranscript(rawPrompt, a.history[turnStart:])
+	}()
+
+	if a.config.ExpandMacros {
+		expanded, err := expandMacros(prompt)
+		if err != nil {
+			return err
+		}
+		prompt = expanded
+	}
+
+	var fileBlocks string
+	if !a.config.NoAtExpansion {
+		prompt, fileBlocks = expandFileReferences(prompt)
+	}
 
 	finalPrompt := prompt
+	ragContext := false
+	var ragResults []rag.Result
 
-	if len(a.config.RagGlobs) > 0 && len(a.RagEngine.Chunks) > 0 {
-		searchQuery := a.generateSearchKeywords(ctx, prompt)
+	if len(a.config.RagGlobs) > 0 && a.RagEngine.ChunkCount() > 0 {
+		searchQuery := prompt
+		if !a.config.DryRun {
+			searchQuery = a.generateSearchKeywords(ctx, prompt)
+		}
 
-		results, err := a.RagEngine.Search(ctx, searchQuery, a.config.RagTopK)
+		var results []rag.Result
+		var err error
+		if a.config.RagHierarchical {
+			results, err = a.RagEngine.SearchHierarchical(ctx, searchQuery, ragTopDocs, a.config.RagTopK)
+		} else {
+			results, err = a.RagEngine.SearchScored(ctx, searchQuery, a.config.RagTopK)
+		}
 		if err != nil {
-			fmt.Printf("%sRAG Search Error: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+			ui.Printf(os.Stdout, ui.ColorRed, "RAG Search Error: %v\n", err)
 		} else if len(results) > 0 {
-			var contextBuilder strings.Builder
-			contextBuilder.WriteString("Use the following context to answer the user's question:\n\n")
-			for _, r := range results {
-				contextBuilder.WriteString(fmt.Sprintf("--- Source: %s ---\n%s\n\n", r.Filename, r.Text))
+			rendered, err := a.renderRagContext(prompt, results)
+			if err != nil {
+				return err
 			}
-			contextBuilder.WriteString("User Question: " + prompt)
-			finalPrompt = contextBuilder.String()
-			fmt.Printf("%sFound %d relevant context chunks.%s\n", ui.ColorGreen, len(results), ui.ColorReset)
+			finalPrompt = rendered
+			ragContext = true
+			ragResults = results
+			ui.Printf(os.Stdout, ui.ColorGreen, "Found %d relevant context chunks.\n", len(results))
 		}
 	}
 
-	attachedURIs, err := a.getAttachmentURIs()
-	if err != nil {
-		fmt.Printf("%sWarning: failed to attach files: %v%s\n", ui.ColorRed, err, ui.ColorReset)
+	finalPrompt += fileBlocks
+
+	var attachedURIs []string
+	if a.config.AttachAsBase64 {
+		inlined, err := a.getInlineAttachments()
+		if err != nil {
+			ui.Printf(os.Stdout, ui.ColorRed, "Warning: failed to attach files: %v\n", err)
+		}
+		finalPrompt += inlined
+	} else {
+		uris, err := a.getAttachmentURIs()
+		if err != nil {
+			ui.Printf(os.Stdout, ui.ColorRed, "Warning: failed to attach files: %v\n", err)
+		}
+		attachedURIs = uris
+	}
+	for _, d := range a.takePendingData() {
+		if a.config.AttachAsBase64 {
+			finalPrompt += fmt.Sprintf("\n\n--- ATTACHMENT: stdin (%s, base64) ---\n%s\n", d.mime, base64.StdEncoding.EncodeToString(d.data))
+		} else {
+			attachedURIs = append(attachedURIs, d.dataURI())
+		}
 	}
 
 	var userMsg openai.ChatCompletionMessage
@@ -528,32 +1114,103 @@ func (a *Agent) runTurnInternal(ctx context.Context, prompt string, printFn func
 		}
 	}
 	a.history = append(a.history, userMsg)
+	userIdx := len(a.history) - 1
+
+	if a.config.DryRun {
+		messages := withPromptPrefix(a.history, userIdx, a.promptPrefix)
+		if ragContext && a.config.RagSystemPrompt != "" && !a.config.NoSystem {
+			messages = withSystemMessage(messages, a.config.RagSystemPrompt, openai.ChatMessageRoleSystem)
+		}
+		printDryRun(printFn, messages)
+		return nil
+	}
 
 	maxSteps := a.config.MaxSteps
 	if !a.agenticMode {
 		maxSteps = 1
 	}
 
+	if a.config.BudgetUSD > 0 {
+		if _, err := a.config.Price(a.config.Model); err != nil {
+			return fmt.Errorf("--budget-usd: %w", err)
+		}
+	}
+
+	streaming = streaming && a.config.ToolOnly == "" && !(a.agenticMode && a.config.TextTools)
+
+	var usage openai.Usage
+	defer func() { a.recordUsage(a.config.Model, usage) }()
 	steps := 0
+	nudged := false
+	continuations := 0
+	cutOff := false
+	var truncated string
 	for steps < maxSteps {
+		if err := a.checkBudget(usage, steps, a.history[turnStart:]); err != nil {
+			return err
+		}
+
+		messages := pairToolResults(withPromptPrefix(a.history, userIdx, a.promptPrefix))
 		req := openai.ChatCompletionRequest{
 			Model:       a.config.Model,
-			Messages:    a.history,
+			Messages:    messages,
 			Temperature: a.config.Temperature,
+			MaxTokens:   a.config.MaxTokens,
+		}
+		if nudged {
+			req.Messages = append(append([]openai.ChatCompletionMessage(nil), messages...), openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleUser,
+				Content: emptyResponseNudge,
+			})
+		}
+		if truncated != "" {
+			req.Messages = append(append([]openai.ChatCompletionMessage(nil), messages...),
+				openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: truncated},
+				openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: continuePrompt},
+			)
 		}
 
 		if a.agenticMode {
+			a.toolsLoaded()
 			availTools := a.Registry.GetOpenAITools()
-			if len(availTools) > 0 {
+			if a.config.TextTools {
+				if len(availTools) > 0 {
+					req.Messages = withTextToolsPrompt(req.Messages, availTools, a.instructionRole())
+				}
+			} else if len(availTools) > 0 {
 				req.Tools = availTools
+				if steps == 0 {
+					req.ToolChoice = a.toolChoice()
+				}
 			}
 		}
 
-		resp, err := a.client.CreateChatCompletion(ctx, req)
+		if ragContext && a.config.RagSystemPrompt != "" && !a.config.NoSystem {
+			req.Messages = withSystemMessage(req.Messages, a.config.RagSystemPrompt, openai.ChatMessageRoleSystem)
+		}
+
+		var resp openai.ChatCompletionResponse
+		var err error
+		streamed := false
+		if streaming {
+			resp, err = a.streamChatCompletion(ctx, req, func(s string) {
+				streamed = true
+				printFn(s)
+			})
+			if streamed && (err != nil || len(resp.Choices[0].Message.ToolCalls) > 0) {
+				printFn("\n")
+			}
+		} else {
+			resp, err = a.chatCompletion(ctx, req)
+		}
 		if err != nil {
-			return fmt.Errorf("api error: %w", err)
+			return apiError(err)
 		}
 
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+
 		if len(resp.Choices) == 0 {
 			return fmt.Errorf("api returned empty response (no choices)")
 		}
@@ -562,35 +1219,192 @@ func (a *Agent) runTurnInternal(ctx context.Context, prompt string, printFn func
 		a.history = append(a.history, msg)
 
 		if len(msg.ToolCalls) > 0 && a.agenticMode {
-			ui.PrintToolUse(msg.ToolCalls[0].Function.Name, msg.ToolCalls[0].Function.Arguments)
-
+			results := make(map[string]openai.ChatCompletionMessage, len(msg.ToolCalls))
+			var images []tools.Image
 			for _, toolCall := range msg.ToolCalls {
-				cleanName := strings.Split(toolCall.Function.Name, "{")[0]
-				cleanName = strings.Split(cleanName, "=")[0]
-				cleanName = strings.TrimSpace(cleanName)
+				cleanName, args := normalizeToolName(toolCall.Function.Name, toolCall.Function.Arguments, a.Registry.Names())
+				toolCall.Function.Arguments = args
+				ui.PrintToolUse(cleanName, toolCall.Function.Arguments)
+
+				output, toolImages, err := a.executeTool(cleanName, toolCall.Function.Arguments)
+				images = append(images, toolImages...)
 
-				output, err := a.Registry.Execute(cleanName, toolCall.Function.Arguments)
-				if err != nil {
-					output = fmt.Sprintf("Error executing tool: %v", err)
+				if a.config.ToolOnly != "" && err == nil {
+					results[toolCall.ID] = toolResult(toolCall.ID, output)
+					a.appendToolResults(msg.ToolCalls, results)
+					return printToolOnlyResult(printFn, a.config.ToolOnly, cleanName, toolCall.Function.Arguments, output)
 				}
 
-				if len(output) > 10000 {
-					output = output[:10000] + "\n...(truncated output)"
+				output = a.limitToolOutput(ctx, rawPrompt, cleanName, output)
+				results[toolCall.ID] = toolResult(toolCall.ID, output)
+			}
+			a.appendToolResults(msg.ToolCalls, results)
+			a.appendToolImages(images)
+			a.correctUnknownTools()
+			steps++
+			continue
+		}
+
+		if len(msg.ToolCalls) == 0 && a.agenticMode && a.config.TextTools {
+			if calls := parseTextToolCalls(msg.Content, a.Registry.Has); len(calls) > 0 {
+				var results strings.Builder
+				var images []tools.Image
+				for _, call := range calls {
+					ui.PrintToolUse(call.Name, call.Arguments)
+
+					output, toolImages, err := a.executeTool(call.Name, call.Arguments)
+					images = append(images, toolImages...)
+					if a.config.ToolOnly != "" && err == nil {
+						return printToolOnlyResult(printFn, a.config.ToolOnly, call.Name, call.Arguments, output)
+					}
+
+					output = a.limitToolOutput(ctx, rawPrompt, call.Name, output)
+					results.WriteString(fmt.Sprintf("Result of tool %s:\n%s\n\n", call.Name, output))
 				}
 
 				a.history = append(a.history, openai.ChatCompletionMessage{
-					Role:       openai.ChatMessageRoleTool,
-					Content:    output,
-					ToolCallID: toolCall.ID,
+					Role:    openai.ChatMessageRoleUser,
+					Content: strings.TrimSpace(results.String()),
 				})
+				a.appendToolImages(images)
+				steps++
+				continue
 			}
-			steps++
+		}
+
+		if truncated != "" {
+			msg.Content = truncated + msg.Content
+			a.history[len(a.history)-1] = msg
+		}
+
+		if resp.Choices[0].FinishReason == openai.FinishReasonLength && strings.TrimSpace(msg.Content) != "" {
+			if continuations < a.config.AutoContinue {
+				continuations++
+				if streamed {
+					ui.Print(os.Stderr, "", "\n")
+				}
+				ui.Printf(os.Stderr, ui.ColorRed, "[Response cut off at the token limit, continuing (%d/%d)]\n", continuations, a.config.AutoContinue)
+				truncated = msg.Content
+				a.history = a.history[:len(a.history)-1]
+				continue
+			}
+			cutOff = true
+		}
+
+		if strings.TrimSpace(msg.Content) == "" {
+			a.history = a.history[:len(a.history)-1]
+			if nudged {
+				return ErrEmptyResponse
+			}
+			ui.Printf(os.Stderr, ui.ColorRed, "[Model returned an empty message, asking again]\n")
+			nudged = true
 			continue
 		}
 
-		printFn(msg.Content + "\n")
+		if streamed {
+			printFn("\n")
+		} else {
+			printFn(msg.Content + "\n")
+		}
+		if len(ragResults) > 0 {
+			printSources(ragResults)
+		}
+		if a.config.RagVerify && len(ragResults) > 0 {
+			a.verifyGrounding(ctx, msg.Content, ragResults, &usage)
+		}
+		if cutOff && a.config.AutoContinue > 0 {
+			ui.Printf(os.Stderr, ui.ColorRed, "[Response still cut off after --auto-continue=%d]\n", continuations)
+		} else if cutOff {
+			ui.Printf(os.Stderr, ui.ColorRed, "[Response cut off at the token limit; use --auto-continue to request the rest]\n")
+		}
+		return nil
+	}
+
+	return ErrStepLimit
+}
+
+func (a *Agent) executeTool(name string, args string) (string, []tools.Image, error) {
+	a.emit(Event{Type: EventToolCall, ToolName: name, ToolArgs: args})
+	start := time.Now()
+
+	var output string
+	var images []tools.Image
+	var err error
+	switch {
+	case !a.Registry.Has(name):
+		err = a.unknownTool(name)
+	case a.confirmTool(name, args):
+		a.unknownToolStreak = 0
+		output, images, err = a.Registry.Execute(name, args)
+	default:
+		a.unknownToolStreak = 0
+		err = fmt.Errorf("the user declined to run %s", name)
+	}
+	if err != nil {
+		output = fmt.Sprintf("Error executing tool: %v", err)
+	}
+
+	a.emit(Event{Type: EventToolResult, ToolName: name, Content: output, Err: err, Elapsed: time.Since(start)})
+	return output, images, err
+}
+
+func (a *Agent) appendToolImages(images []tools.Image) {
+	if len(images) == 0 || !a.config.ToolImages {
+		return
+	}
+
+	parts := []openai.ChatMessagePart{{
+		Type: openai.ChatMessagePartTypeText,
+		Text: fmt.Sprintf("The tool calls above returned %d image(s), attached here.", len(images)),
+	}}
+	for _, img := range images {
+		parts = append(parts, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: img.DataURI()},
+		})
+	}
+	a.history = append(a.history, openai.ChatCompletionMessage{
+		Role:         openai.ChatMessageRoleUser,
+		MultiContent: parts,
+	})
+}
+
+func printDryRun(printFn func(string), messages []openai.ChatCompletionMessage) {
+	var sb strings.Builder
+	for _, msg := range messages {
+		sb.WriteString(fmt.Sprintf("--- %s ---\n", msg.Role))
+		content := msg.Content
+		for _, part := range msg.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeText {
+				content += part.Text
+			} else if part.ImageURL != nil {
+				content += "\n[attachment]"
+			}
+		}
+		sb.WriteString(strings.TrimRight(content, "\n") + "\n\n")
+	}
+	printFn(strings.TrimRight(sb.String(), "\n") + "\n")
+}
+
+func printToolOnlyResult(printFn func(string), format string, name string, args string, output string) error {
+	if format != "json" {
+		printFn(output + "\n")
 		return nil
 	}
 
-	return errors.New("agent step limit reached")
+	rawArgs := json.RawMessage(args)
+	if !json.Valid(rawArgs) {
+		rawArgs = json.RawMessage("{}")
+	}
+
+	b, err := json.MarshalIndent(map[string]interface{}{
+		"tool":      name,
+		"arguments": rawArgs,
+		"output":    output,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	printFn(string(b) + "\n")
+	return nil
 }
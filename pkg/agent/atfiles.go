@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuriiter/ai/pkg/rag"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+const (
+	maxAtFiles     = 20
+	maxAtFileBytes = 256 * 1024
+)
+
+var atReferenceRegex = regexp.MustCompile(`(^|\s)@([^\s@]+)`)
+
+type atFile struct {
+	path    string
+	content string
+}
+
+func expandFileReferences(prompt string) (string, string) {
+	var files []atFile
+	seen := make(map[string]bool)
+	totalBytes := 0
+	limitWarned := false
+
+	prose := atReferenceRegex.ReplaceAllStringFunc(prompt, func(match string) string {
+		groups := atReferenceRegex.FindStringSubmatch(match)
+		lead, token := groups[1], groups[2]
+		ref := strings.TrimRight(token, ".,;:!?)]}'\"")
+		trailing := token[len(ref):]
+		if ref == "" {
+			return match
+		}
+
+		paths := resolveAtReference(ref)
+		if len(paths) == 0 {
+			if strings.ContainsAny(ref, `/.\`) {
+				warnMissingReference(ref)
+			}
+			return match
+		}
+
+		var names []string
+		for _, path := range paths {
+			if seen[path] {
+				names = append(names, filepath.Base(path))
+				continue
+			}
+			if len(files) >= maxAtFiles {
+				if !limitWarned {
+					ui.Printf(os.Stderr, ui.ColorRed, "Warning: only the first %d @file references are included\n", maxAtFiles)
+					limitWarned = true
+				}
+				break
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				ui.Printf(os.Stderr, ui.ColorRed, "Warning: failed to read %s: %v\n", path, err)
+				continue
+			}
+			if !utf8.Valid(data) {
+				ui.Printf(os.Stderr, ui.ColorRed, "Warning: skipping binary file %s\n", path)
+				continue
+			}
+			if totalBytes+len(data) > maxAtFileBytes {
+				ui.Printf(os.Stderr, ui.ColorRed, "Warning: skipping %s, @file references are limited to %d KB in total\n", path, maxAtFileBytes/1024)
+				continue
+			}
+
+			totalBytes += len(data)
+			seen[path] = true
+			files = append(files, atFile{path: path, content: string(data)})
+			names = append(names, filepath.Base(path))
+		}
+
+		if len(names) == 0 {
+			return match
+		}
+		return lead + strings.Join(names, ", ") + trailing
+	})
+
+	if len(files) == 0 {
+		return prompt, ""
+	}
+
+	var sb strings.Builder
+	for _, f := range files {
+		fence := codeFence(f.content)
+		sb.WriteString(fmt.Sprintf("\n\n--- File: %s ---\n%s\n%s", f.path, fence, f.content))
+		if !strings.HasSuffix(f.content, "\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fence)
+	}
+	ui.Printf(os.Stdout, ui.ColorBlue, "Included %d file(s) referenced with @\n", len(files))
+	return prose, sb.String()
+}
+
+func resolveAtReference(ref string) []string {
+	if strings.ContainsAny(ref, "*?[") {
+		var paths []string
+		for _, path := range rag.FindFiles([]string{ref}) {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+
+	info, err := os.Stat(ref)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return []string{ref}
+}
+
+func warnMissingReference(ref string) {
+	msg := fmt.Sprintf("Warning: @%s does not match any file", ref)
+	if suggestions := nearMissPaths(ref); len(suggestions) > 0 {
+		msg += fmt.Sprintf(" (did you mean %s?)", strings.Join(suggestions, ", "))
+	}
+	ui.Printf(os.Stderr, ui.ColorRed, "%s\n", msg)
+}
+
+func nearMissPaths(ref string) []string {
+	dir, base := filepath.Split(ref)
+	listDir := dir
+	if listDir == "" {
+		listDir = "."
+	}
+	entries, err := os.ReadDir(listDir)
+	if err != nil {
+		return nil
+	}
+
+	type candidate struct {
+		path     string
+		distance int
+	}
+	var candidates []candidate
+	lowerBase := strings.ToLower(base)
+	for _, e := range entries {
+		name := e.Name()
+		d := levenshtein(lowerBase, strings.ToLower(name))
+		if d <= 3 || (len(base) >= 3 && strings.HasPrefix(strings.ToLower(name), lowerBase)) {
+			candidates = append(candidates, candidate{path: dir + name, distance: d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].path < candidates[j].path
+	})
+
+	var out []string
+	for i := 0; i < len(candidates) && i < 3; i++ {
+		out = append(out, "@"+candidates[i].path)
+	}
+	return out
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func codeFence(content string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return fence
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+func TestExpandFileReferencesUsesBaseNames(t *testing.T) {
+	ui.RedirectStdout(io.Discard)
+	t.Cleanup(func() { ui.RedirectStdout(os.Stdout) })
+
+	dir := t.TempDir()
+	retry := filepath.Join(dir, "retry.go")
+	for path, content := range map[string]string{retry: "package retry\n", filepath.Join(dir, "notes.md"): "# Notes\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prose, attached := expandFileReferences("explain @" + retry + ", then compare @" + filepath.Join(dir, "*.md") + ".")
+	if want := "explain retry.go, then compare notes.md."; prose != want {
+		t.Errorf("prose = %q, want %q", prose, want)
+	}
+	if strings.Contains(prose, dir) {
+		t.Errorf("prose leaks the directory: %q", prose)
+	}
+	for _, want := range []string{"--- File: " + retry + " ---", "package retry", "# Notes"} {
+		if !strings.Contains(attached, want) {
+			t.Errorf("attached files do not contain %q:\n%s", want, attached)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+func (a *Agent) checkBudget(usage openai.Usage, steps int, turnMessages []openai.ChatCompletionMessage) error {
+	if a.config.BudgetTokens <= 0 && a.config.BudgetUSD <= 0 {
+		return nil
+	}
+
+	var cost float64
+	if a.config.BudgetUSD > 0 {
+		price, err := a.config.Price(a.config.Model)
+		if err != nil {
+			return fmt.Errorf("--budget-usd: %w", err)
+		}
+		cost = price.Cost(usage.PromptTokens, usage.CompletionTokens)
+	}
+
+	tokensExceeded := a.config.BudgetTokens > 0 && usage.TotalTokens >= a.config.BudgetTokens
+	costExceeded := a.config.BudgetUSD > 0 && cost >= a.config.BudgetUSD
+	if !tokensExceeded && !costExceeded {
+		return nil
+	}
+
+	summary := fmt.Sprintf("stopped before step %d: used %d tokens (%d prompt + %d completion)",
+		steps+1, usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+	if a.config.BudgetUSD > 0 {
+		summary += fmt.Sprintf(", about $%.4f of $%.4f", cost, a.config.BudgetUSD)
+	}
+	if a.config.BudgetTokens > 0 {
+		summary += fmt.Sprintf(", limit %d tokens", a.config.BudgetTokens)
+	}
+
+	var called []string
+	for _, msg := range turnMessages {
+		for _, tc := range msg.ToolCalls {
+			called = append(called, tc.Function.Name)
+		}
+	}
+	if len(called) > 0 {
+		summary += "; tools called: " + strings.Join(called, ", ")
+	}
+	return fmt.Errorf("%w: %s", ErrBudgetExceeded, summary)
+}
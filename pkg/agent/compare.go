@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/config"
+)
+
+type Completion struct {
+	Model   string
+	Content string
+	Usage   openai.Usage
+	Elapsed time.Duration
+	Err     error
+}
+
+type Sampling struct {
+	Model       config.ModelAlias
+	Temperature *float32
+	TopP        *float32
+}
+
+func (a *Agent) Complete(ctx context.Context, system string, model config.ModelAlias, prompt string, seed *int) Completion {
+	return a.CompleteWith(ctx, system, prompt, Sampling{Model: model}, seed)
+}
+
+func (a *Agent) CompleteWith(ctx context.Context, system, prompt string, sampling Sampling, seed *int) Completion {
+	model := sampling.Model.Model
+	if model == "" {
+		model = a.config.Model
+	}
+	temperature := a.config.Temperature
+	if sampling.Temperature != nil {
+		temperature = explicitZero(*sampling.Temperature)
+	}
+	var topP float32
+	if sampling.TopP != nil {
+		topP = explicitZero(*sampling.TopP)
+	}
+
+	var messages []openai.ChatCompletionMessage
+	if system != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: a.instructionRole(), Content: system})
+	}
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: prompt})
+
+	start := time.Now()
+	resp, err := a.clientFor(sampling.Model).CreateChatCompletion(ctx, a.prepareRequest(openai.ChatCompletionRequest{
+		Model:       model,
+		Messages:    messages,
+		Temperature: temperature,
+		TopP:        topP,
+		Seed:        seed,
+	}))
+	result := Completion{Model: model, Elapsed: time.Since(start)}
+	if err != nil {
+		result.Err = apiError(err)
+		return result
+	}
+	if len(resp.Choices) == 0 {
+		result.Err = fmt.Errorf("api returned empty response (no choices)")
+		return result
+	}
+
+	result.Content = strings.TrimSpace(resp.Choices[0].Message.Content)
+	result.Usage = resp.Usage
+	a.recordUsage(model, resp.Usage)
+	return result
+}
+
+func (a *Agent) clientFor(alias config.ModelAlias) ChatCompleter {
+	cfg := a.config
+	if alias.BaseURL != "" {
+		cfg.BaseURL = alias.BaseURL
+	}
+	if alias.ApiKey != "" {
+		cfg.ApiKey = alias.ApiKey
+	}
+	if cfg.ApiKey == a.config.ApiKey && cfg.BaseURL == a.config.BaseURL {
+		return a.client
+	}
+	return newClient(cfg)
+}
+
+func explicitZero(v float32) float32 {
+	if v == 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return v
+}
+
+func (a *Agent) Judge(ctx context.Context, model config.ModelAlias, prompt, answerA, answerB string) Completion {
+	system := "You compare two answers to the same prompt. Decide which one better satisfies the prompt: " +
+		"correctness first, then completeness, then clarity. Reply with a first line of exactly \"Winner: A\", " +
+		"\"Winner: B\", or \"Winner: tie\", followed by one paragraph explaining the decision."
+	content := fmt.Sprintf("Prompt:\n%s\n\n--- Answer A ---\n%s\n\n--- Answer B ---\n%s", prompt, answerA, answerB)
+
+	return a.Complete(ctx, system, model, content, nil)
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/config"
+)
+
+func TestCompleteConcurrentlyWithReasoningModel(t *testing.T) {
+	const sides = 4
+	replies := make([]openai.ChatCompletionMessage, sides)
+	for i := range replies {
+		replies[i] = openai.ChatCompletionMessage{Content: "answer"}
+	}
+	a, fake := scriptedAgent(false, replies...)
+	a.config.Verbose = true
+	a.config.ReasoningModels = []string{"test-model"}
+	a.config.Temperature = 0.5
+
+	var wg sync.WaitGroup
+	results := make([]Completion, sides)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = a.Complete(context.Background(), "system", config.ModelAlias{}, "question", nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, res := range results {
+		if res.Err != nil || res.Content != "answer" {
+			t.Errorf("side %d: got %q, %v", i, res.Content, res.Err)
+		}
+	}
+	for _, req := range fake.requests {
+		if req.Temperature != 0 {
+			t.Errorf("sent temperature %v to a reasoning model", req.Temperature)
+		}
+	}
+	if !a.reasoningNoted.Load() {
+		t.Error("the reasoning model note was not recorded")
+	}
+}
+
+func TestCompleteUsesAliasEndpoint(t *testing.T) {
+	var got openai.ChatCompletionRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "local answer"}}},
+		})
+	}))
+	defer server.Close()
+
+	a, fake := scriptedAgent(false)
+	alias := config.ModelAlias{Model: "llama3.1:8b", BaseURL: server.URL, ApiKey: "local-key"}
+	res := a.Complete(context.Background(), "", alias, "question", nil)
+
+	if res.Err != nil || res.Content != "local answer" {
+		t.Fatalf("got %q, %v", res.Content, res.Err)
+	}
+	if got.Model != "llama3.1:8b" || auth != "Bearer local-key" {
+		t.Errorf("endpoint got model %q with %q", got.Model, auth)
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("the default client got %d requests", len(fake.requests))
+	}
+}
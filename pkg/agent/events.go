@@ -0,0 +1,36 @@
+package agent
+
+import "time"
+
+type EventType int
+
+const (
+	EventTurnStart EventType = iota
+	EventToolCall
+	EventToolResult
+	EventTurnComplete
+	EventTurnError
+	EventUnknownTool
+)
+
+type Event struct {
+	Type     EventType
+	Prompt   string
+	Content  string
+	ToolName string
+	ToolArgs string
+	Err      error
+	Elapsed  time.Duration
+}
+
+type EventHandler func(Event)
+
+func (a *Agent) OnEvent(handler EventHandler) {
+	a.handlers = append(a.handlers, handler)
+}
+
+func (a *Agent) emit(ev Event) {
+	for _, h := range a.handlers {
+		h(ev)
+	}
+}
@@ -0,0 +1,291 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/tokenizer"
+	"github.com/yuriiter/ai/pkg/tools"
+)
+
+type fakeCompleter struct {
+	mu       sync.Mutex
+	replies  []openai.ChatCompletionMessage
+	requests []openai.ChatCompletionRequest
+}
+
+func (f *fakeCompleter) next(req openai.ChatCompletionRequest) (openai.ChatCompletionMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	if len(f.replies) == 0 {
+		return openai.ChatCompletionMessage{}, errors.New("fake completer: no scripted reply left")
+	}
+	msg := f.replies[0]
+	f.replies = f.replies[1:]
+	msg.Role = openai.ChatMessageRoleAssistant
+	return msg, nil
+}
+
+func (f *fakeCompleter) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	msg, err := f.next(req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	return openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: msg, FinishReason: openai.FinishReasonStop}},
+		Usage:   openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (f *fakeCompleter) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatStream, error) {
+	msg, err := f.next(req)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []openai.ChatCompletionStreamResponse
+	if msg.Content != "" {
+		chunks = append(chunks, openai.ChatCompletionStreamResponse{Choices: []openai.ChatCompletionStreamChoice{{
+			Delta: openai.ChatCompletionStreamChoiceDelta{Content: msg.Content},
+		}}})
+	}
+	for i, call := range msg.ToolCalls {
+		call.Index = &i
+		chunks = append(chunks, openai.ChatCompletionStreamResponse{Choices: []openai.ChatCompletionStreamChoice{{
+			Delta: openai.ChatCompletionStreamChoiceDelta{ToolCalls: []openai.ToolCall{call}},
+		}}})
+	}
+	chunks = append(chunks, openai.ChatCompletionStreamResponse{Choices: []openai.ChatCompletionStreamChoice{{
+		FinishReason: openai.FinishReasonStop,
+	}}})
+	return &fakeStream{chunks: chunks}, nil
+}
+
+type fakeStream struct {
+	chunks []openai.ChatCompletionStreamResponse
+}
+
+func (s *fakeStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if len(s.chunks) == 0 {
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *fakeStream) Close() error { return nil }
+
+func scriptedAgent(agentic bool, replies ...openai.ChatCompletionMessage) (*Agent, *fakeCompleter) {
+	fake := &fakeCompleter{replies: replies}
+	ready := make(chan struct{})
+	close(ready)
+	return &Agent{
+		client:        fake,
+		config:        config.Config{ApiKey: "test", Model: "test-model", MaxSteps: 10, RetainHistory: true},
+		Registry:      tools.NewRegistry(),
+		agenticMode:   agentic,
+		toolsReady:    ready,
+		toolsReported: true,
+		tokenizer:     tokenizer.Heuristic{},
+	}, fake
+}
+
+func runScripted(t *testing.T, a *Agent, prompt string, streaming bool) (string, error) {
+	t.Helper()
+	var out strings.Builder
+	err := a.runTurnInternal(context.Background(), prompt, streaming, func(s string) { out.WriteString(s) })
+	return out.String(), err
+}
+
+func TestEmptyResponseRetriesOnce(t *testing.T) {
+	empty := openai.ChatCompletionMessage{}
+	answer := openai.ChatCompletionMessage{Content: "The answer is 42."}
+
+	for _, streaming := range []bool{false, true} {
+		name := "blocking"
+		if streaming {
+			name = "streaming"
+		}
+		t.Run(name+"/recovers", func(t *testing.T) {
+			a, fake := scriptedAgent(false, empty, answer)
+			out, err := runScripted(t, a, "question", streaming)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(out) != answer.Content {
+				t.Errorf("printed %q", out)
+			}
+			if len(fake.requests) != 2 {
+				t.Fatalf("sent %d requests, want 2", len(fake.requests))
+			}
+			msgs := fake.requests[1].Messages
+			if last := msgs[len(msgs)-1]; last.Content != emptyResponseNudge {
+				t.Errorf("retry ended with %q, want the nudge", last.Content)
+			}
+			if got := contents(a.history); strings.Join(got, "|") != "question|"+answer.Content {
+				t.Errorf("history %q", got)
+			}
+		})
+		t.Run(name+"/gives up", func(t *testing.T) {
+			a, fake := scriptedAgent(false, empty, empty)
+			_, err := runScripted(t, a, "question", streaming)
+			if !errors.Is(err, ErrEmptyResponse) {
+				t.Fatalf("got %v, want ErrEmptyResponse", err)
+			}
+			if len(fake.requests) != 2 {
+				t.Fatalf("sent %d requests, want 2", len(fake.requests))
+			}
+			if got := contents(a.history); strings.Join(got, "|") != "question" {
+				t.Errorf("history keeps the empty reply: %q", got)
+			}
+		})
+	}
+}
+
+func toolCall(id, name, args string) openai.ToolCall {
+	return openai.ToolCall{ID: id, Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: name, Arguments: args}}
+}
+
+func TestSeveralToolCallsInOneTurn(t *testing.T) {
+	calls := []openai.ToolCall{
+		toolCall("call_a", "echo", `{"text":"one"}`),
+		toolCall("call_b", "echo", `{"text":"two"}`),
+		toolCall("call_c", "missing_tool", `{}`),
+		toolCall("call_d", "upper", `{"text":"four"}`),
+	}
+	want := map[string]string{
+		"call_a": "one",
+		"call_b": "two",
+		"call_c": "Error executing tool",
+		"call_d": "FOUR",
+	}
+
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming=%v", streaming), func(t *testing.T) {
+			a, fake := scriptedAgent(true,
+				openai.ChatCompletionMessage{ToolCalls: calls},
+				openai.ChatCompletionMessage{Content: "done"},
+			)
+			readOnly := true
+			for name, fn := range map[string]func(string) string{"echo": func(s string) string { return s }, "upper": strings.ToUpper} {
+				a.Registry.RegisterInternal(openai.FunctionDefinition{Name: name}, tools.Annotations{ReadOnlyHint: &readOnly}, func(args string) (string, error) {
+					var in struct{ Text string }
+					if err := json.Unmarshal([]byte(args), &in); err != nil {
+						return "", err
+					}
+					return fn(in.Text), nil
+				})
+			}
+
+			if _, err := runScripted(t, a, "run the tools", streaming); err != nil {
+				t.Fatal(err)
+			}
+			if len(fake.requests) != 2 {
+				t.Fatalf("sent %d requests, want 2", len(fake.requests))
+			}
+
+			msgs := fake.requests[1].Messages
+			idx := -1
+			for i, msg := range msgs {
+				if len(msg.ToolCalls) > 0 {
+					idx = i
+				}
+			}
+			if idx < 0 || len(msgs[idx].ToolCalls) != len(calls) {
+				t.Fatalf("the follow-up request does not repeat the %d tool calls", len(calls))
+			}
+			results := msgs[idx+1:]
+			if len(results) < len(calls) {
+				t.Fatalf("got %d messages after the tool calls, want at least %d", len(results), len(calls))
+			}
+			for i, call := range calls {
+				res := results[i]
+				if res.Role != openai.ChatMessageRoleTool || res.ToolCallID != call.ID {
+					t.Fatalf("message %d after the calls is %s for %q, want the result for %s", i, res.Role, res.ToolCallID, call.ID)
+				}
+				if !strings.Contains(res.Content, want[call.ID]) {
+					t.Errorf("result for %s is %q, want it to contain %q", call.ID, res.Content, want[call.ID])
+				}
+			}
+		})
+	}
+}
+
+func TestPairToolResultsOutOfOrder(t *testing.T) {
+	calls := []openai.ToolCall{toolCall("1", "a", "{}"), toolCall("2", "b", "{}"), toolCall("3", "c", "{}")}
+	history := []openai.ChatCompletionMessage{
+		message(openai.ChatMessageRoleUser, "go"),
+		{Role: openai.ChatMessageRoleAssistant, ToolCalls: calls},
+		toolResult("3", "third"),
+		toolResult("1", "first"),
+		toolResult("1", "duplicate"),
+		toolResult("9", "stray"),
+	}
+
+	paired := pairToolResults(history)
+	got := contents(paired[2:])
+	if strings.Join(got, "|") != "first|"+skippedToolResult+"|third" {
+		t.Fatalf("got %q", got)
+	}
+	for i, call := range calls {
+		if paired[2+i].ToolCallID != call.ID {
+			t.Errorf("result %d answers %q, want %q", i, paired[2+i].ToolCallID, call.ID)
+		}
+	}
+}
+
+func TestCloseDoesNotWaitForeverForTools(t *testing.T) {
+	saved := closeTimeout
+	closeTimeout = 10 * time.Millisecond
+	t.Cleanup(func() { closeTimeout = saved })
+
+	a, _ := scriptedAgent(true)
+	a.toolsReady = make(chan struct{})
+
+	closed := make(chan struct{})
+	go func() {
+		a.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on an MCP server that never finished connecting")
+	}
+}
+
+func TestCloseRemovesSpilledToolOutput(t *testing.T) {
+	a, _ := scriptedAgent(true, openai.ChatCompletionMessage{Content: "the failing line"})
+	a.config.SummarizeToolOutput = true
+
+	output := strings.Repeat("noise\n", maxToolOutput)
+	limited := a.limitToolOutput(context.Background(), "why did it fail", "build", output)
+	_, rest, ok := strings.Cut(limited, "saved at ")
+	if !ok {
+		t.Fatalf("the result does not name the saved file: %q", limited)
+	}
+	path, _, _ := strings.Cut(rest, ";")
+	if data, err := os.ReadFile(path); err != nil || string(data) != output {
+		t.Fatalf("the full output was not saved to %s: %v", path, err)
+	}
+
+	a.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after Close: %v", path, err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Errorf("the session output directory still exists after Close: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yuriiter/ai/pkg/tools"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	readFileMaxLines = 400
+	readFileMaxBytes = 32 * 1024
+	redactedValue    = "[REDACTED]"
+)
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b((?:bearer|basic)\s+)[A-Za-z0-9._~+/=-]{8,}`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|access[_-]?key|secret|token|passw(?:or)?d|authorization)["']?\s*[:=]\s*["']?)[^\s"',;]{4,}`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{30,}`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`),
+}
+
+var secretEnvName = regexp.MustCompile(`(?i)(key|token|secret|passw|credential|auth)`)
+
+func Redact(text string, secrets ...string) string {
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if secretEnvName.MatchString(name) {
+			secrets = append(secrets, value)
+		}
+	}
+	for _, secret := range secrets {
+		if len(secret) >= 8 {
+			text = strings.ReplaceAll(text, secret, redactedValue)
+		}
+	}
+
+	for _, re := range secretPatterns {
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			if sub := re.FindStringSubmatch(match); len(sub) > 1 {
+				return sub[1] + redactedValue
+			}
+			return redactedValue
+		})
+	}
+	return text
+}
+
+func RegisterFileTools(reg *tools.Registry, root string) {
+	readOnly := true
+
+	reg.RegisterInternal(openai.FunctionDefinition{
+		Name:        "read_file",
+		Description: fmt.Sprintf("Read a text file under %s. Returns numbered lines; use start_line and end_line for long files.", root),
+		Parameters: json.RawMessage(`{"type": "object", "properties": {` +
+			`"path": {"type": "string", "description": "File path, relative to the working directory"}, ` +
+			`"start_line": {"type": "integer", "description": "First line to return (default 1)"}, ` +
+			`"end_line": {"type": "integer", "description": "Last line to return"}}, "required": ["path"]}`),
+	}, tools.Annotations{ReadOnlyHint: &readOnly}, func(args string) (string, error) {
+		var params struct {
+			Path      string `json:"path"`
+			StartLine int    `json:"start_line"`
+			EndLine   int    `json:"end_line"`
+		}
+		if err := json.Unmarshal([]byte(args), &params); err != nil {
+			return "", fmt.Errorf("%w: %v", tools.ErrInvalidArguments, err)
+		}
+		path, err := resolveUnder(root, params.Path)
+		if err != nil {
+			return "", err
+		}
+		return readFileLines(path, params.StartLine, params.EndLine)
+	})
+}
+
+func resolveUnder(root, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: path is required", tools.ErrInvalidArguments)
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	base, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside %s", name, root)
+	}
+	return resolved, nil
+}
+
+func readFileLines(path string, start, end int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	start = max(start, 1)
+	if end <= 0 || end-start >= readFileMaxLines {
+		end = start + readFileMaxLines - 1
+	}
+
+	var sb strings.Builder
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		if n < start {
+			continue
+		}
+		if n > end || sb.Len() >= readFileMaxBytes {
+			fmt.Fprintf(&sb, "[truncated at line %d; request later lines with start_line]\n", n-1)
+			break
+		}
+		fmt.Fprintf(&sb, "%d\t%s\n", n, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if sb.Len() == 0 {
+		return fmt.Sprintf("%s has %d lines.", path, n), nil
+	}
+	return Redact(sb.String()), nil
+}
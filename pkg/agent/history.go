@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func (a *Agent) LoadHistory(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var messages []openai.ChatCompletionMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("invalid history file %s: expected a JSON array of messages: %w", filename, err)
+	}
+	for i, msg := range messages {
+		switch msg.Role {
+		case openai.ChatMessageRoleSystem, openai.ChatMessageRoleUser, openai.ChatMessageRoleAssistant:
+		case openai.ChatMessageRoleTool:
+			if msg.ToolCallID == "" {
+				return fmt.Errorf("invalid history file %s: message %d has role tool but no tool_call_id", filename, i+1)
+			}
+		default:
+			return fmt.Errorf("invalid history file %s: message %d has unknown role %q", filename, i+1, msg.Role)
+		}
+	}
+
+	if len(messages) > 0 && messages[0].Role == openai.ChatMessageRoleSystem {
+		a.history = messages
+		return nil
+	}
+	var history []openai.ChatCompletionMessage
+	if len(a.history) > 0 && a.history[0].Role == openai.ChatMessageRoleSystem {
+		history = append(history, a.history[0])
+	}
+	a.history = append(history, messages...)
+	return nil
+}
+
+func (a *Agent) History() []openai.ChatCompletionMessage {
+	return append([]openai.ChatCompletionMessage(nil), a.history...)
+}
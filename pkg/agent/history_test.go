@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/tokenizer"
+)
+
+func message(role, content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: role, Content: content}
+}
+
+func historyAgent(budget int, history ...openai.ChatCompletionMessage) *Agent {
+	return &Agent{
+		config:    config.Config{MaxContextTokens: budget},
+		history:   history,
+		tokenizer: tokenizer.Heuristic{},
+	}
+}
+
+func contents(history []openai.ChatCompletionMessage) []string {
+	var out []string
+	for _, msg := range history {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func TestPruneHistory(t *testing.T) {
+	system := message(openai.ChatMessageRoleSystem, "You are a helpful assistant.")
+	first := message(openai.ChatMessageRoleUser, strings.Repeat("first question ", 20))
+	reply := message(openai.ChatMessageRoleAssistant, strings.Repeat("first answer ", 20))
+	second := message(openai.ChatMessageRoleUser, "second question")
+	full := []openai.ChatCompletionMessage{system, first, reply, second}
+	size := tokenizer.CountTokens(tokenizer.Heuristic{}, full)
+
+	tests := []struct {
+		name    string
+		budget  int
+		history []openai.ChatCompletionMessage
+		want    []openai.ChatCompletionMessage
+	}{
+		{"exactly at the budget", size, full, full},
+		{"one token over", size - 1, full, []openai.ChatCompletionMessage{system, reply, second}},
+		{"keeps a single oversized message", 10, []openai.ChatCompletionMessage{system, first}, []openai.ChatCompletionMessage{system, first}},
+		{"keeps the system prompt", 20, full, []openai.ChatCompletionMessage{system, second}},
+		{"no system prompt", 20, full[1:], []openai.ChatCompletionMessage{second}},
+		{"no budget", 0, full, full},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := historyAgent(tt.budget, append([]openai.ChatCompletionMessage(nil), tt.history...)...)
+			a.pruneHistory()
+			if got, want := contents(a.history), contents(tt.want); strings.Join(got, "|") != strings.Join(want, "|") {
+				t.Fatalf("got %d messages %q, want %d %q", len(got), got, len(want), want)
+			}
+		})
+	}
+}
+
+func TestPruneHistoryDropsOrphanedToolResults(t *testing.T) {
+	call := openai.ChatCompletionMessage{
+		Role:      openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{{ID: "1", Function: openai.FunctionCall{Name: "read_file", Arguments: "{}"}}},
+	}
+	result := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, ToolCallID: "1", Content: strings.Repeat("file contents ", 50)}
+	a := historyAgent(40,
+		message(openai.ChatMessageRoleSystem, "system"),
+		call, result,
+		message(openai.ChatMessageRoleAssistant, "done"),
+		message(openai.ChatMessageRoleUser, "next"),
+	)
+	a.pruneHistory()
+	for _, msg := range a.history {
+		if msg.Role == openai.ChatMessageRoleTool || len(msg.ToolCalls) > 0 {
+			t.Fatalf("tool call or result left without its pair: %+v", contents(a.history))
+		}
+	}
+	if a.history[0].Role != openai.ChatMessageRoleSystem {
+		t.Fatalf("system prompt dropped: %q", contents(a.history))
+	}
+}
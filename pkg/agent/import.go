@@ -0,0 +1,447 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/tokenizer"
+	"github.com/yuriiter/ai/pkg/ui"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+var ImportFormats = []string{"chatgpt", "claude", "openai-jsonl"}
+
+type ImportedConversation struct {
+	Title           string
+	Updated         time.Time
+	Messages        []openai.ChatCompletionMessage
+	SkippedMessages int
+	DroppedParts    int
+}
+
+func ParseExport(data []byte, format string) ([]ImportedConversation, error) {
+	var convs []ImportedConversation
+	var err error
+	switch format {
+	case "chatgpt":
+		convs, err = parseChatGPTExport(data)
+	case "claude":
+		convs, err = parseClaudeExport(data)
+	case "openai-jsonl":
+		convs, err = parseOpenAIJSONL(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected %s)", format, strings.Join(ImportFormats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s export: %w", format, err)
+	}
+	if len(convs) == 0 {
+		return nil, fmt.Errorf("the %s export contains no conversations", format)
+	}
+	return convs, nil
+}
+
+func SelectConversation(convs []ImportedConversation, query string) (ImportedConversation, error) {
+	if query == "" {
+		latest := 0
+		for i, c := range convs {
+			if c.Updated.After(convs[latest].Updated) {
+				latest = i
+			}
+		}
+		return convs[latest], nil
+	}
+	if n, err := strconv.Atoi(query); err == nil {
+		if n < 1 || n > len(convs) {
+			return ImportedConversation{}, fmt.Errorf("conversation %d does not exist (the export has %d)", n, len(convs))
+		}
+		return convs[n-1], nil
+	}
+
+	var matches []ImportedConversation
+	for _, c := range convs {
+		if strings.EqualFold(c.Title, query) {
+			return c, nil
+		}
+		if strings.Contains(strings.ToLower(c.Title), strings.ToLower(query)) {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return ImportedConversation{}, fmt.Errorf("no conversation titled %q", query)
+	case 1:
+		return matches[0], nil
+	}
+	var titles []string
+	for _, m := range matches {
+		titles = append(titles, strconv.Quote(m.Title))
+	}
+	return ImportedConversation{}, fmt.Errorf("%q matches %d conversations: %s", query, len(matches), strings.Join(titles, ", "))
+}
+
+func (c *ImportedConversation) add(role, text string, dropped int) {
+	c.DroppedParts += dropped
+	text = strings.TrimSpace(text)
+	if text == "" {
+		if dropped > 0 {
+			c.SkippedMessages++
+		}
+		return
+	}
+	c.Messages = append(c.Messages, openai.ChatCompletionMessage{Role: role, Content: text})
+}
+
+func (c *ImportedConversation) skip() {
+	c.SkippedMessages++
+}
+
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	UpdateTime  float64                `json:"update_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		Content struct {
+			ContentType string            `json:"content_type"`
+			Parts       []json.RawMessage `json:"parts"`
+			Text        string            `json:"text"`
+		} `json:"content"`
+		Metadata struct {
+			Hidden bool `json:"is_visually_hidden_from_conversation"`
+		} `json:"metadata"`
+	} `json:"message"`
+}
+
+func parseChatGPTExport(data []byte) ([]ImportedConversation, error) {
+	var raw []chatGPTConversation
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var single chatGPTConversation
+		if json.Unmarshal(data, &single) != nil || single.Mapping == nil {
+			return nil, err
+		}
+		raw = []chatGPTConversation{single}
+	}
+
+	var convs []ImportedConversation
+	for _, rc := range raw {
+		conv := ImportedConversation{Title: rc.Title, Updated: time.Unix(int64(rc.UpdateTime), 0)}
+
+		var path []chatGPTNode
+		seen := make(map[string]bool)
+		for id := rc.CurrentNode; id != "" && !seen[id]; id = rc.Mapping[id].Parent {
+			seen[id] = true
+			node, ok := rc.Mapping[id]
+			if !ok {
+				break
+			}
+			path = append(path, node)
+		}
+
+		for i := len(path) - 1; i >= 0; i-- {
+			msg := path[i].Message
+			if msg == nil || msg.Metadata.Hidden {
+				continue
+			}
+			role := msg.Author.Role
+			switch role {
+			case openai.ChatMessageRoleUser, openai.ChatMessageRoleAssistant, openai.ChatMessageRoleSystem:
+			default:
+				if len(msg.Content.Parts) > 0 || msg.Content.Text != "" {
+					conv.skip()
+				}
+				continue
+			}
+
+			switch msg.Content.ContentType {
+			case "text", "multimodal_text", "":
+				var texts []string
+				dropped := 0
+				for _, part := range msg.Content.Parts {
+					var s string
+					if json.Unmarshal(part, &s) == nil {
+						texts = append(texts, s)
+					} else {
+						dropped++
+					}
+				}
+				conv.add(role, strings.Join(texts, "\n\n"), dropped)
+			case "code":
+				conv.add(role, "```\n"+msg.Content.Text+"\n```", 0)
+			default:
+				conv.skip()
+			}
+		}
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+type claudeConversation struct {
+	Name      string `json:"name"`
+	UpdatedAt string `json:"updated_at"`
+	Messages  []struct {
+		Sender  string `json:"sender"`
+		Text    string `json:"text"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Attachments []struct {
+			FileName         string `json:"file_name"`
+			ExtractedContent string `json:"extracted_content"`
+		} `json:"attachments"`
+		Files []json.RawMessage `json:"files"`
+	} `json:"chat_messages"`
+}
+
+func parseClaudeExport(data []byte) ([]ImportedConversation, error) {
+	var raw []claudeConversation
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var single claudeConversation
+		if json.Unmarshal(data, &single) != nil || single.Messages == nil {
+			return nil, err
+		}
+		raw = []claudeConversation{single}
+	}
+
+	var convs []ImportedConversation
+	for _, rc := range raw {
+		updated, _ := time.Parse(time.RFC3339Nano, rc.UpdatedAt)
+		conv := ImportedConversation{Title: rc.Name, Updated: updated}
+		for _, msg := range rc.Messages {
+			var role string
+			switch msg.Sender {
+			case "human", "user":
+				role = openai.ChatMessageRoleUser
+			case "assistant":
+				role = openai.ChatMessageRoleAssistant
+			default:
+				conv.skip()
+				continue
+			}
+
+			var texts []string
+			dropped := len(msg.Files)
+			for _, part := range msg.Content {
+				if part.Type == "text" {
+					texts = append(texts, part.Text)
+				} else {
+					dropped++
+				}
+			}
+			if len(msg.Content) == 0 {
+				texts = append(texts, msg.Text)
+			}
+			for _, att := range msg.Attachments {
+				if att.ExtractedContent == "" {
+					dropped++
+					continue
+				}
+				texts = append(texts, fmt.Sprintf("--- ATTACHMENT: %s ---\n%s", att.FileName, att.ExtractedContent))
+			}
+			conv.add(role, strings.Join(texts, "\n\n"), dropped)
+		}
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+type jsonlMessage struct {
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	ToolCalls json.RawMessage `json:"tool_calls"`
+}
+
+func parseOpenAIJSONL(data []byte) ([]ImportedConversation, error) {
+	var convs []ImportedConversation
+	var loose ImportedConversation
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry struct {
+			jsonlMessage
+			Messages []jsonlMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if entry.Messages != nil {
+			conv := ImportedConversation{Title: fmt.Sprintf("conversation %d", len(convs)+1)}
+			for _, msg := range entry.Messages {
+				conv.addJSONL(msg)
+			}
+			convs = append(convs, conv)
+			continue
+		}
+		if entry.Role == "" {
+			return nil, fmt.Errorf("line %d: expected a message with a role or an object with messages", n)
+		}
+		loose.addJSONL(entry.jsonlMessage)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(loose.Messages) > 0 || loose.SkippedMessages > 0 {
+		loose.Title = "conversation"
+		convs = append(convs, loose)
+	}
+	return convs, nil
+}
+
+func (c *ImportedConversation) addJSONL(msg jsonlMessage) {
+	switch msg.Role {
+	case openai.ChatMessageRoleUser, openai.ChatMessageRoleAssistant, openai.ChatMessageRoleSystem, openai.ChatMessageRoleDeveloper:
+	default:
+		c.skip()
+		return
+	}
+	role := msg.Role
+	if role == openai.ChatMessageRoleDeveloper {
+		role = openai.ChatMessageRoleSystem
+	}
+
+	var text string
+	if json.Unmarshal(msg.Content, &text) == nil {
+		dropped := 0
+		if text == "" && len(msg.ToolCalls) > 0 && string(msg.ToolCalls) != "null" {
+			dropped = 1
+		}
+		c.add(role, text, dropped)
+		return
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(msg.Content, &parts); err != nil {
+		c.skip()
+		return
+	}
+	var texts []string
+	dropped := 0
+	for _, part := range parts {
+		if part.Type == "text" || part.Type == "input_text" || part.Type == "output_text" {
+			texts = append(texts, part.Text)
+		} else {
+			dropped++
+		}
+	}
+	c.add(role, strings.Join(texts, "\n\n"), dropped)
+}
+
+func (a *Agent) CompactHistory(ctx context.Context, messages []openai.ChatCompletionMessage, budget int) ([]openai.ChatCompletionMessage, int, error) {
+	t := a.tokens()
+	if budget <= 0 || tokenizer.CountTokens(t, messages) <= budget {
+		return messages, 0, nil
+	}
+
+	var head []openai.ChatCompletionMessage
+	rest := messages
+	if len(rest) > 0 && rest[0].Role == openai.ChatMessageRoleSystem {
+		head, rest = rest[:1], rest[1:]
+	}
+
+	keep := len(rest)
+	recent := 0
+	for keep > 0 {
+		cost := tokenizer.CountTokens(t, rest[keep-1:keep])
+		if recent+cost > budget/2 {
+			break
+		}
+		recent += cost
+		keep--
+	}
+	if keep == len(rest) && keep > 0 {
+		keep--
+	}
+	older := rest[:keep]
+	if len(older) == 0 {
+		return messages, 0, nil
+	}
+
+	var chunks []string
+	var sb strings.Builder
+	for _, msg := range older {
+		entry := fmt.Sprintf("%s: %s\n\n", msg.Role, msg.Content)
+		if sb.Len() > 0 && sb.Len()+len(entry) > maxSummaryInputSize {
+			chunks = append(chunks, sb.String())
+			sb.Reset()
+		}
+		sb.WriteString(entry)
+	}
+	chunks = append(chunks, sb.String())
+
+	var summaries []string
+	for i, chunk := range chunks {
+		ui.SetProgress(fmt.Sprintf("Summarizing earlier messages (%d/%d)", i+1, len(chunks)))
+		summary, err := a.summarizeConversation(ctx, chunk)
+		if err != nil {
+			ui.ClearProgress()
+			return nil, 0, err
+		}
+		summaries = append(summaries, summary)
+	}
+	ui.ClearProgress()
+
+	compacted := append([]openai.ChatCompletionMessage(nil), head...)
+	compacted = append(compacted, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: fmt.Sprintf("Summary of the earlier part of this conversation (%d messages):\n\n%s", len(older), strings.Join(summaries, "\n\n")),
+	})
+	compacted = append(compacted, rest[keep:]...)
+	return compacted, len(older), nil
+}
+
+func (a *Agent) summarizeConversation(ctx context.Context, transcript string) (string, error) {
+	model := a.config.SummaryModel
+	if model == "" {
+		model = a.config.Model
+	}
+	resp, err := a.chatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: a.instructionRole(),
+				Content: "You compact chat transcripts so the conversation can continue without them. Summarize what the user asked for, " +
+					"the decisions and answers reached, and any facts, names, code, or open questions a later reply may need. Output only the summary.",
+			},
+			{Role: openai.ChatMessageRoleUser, Content: transcript},
+		},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", apiError(err)
+	}
+	a.recordUsage(model, resp.Usage)
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("api returned empty response (no choices)")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+func (a *Agent) SetHistory(messages []openai.ChatCompletionMessage) {
+	a.history = append([]openai.ChatCompletionMessage(nil), messages...)
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestImportRoundTrip(t *testing.T) {
+	tests := []struct {
+		file    string
+		format  string
+		want    []openai.ChatCompletionMessage
+		skipped int
+		dropped int
+	}{
+		{"chatgpt.json", "chatgpt", []openai.ChatCompletionMessage{
+			message(openai.ChatMessageRoleUser, "How should I retry failed uploads?"),
+			message(openai.ChatMessageRoleAssistant, "Use exponential backoff with jitter."),
+			message(openai.ChatMessageRoleUser, "Show me a snippet."),
+			message(openai.ChatMessageRoleAssistant, "```\nfor i := 0; i < 5; i++ {\n\tsleep(backoff(i))\n}\n```"),
+		}, 1, 1},
+		{"claude.json", "claude", []openai.ChatCompletionMessage{
+			message(openai.ChatMessageRoleUser, "What does BPE stand for?\n\n--- ATTACHMENT: notes.txt ---\nbyte pair encoding notes"),
+			message(openai.ChatMessageRoleAssistant, "Byte pair encoding."),
+			message(openai.ChatMessageRoleUser, "Thanks!"),
+		}, 1, 3},
+		{"openai.jsonl", "openai-jsonl", []openai.ChatCompletionMessage{
+			message(openai.ChatMessageRoleSystem, "You are terse."),
+			message(openai.ChatMessageRoleUser, "Summarize this"),
+			message(openai.ChatMessageRoleAssistant, "It is a short file."),
+		}, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "import", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			convs, err := ParseExport(data, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conv, err := SelectConversation(convs, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertMessages(t, "import", conv.Messages, tt.want)
+			if conv.SkippedMessages != tt.skipped || conv.DroppedParts != tt.dropped {
+				t.Errorf("skipped %d messages and dropped %d parts, want %d and %d", conv.SkippedMessages, conv.DroppedParts, tt.skipped, tt.dropped)
+			}
+
+			session := filepath.Join(t.TempDir(), "session.md")
+			a := &Agent{}
+			a.SetHistory(conv.Messages)
+			if err := a.SaveSession(session); err != nil {
+				t.Fatal(err)
+			}
+			loaded := &Agent{}
+			if err := loaded.LoadSession(session); err != nil {
+				t.Fatal(err)
+			}
+			assertMessages(t, "session", loaded.history, tt.want)
+
+			line, err := json.Marshal(map[string][]openai.ChatCompletionMessage{"messages": conv.Messages})
+			if err != nil {
+				t.Fatal(err)
+			}
+			reimported, err := ParseExport(append(line, '\n'), "openai-jsonl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertMessages(t, "jsonl", reimported[0].Messages, tt.want)
+		})
+	}
+}
+
+func assertMessages(t *testing.T, stage string, got, want []openai.ChatCompletionMessage) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d messages %q, want %d", stage, len(got), contents(got), len(want))
+	}
+	for i := range want {
+		if got[i].Role != want[i].Role || got[i].Content != want[i].Content {
+			t.Errorf("%s: message %d is %s %q, want %s %q", stage, i, got[i].Role, got[i].Content, want[i].Role, want[i].Content)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type dataAttachment struct {
+	mime string
+	data []byte
+}
+
+func (d dataAttachment) dataURI() string {
+	return fmt.Sprintf("data:%s;base64,%s", d.mime, base64.StdEncoding.EncodeToString(d.data))
+}
+
+func (a *Agent) AttachData(kind string, data []byte) error {
+	mime, err := detectDataType(kind, data)
+	if err != nil {
+		return err
+	}
+
+	category := mime[:strings.Index(mime, "/")]
+	patterns, key := a.config.VisionModels, "vision_models"
+	if category == "audio" {
+		patterns, key = a.config.AudioModels, "audio_models"
+	}
+	if !matchModel(patterns, a.config.Model) {
+		return fmt.Errorf("model %s does not accept %s input (it matches no pattern in %s; add it to the config file if it does)", a.config.Model, category, key)
+	}
+
+	a.pendingData = append(a.pendingData, dataAttachment{mime: mime, data: data})
+	return nil
+}
+
+func (a *Agent) takePendingData() []dataAttachment {
+	pending := a.pendingData
+	a.pendingData = nil
+	return pending
+}
+
+func detectDataType(kind string, data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("stdin is empty")
+	}
+	detected, _, _ := strings.Cut(http.DetectContentType(data), ";")
+
+	mime := strings.ToLower(kind)
+	switch mime {
+	case "image", "audio":
+		if !strings.HasPrefix(detected, mime+"/") {
+			example := "image/png"
+			if mime == "audio" {
+				example = "audio/mpeg"
+			}
+			return "", fmt.Errorf("stdin does not look like %s data (detected %s); pass the exact type, e.g. --stdin-type %s", mime, detected, example)
+		}
+		mime = detected
+	default:
+		if !strings.HasPrefix(mime, "image/") && !strings.HasPrefix(mime, "audio/") {
+			return "", fmt.Errorf("unsupported stdin type %q (expected text, image, audio, or an image/* or audio/* MIME type)", kind)
+		}
+	}
+
+	if strings.HasPrefix(mime, "audio/") {
+		if _, err := audioFormat(mime); err != nil {
+			return "", err
+		}
+	}
+	return mime, nil
+}
+
+func audioFormat(mime string) (string, error) {
+	switch mime {
+	case "audio/wav", "audio/wave", "audio/x-wav":
+		return "wav", nil
+	case "audio/mpeg", "audio/mp3":
+		return "mp3", nil
+	}
+	return "", fmt.Errorf("unsupported audio format %s (chat models accept wav or mp3)", mime)
+}
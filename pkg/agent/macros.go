@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const maxIncludeDepth = 10
+
+var macroRegex = regexp.MustCompile(`(^|[^\w@])@(include:(\S+)|env:([A-Za-z_][A-Za-z0-9_]*)|date\b|cwd\b)`)
+
+type macroExpander struct {
+	root  string
+	stack []string
+}
+
+func expandMacros(prompt string) (string, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	e := &macroExpander{root: root}
+	return e.expand(prompt, root)
+}
+
+func (e *macroExpander) expand(text string, dir string) (string, error) {
+	var firstErr error
+	out := macroRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if firstErr != nil {
+			return match
+		}
+		groups := macroRegex.FindStringSubmatch(match)
+		lead, macro := groups[1], groups[2]
+
+		switch {
+		case strings.HasPrefix(macro, "include:"):
+			ref := strings.TrimRight(groups[3], ".,;:!?)]}'\"")
+			trailing := groups[3][len(ref):]
+			content, err := e.include(ref, dir)
+			if err != nil {
+				firstErr = err
+				return match
+			}
+			return lead + content + trailing
+		case strings.HasPrefix(macro, "env:"):
+			value, ok := os.LookupEnv(groups[4])
+			if !ok {
+				firstErr = fmt.Errorf("@env:%s: environment variable is not set", groups[4])
+				return match
+			}
+			return lead + value
+		case macro == "date":
+			return lead + time.Now().Format("2006-01-02")
+		case macro == "cwd":
+			return lead + e.root
+		}
+		return match
+	})
+	return out, firstErr
+}
+
+func (e *macroExpander) include(ref string, dir string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("@include: missing file name")
+	}
+
+	path := ref
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("@include:%s: %w", ref, err)
+	}
+	if rel, err := filepath.Rel(e.root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("@include:%s: %s is outside the working directory", ref, path)
+	}
+
+	for _, p := range e.stack {
+		if p == path {
+			return "", fmt.Errorf("@include:%s: include cycle (%s -> %s)", ref, strings.Join(e.stack, " -> "), path)
+		}
+	}
+	if len(e.stack) >= maxIncludeDepth {
+		return "", fmt.Errorf("@include:%s: includes are nested more than %d levels deep", ref, maxIncludeDepth)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("@include:%s: %w", ref, err)
+	}
+
+	e.stack = append(e.stack, path)
+	defer func() { e.stack = e.stack[:len(e.stack)-1] }()
+
+	content, err := e.expand(strings.TrimSuffix(string(data), "\n"), filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return content, nil
+}
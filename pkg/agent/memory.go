@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yuriiter/ai/pkg/memory"
+	"github.com/yuriiter/ai/pkg/tools"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const memoryContextBudget = 4000
+
+func memoryContext(path string) string {
+	content, err := memory.Read(path)
+	if err != nil || content == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nProject memory from %s (notes saved in earlier sessions; use append_memory to add new ones):\n%s",
+		path, memory.Truncate(content, memoryContextBudget))
+}
+
+func registerMemoryTools(reg *tools.Registry, path string) {
+	readOnly, notReadOnly, notDestructive := true, false, false
+
+	reg.RegisterInternal(openai.FunctionDefinition{
+		Name:        "read_memory",
+		Description: "Read the project memory file with notes saved in earlier sessions.",
+		Parameters:  json.RawMessage(`{"type": "object", "properties": {}}`),
+	}, tools.Annotations{ReadOnlyHint: &readOnly}, func(args string) (string, error) {
+		content, err := memory.Read(path)
+		if err != nil {
+			return "", err
+		}
+		if content == "" {
+			return "The project memory is empty.", nil
+		}
+		return content, nil
+	})
+
+	reg.RegisterInternal(openai.FunctionDefinition{
+		Name:        "append_memory",
+		Description: "Append a note to the project memory file so it is available in future sessions. Keep notes short and factual.",
+		Parameters:  json.RawMessage(`{"type": "object", "properties": {"note": {"type": "string", "description": "The note to save"}}, "required": ["note"]}`),
+	}, tools.Annotations{ReadOnlyHint: &notReadOnly, DestructiveHint: &notDestructive}, func(args string) (string, error) {
+		var params struct {
+			Note string `json:"note"`
+		}
+		if err := json.Unmarshal([]byte(args), &params); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		if err := memory.Append(path, params.Note); err != nil {
+			return "", err
+		}
+		return "Note saved to " + path, nil
+	})
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/tokenizer"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+type rateLimits struct {
+	mu                sync.Mutex
+	remainingRequests int
+	remainingTokens   int
+	requestsReset     time.Time
+	tokensReset       time.Time
+}
+
+func newRateLimits() *rateLimits {
+	return &rateLimits{remainingRequests: -1, remainingTokens: -1}
+}
+
+func (r *rateLimits) update(h http.Header, now time.Time) (string, bool) {
+	requests, hasRequests := headerInt(h, "x-ratelimit-remaining-requests")
+	tokens, hasTokens := headerInt(h, "x-ratelimit-remaining-tokens")
+	if !hasRequests && !hasTokens {
+		return "", false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var parts []string
+	if hasRequests {
+		r.remainingRequests = requests
+		r.requestsReset = resetTime(h.Get("x-ratelimit-reset-requests"), now)
+		parts = append(parts, fmt.Sprintf("%d requests", requests))
+	}
+	if hasTokens {
+		r.remainingTokens = tokens
+		r.tokensReset = resetTime(h.Get("x-ratelimit-reset-tokens"), now)
+		parts = append(parts, fmt.Sprintf("%d tokens", tokens))
+	}
+	return strings.Join(parts, ", ") + " remaining", true
+}
+
+func (r *rateLimits) reserve(tokens int, now time.Time) (time.Duration, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.remainingRequests == 0 && r.requestsReset.After(now) {
+		return r.requestsReset.Sub(now), "request limit"
+	}
+	if r.remainingTokens >= 0 && tokens > r.remainingTokens && r.tokensReset.After(now) {
+		return r.tokensReset.Sub(now), fmt.Sprintf("token limit (%d left, about %d needed)", r.remainingTokens, tokens)
+	}
+
+	if r.remainingRequests > 0 {
+		r.remainingRequests--
+	}
+	if r.remainingTokens > 0 {
+		r.remainingTokens = max(r.remainingTokens-tokens, 0)
+	}
+	return 0, ""
+}
+
+func (r *rateLimits) tracksTokens() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.remainingTokens >= 0
+}
+
+func (r *rateLimits) wait(ctx context.Context, estimate func() int) error {
+	tokens := 0
+	if r.tracksTokens() {
+		tokens = estimate()
+	}
+	for {
+		delay, reason := r.reserve(tokens, time.Now())
+		if delay <= 0 {
+			return nil
+		}
+
+		ui.Printf(os.Stderr, ui.ColorBlue, "[Rate limit: %s reached, waiting %s for it to reset]\n", reason, delay.Round(time.Second))
+		deadline := time.Now().Add(delay)
+		ticker := time.NewTicker(time.Second)
+		for remaining := delay; remaining > 0; remaining = time.Until(deadline) {
+			ui.SetProgress(fmt.Sprintf("Rate limit resets in %s", remaining.Round(time.Second)))
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				ui.ClearProgress()
+				return ctx.Err()
+			case <-ticker.C:
+			case <-time.After(remaining):
+			}
+		}
+		ticker.Stop()
+		ui.ClearProgress()
+
+		r.mu.Lock()
+		if !r.requestsReset.After(time.Now()) {
+			r.remainingRequests = -1
+		}
+		if !r.tokensReset.After(time.Now()) {
+			r.remainingTokens = -1
+		}
+		r.mu.Unlock()
+	}
+}
+
+func headerInt(h http.Header, key string) (int, bool) {
+	v, err := strconv.Atoi(strings.TrimSpace(h.Get(key)))
+	return v, err == nil
+}
+
+func resetTime(value string, now time.Time) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d)
+	}
+	secs, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	if secs > 1e9 {
+		return time.Unix(int64(secs), 0)
+	}
+	return now.Add(time.Duration(secs * float64(time.Second)))
+}
+
+func estimateRequestTokens(body []byte) int {
+	var req struct {
+		Model               string                         `json:"model"`
+		Messages            []openai.ChatCompletionMessage `json:"messages"`
+		MaxTokens           int                            `json:"max_tokens"`
+		MaxCompletionTokens int                            `json:"max_completion_tokens"`
+	}
+	reserved := 0
+	if err := json.Unmarshal(body, &req); err == nil {
+		reserved = max(req.MaxTokens, req.MaxCompletionTokens)
+	}
+	if len(req.Messages) == 0 {
+		return tokenizer.Heuristic{}.Count(string(body)) + reserved
+	}
+	return tokenizer.CountTokens(tokenizer.ForModel(req.Model), req.Messages) + reserved
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/yuriiter/ai/pkg/ui"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func (a *Agent) chatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	return a.client.CreateChatCompletion(ctx, a.prepareRequest(req))
+}
+
+func (a *Agent) prepareRequest(req openai.ChatCompletionRequest) openai.ChatCompletionRequest {
+	if a.isReasoningModel(req.Model) {
+		var omitted []string
+		if req.Temperature != 0 {
+			req.Temperature = 0
+			omitted = append(omitted, "temperature")
+		}
+		if req.TopP != 0 {
+			req.TopP = 0
+			omitted = append(omitted, "top_p")
+		}
+		if req.MaxTokens != 0 {
+			req.MaxTokens = 0
+			omitted = append(omitted, "max_tokens")
+		}
+		if len(omitted) > 0 && a.config.Verbose && a.reasoningNoted.CompareAndSwap(false, true) {
+			ui.Printf(os.Stderr, ui.ColorBlue, "[%s is a reasoning model, omitting %s]\n", req.Model, strings.Join(omitted, ", "))
+		}
+	}
+	return req
+}
+
+func (a *Agent) isReasoningModel(model string) bool {
+	return matchModel(a.config.ReasoningModels, model)
+}
+
+func matchModel(patterns []string, model string) bool {
+	name := strings.ToLower(model)
+	base := name[strings.LastIndex(name, "/")+1:]
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type toolCallAccumulator struct {
+	calls   []openai.ToolCall
+	byIndex map[int]int
+}
+
+func newToolCallAccumulator() *toolCallAccumulator {
+	return &toolCallAccumulator{byIndex: make(map[int]int)}
+}
+
+func (acc *toolCallAccumulator) add(delta openai.ToolCall) {
+	pos, ok := -1, false
+	if delta.Index != nil {
+		pos, ok = acc.byIndex[*delta.Index]
+		if ok && delta.ID != "" && acc.calls[pos].ID != "" && acc.calls[pos].ID != delta.ID {
+			ok = false
+		}
+	}
+	if !ok && delta.ID != "" {
+		for i, call := range acc.calls {
+			if call.ID == delta.ID {
+				pos, ok = i, true
+				break
+			}
+		}
+	}
+	if !ok && delta.Index == nil && delta.ID == "" && len(acc.calls) > 0 {
+		pos, ok = len(acc.calls)-1, true
+	}
+	if !ok {
+		acc.calls = append(acc.calls, openai.ToolCall{Type: openai.ToolTypeFunction})
+		pos = len(acc.calls) - 1
+		if delta.Index != nil {
+			acc.byIndex[*delta.Index] = pos
+		}
+	}
+
+	call := &acc.calls[pos]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	if name := delta.Function.Name; name != "" && name != call.Function.Name {
+		call.Function.Name += name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+func (acc *toolCallAccumulator) result() []openai.ToolCall {
+	if len(acc.calls) == 0 {
+		return nil
+	}
+	calls := make([]openai.ToolCall, len(acc.calls))
+	for i, call := range acc.calls {
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", i)
+		}
+		calls[i] = call
+	}
+	return calls
+}
+
+func (a *Agent) streamChatCompletion(ctx context.Context, req openai.ChatCompletionRequest, onContent func(string)) (openai.ChatCompletionResponse, error) {
+	req = a.prepareRequest(req)
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idle := a.config.StreamIdleTimeout
+	var stalled atomic.Bool
+	var watchdog *time.Timer
+	if idle > 0 {
+		watchdog = time.AfterFunc(idle, func() {
+			stalled.Store(true)
+			cancel()
+		})
+		defer watchdog.Stop()
+	}
+	idleErr := func(err error) error {
+		if stalled.Load() {
+			return fmt.Errorf("%w: no data received for %s", ErrStreamIdle, idle)
+		}
+		return err
+	}
+
+	var resp openai.ChatCompletionResponse
+	stream, err := a.client.CreateChatCompletionStream(streamCtx, req)
+	if err != nil {
+		return resp, idleErr(err)
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	tools := newToolCallAccumulator()
+	var finish openai.FinishReason
+	result := func() openai.ChatCompletionResponse {
+		resp.Choices = []openai.ChatCompletionChoice{{
+			Message: openai.ChatCompletionMessage{
+				Role:      openai.ChatMessageRoleAssistant,
+				Content:   content.String(),
+				ToolCalls: tools.result(),
+			},
+			FinishReason: finish,
+		}}
+		return resp
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result(), idleErr(err)
+		}
+		if watchdog != nil {
+			watchdog.Reset(idle)
+		}
+
+		resp.ID, resp.Model = chunk.ID, chunk.Model
+		if chunk.Usage != nil {
+			resp.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				onContent(choice.Delta.Content)
+			}
+			for _, delta := range choice.Delta.ToolCalls {
+				tools.add(delta)
+			}
+			if choice.FinishReason != "" {
+				finish = choice.FinishReason
+			}
+		}
+	}
+	return result(), nil
+}
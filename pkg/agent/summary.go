@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/memory"
+	"github.com/yuriiter/ai/pkg/tools"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func (a *Agent) Summary() []config.Entry {
+	cfg := a.config
+	var entries []config.Entry
+	add := func(key, value string, src config.Source) {
+		entries = append(entries, config.Entry{Key: key, Value: value, Source: src})
+	}
+
+	add("model", cfg.Model, cfg.Source(endpointKey(cfg.Chat.Model, "model")))
+	add("base_url", baseURLHost(cfg.BaseURL), cfg.Source(endpointKey(cfg.Chat.BaseURL, "base_url")))
+	apiKey := "(unset)"
+	if cfg.ApiKey != "" {
+		apiKey = config.MaskSecret(cfg.ApiKey)
+	}
+	add("api_key", apiKey, cfg.Source(endpointKey(cfg.Chat.ApiKey, "api_key")))
+
+	temperature := strconv.FormatFloat(float64(cfg.Temperature), 'g', -1, 32)
+	if a.isReasoningModel(cfg.Model) {
+		temperature += " (omitted for reasoning model)"
+	}
+	add("temperature", temperature, cfg.Source("temperature"))
+	add("max_steps", strconv.Itoa(cfg.MaxSteps), cfg.Source("max_steps"))
+	if len(cfg.ExtraBody) > 0 {
+		extra, _ := json.Marshal(cfg.ExtraBody)
+		add("extra_body", string(extra), cfg.Source("extra_body"))
+	}
+
+	add("system_prompt", a.systemPromptSummary(), a.systemPromptSource())
+
+	mode := "chat"
+	if a.agenticMode {
+		mode = "agentic"
+	}
+	add("mode", mode, cfg.Source("agent"))
+	if a.agenticMode {
+		for _, server := range a.serverToolSummary() {
+			add("mcp", server, cfg.Source("mcp"))
+		}
+		add("memory_file", memory.Path(cfg.MemoryFile), cfg.Source("memory_file"))
+	}
+
+	if len(cfg.RagGlobs) > 0 {
+		key := "rag"
+		target := strings.Join(cfg.RagGlobs, ", ")
+		if cfg.Corpus != "" {
+			key, target = "corpus", cfg.Corpus
+		}
+		add(key, fmt.Sprintf("%s (%s)", target, a.ragCacheStatus()), cfg.Source(key))
+	}
+
+	history := "off"
+	if cfg.RetainHistory {
+		history = "on"
+	}
+	add("history", history, cfg.Source("memory"))
+
+	return entries
+}
+
+func endpointKey(sectionValue, key string) string {
+	if sectionValue != "" {
+		return "chat." + key
+	}
+	return key
+}
+
+func baseURLHost(baseURL string) string {
+	if baseURL == "" {
+		return "api.openai.com"
+	}
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return baseURL
+}
+
+func (a *Agent) systemPromptSummary() string {
+	if len(a.history) == 0 || a.history[0].Role != openai.ChatMessageRoleSystem {
+		return "none"
+	}
+	kind := "built-in"
+	if a.config.SystemInstructions != "" {
+		kind = "custom"
+	}
+	return fmt.Sprintf("%s, %d chars", kind, len(a.history[0].Content))
+}
+
+func (a *Agent) systemPromptSource() config.Source {
+	if a.config.NoSystem {
+		return a.config.Source("no-system")
+	}
+	return a.config.Source("system_instructions")
+}
+
+func (a *Agent) serverToolSummary() []string {
+	select {
+	case <-a.toolsReady:
+	default:
+		summary := make([]string, 0, len(a.mcpServers))
+		for _, server := range a.mcpServers {
+			summary = append(summary, server+": connecting in background")
+		}
+		return summary
+	}
+
+	counts := make(map[string]int)
+	for _, t := range a.Registry.List() {
+		if t.Type == tools.TypeMCP {
+			counts[t.Server]++
+		}
+	}
+	var summary []string
+	for _, server := range a.mcpServers {
+		if server == "" {
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%s: %d tools", server, counts[server]))
+	}
+	return summary
+}
+
+func (a *Agent) ragCacheStatus() string {
+	cachePath, err := a.prepareRAG()
+	if err != nil {
+		return err.Error()
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		return "not indexed"
+	}
+	if valid, reason := a.RagEngine.ValidateCache(cachePath, a.config.RagGlobs); !valid {
+		return "stale: " + reason
+	}
+	return "cache fresh"
+}
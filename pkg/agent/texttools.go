@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type textToolCall struct {
+	Name      string
+	Arguments string
+}
+
+var fencedBlockRegex = regexp.MustCompile("(?s)```(?:tool|tool_call|json)?\\s*\\n(.*?)```")
+
+func withTextToolsPrompt(history []openai.ChatCompletionMessage, tools []openai.Tool, role string) []openai.ChatCompletionMessage {
+	if len(tools) == 0 {
+		return history
+	}
+
+	var sb strings.Builder
+	sb.WriteString("You can call tools. To call a tool, reply with ONLY a fenced block in this exact format:\n")
+	sb.WriteString("```tool\n{\"name\": \"<tool name>\", \"arguments\": {<arguments as JSON>}}\n```\n")
+	sb.WriteString("The tool result will be sent back to you in the next message. Available tools:\n")
+	for _, t := range tools {
+		params, _ := json.Marshal(t.Function.Parameters)
+		sb.WriteString(fmt.Sprintf("- %s: %s\n  parameters: %s\n", t.Function.Name, t.Function.Description, params))
+	}
+
+	return withSystemMessage(history, sb.String(), role)
+}
+
+func parseTextToolCalls(content string, known func(string) bool) []textToolCall {
+	var candidates []string
+	for _, m := range fencedBlockRegex.FindAllStringSubmatch(content, -1) {
+		candidates = append(candidates, m[1])
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, content)
+	}
+
+	var calls []textToolCall
+	for _, c := range candidates {
+		for _, call := range extractJSONToolCalls(c) {
+			if known(call.Name) {
+				calls = append(calls, call)
+			}
+		}
+	}
+	return calls
+}
+
+func extractJSONToolCalls(text string) []textToolCall {
+	var calls []textToolCall
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' {
+			continue
+		}
+
+		dec := json.NewDecoder(strings.NewReader(text[i:]))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			continue
+		}
+
+		if call, ok := toTextToolCall(raw); ok {
+			calls = append(calls, call)
+		}
+		i += int(dec.InputOffset()) - 1
+	}
+	return calls
+}
+
+func toTextToolCall(raw json.RawMessage) (textToolCall, bool) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return textToolCall{}, false
+	}
+
+	var name string
+	for _, key := range []string{"name", "tool", "function"} {
+		if v, ok := obj[key]; ok && json.Unmarshal(v, &name) == nil && name != "" {
+			break
+		}
+	}
+	if name == "" {
+		return textToolCall{}, false
+	}
+
+	args := "{}"
+	for _, key := range []string{"arguments", "parameters", "args"} {
+		v, ok := obj[key]
+		if !ok {
+			continue
+		}
+		var encoded string
+		if json.Unmarshal(v, &encoded) == nil {
+			args = encoded
+		} else {
+			args = string(v)
+		}
+		break
+	}
+
+	return textToolCall{Name: name, Arguments: args}, true
+}
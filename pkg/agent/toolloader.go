@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yuriiter/ai/pkg/tools"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+func (a *Agent) mustWaitForTools() bool {
+	if a.config.WaitForTools || a.config.ToolOnly != "" {
+		return true
+	}
+	switch a.config.ToolChoice {
+	case "", "auto", "none":
+		return false
+	}
+	return true
+}
+
+func (a *Agent) loadTools(mcpServers []string, verbose bool) error {
+	for _, serverCmd := range mcpServers {
+		if serverCmd == "" {
+			continue
+		}
+		if verbose {
+			ui.Printf(os.Stdout, ui.ColorBlue, "Connecting to MCP: %s...\n", serverCmd)
+		}
+		if err := a.Registry.LoadMCPTools(serverCmd); err != nil {
+			return fmt.Errorf("failed to load MCP server '%s': %w", serverCmd, err)
+		}
+	}
+	return nil
+}
+
+func (a *Agent) toolsLoaded() bool {
+	select {
+	case <-a.toolsReady:
+	default:
+		return false
+	}
+
+	if !a.toolsReported {
+		a.toolsReported = true
+		if a.toolsErr != nil {
+			ui.Printf(os.Stdout, ui.ColorRed, "Warning: %v\n", a.toolsErr)
+		}
+		a.reportTools()
+	}
+	return true
+}
+
+func (a *Agent) reportTools() {
+	if names := a.Registry.Names(); len(names) > 0 {
+		ui.Printf(os.Stdout, ui.ColorGreen, "Loaded Tools: %s\n", strings.Join(names, ", "))
+	}
+}
+
+func (a *Agent) needsConfirmation(name string) bool {
+	t, ok := a.Registry.Lookup(name)
+	if !ok {
+		return false
+	}
+	switch {
+	case t.Annotations.ReadOnly():
+		return false
+	case t.Annotations.Destructive():
+		return !a.config.YesDestructive
+	case t.Type == tools.TypeInternal:
+		return !a.config.AssumeYes
+	default:
+		return a.config.ConfirmTools == "always" && !a.config.AssumeYes
+	}
+}
+
+func (a *Agent) confirmTool(name, args string) bool {
+	if !a.needsConfirmation(name) {
+		return true
+	}
+
+	t, _ := a.Registry.Lookup(name)
+	kind := "Tool"
+	if t.Annotations.Destructive() {
+		kind = "Destructive tool"
+	}
+	question := fmt.Sprintf("%s%s %s wants to run with %s. Allow? [y/N] %s", ui.ColorRed, kind, name, args, ui.ColorReset)
+	if !ui.Confirm(question) {
+		ui.Printf(os.Stderr, ui.ColorRed, "[Skipped %s]\n", name)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yuriiter/ai/pkg/tools"
+	"github.com/yuriiter/ai/pkg/ui"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	maxToolOutput       = 10000
+	maxSummaryInputSize = 200000
+)
+
+func (a *Agent) limitToolOutput(ctx context.Context, goal, name, output string) string {
+	if len(output) <= maxToolOutput {
+		return output
+	}
+	if !a.config.SummarizeToolOutput {
+		return output[:maxToolOutput] + "\n...(truncated output)"
+	}
+
+	path, err := saveToolOutput(name, output)
+	if err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Warning: failed to save full output of %s: %v\n", name, err)
+		return output[:maxToolOutput] + "\n...(truncated output)"
+	}
+
+	summary, err := a.summarizeToolOutput(ctx, goal, name, output)
+	if err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "Warning: failed to summarize output of %s: %v\n", name, err)
+		return fmt.Sprintf("%s\n...(truncated output, the full output is saved at %s)", output[:maxToolOutput], path)
+	}
+
+	return fmt.Sprintf("[This is a summary of a %d-byte output, focused on the user's request. The full output is saved at %s; use other tools to search it if details are missing.]\n\n%s",
+		len(output), path, summary)
+}
+
+func (a *Agent) summarizeToolOutput(ctx context.Context, goal, name, output string) (string, error) {
+	if len(output) > maxSummaryInputSize {
+		output = output[:maxSummaryInputSize] + "\n...(truncated output)"
+	}
+
+	model := a.config.SummaryModel
+	if model == "" {
+		model = a.config.Model
+	}
+	req := openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: a.instructionRole(),
+				Content: "You condense tool output for another assistant. Extract the parts that matter for the user's request: " +
+					"errors, failures, warnings, and the lines around them, quoted verbatim where possible. Output only the extracted content.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("User request: %s\n\nOutput of tool %s:\n%s", goal, name, output),
+			},
+		},
+		Temperature: 0.2,
+	}
+
+	resp, err := a.chatCompletion(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("api returned empty response (no choices)")
+	}
+
+	a.summaryUsage.PromptTokens += resp.Usage.PromptTokens
+	a.summaryUsage.CompletionTokens += resp.Usage.CompletionTokens
+	a.summaryUsage.TotalTokens += resp.Usage.TotalTokens
+	a.recordUsage(model, resp.Usage)
+	ui.Printf(os.Stderr, ui.ColorBlue, "[Summarized %d bytes of %s output: %d prompt + %d completion tokens, %d total this session]\n",
+		len(output), name, resp.Usage.PromptTokens, resp.Usage.CompletionTokens, a.summaryUsage.TotalTokens)
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+func saveToolOutput(name, output string) (string, error) {
+	dir, err := tools.OutputDir()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(dir, tools.SafeFileName(name)+"-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(output); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
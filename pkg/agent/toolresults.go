@@ -0,0 +1,78 @@
+package agent
+
+import (
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const skippedToolResult = "Tool execution skipped"
+
+func toolResult(id, output string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		Content:    output,
+		ToolCallID: id,
+	}
+}
+
+func (a *Agent) appendToolResults(calls []openai.ToolCall, results map[string]openai.ChatCompletionMessage) {
+	for _, call := range calls {
+		result, ok := results[call.ID]
+		if !ok {
+			result = toolResult(call.ID, skippedToolResult)
+		}
+		a.history = append(a.history, result)
+	}
+}
+
+func pairToolResults(history []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	if !needsPairing(history) {
+		return history
+	}
+
+	paired := make([]openai.ChatCompletionMessage, 0, len(history))
+	for i := 0; i < len(history); i++ {
+		msg := history[i]
+		if msg.Role == openai.ChatMessageRoleTool {
+			continue
+		}
+		paired = append(paired, msg)
+		if msg.Role != openai.ChatMessageRoleAssistant || len(msg.ToolCalls) == 0 {
+			continue
+		}
+
+		results := make(map[string]openai.ChatCompletionMessage)
+		for i+1 < len(history) && history[i+1].Role == openai.ChatMessageRoleTool {
+			i++
+			if _, seen := results[history[i].ToolCallID]; !seen {
+				results[history[i].ToolCallID] = history[i]
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			result, ok := results[call.ID]
+			if !ok {
+				result = toolResult(call.ID, skippedToolResult)
+			}
+			paired = append(paired, result)
+		}
+	}
+	return paired
+}
+
+func needsPairing(history []openai.ChatCompletionMessage) bool {
+	for i := 0; i < len(history); i++ {
+		msg := history[i]
+		if msg.Role == openai.ChatMessageRoleTool {
+			return true
+		}
+		if msg.Role != openai.ChatMessageRoleAssistant || len(msg.ToolCalls) == 0 {
+			continue
+		}
+		for _, call := range msg.ToolCalls {
+			i++
+			if i >= len(history) || history[i].Role != openai.ChatMessageRoleTool || history[i].ToolCallID != call.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
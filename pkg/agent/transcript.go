@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type transcriptTurn struct {
+	prompt   string
+	messages []openai.ChatCompletionMessage
+}
+
+func (a *Agent) recordTranscript(prompt string, messages []openai.ChatCompletionMessage) {
+	if len(messages) == 0 {
+		return
+	}
+	a.transcript = append(a.transcript, transcriptTurn{
+		prompt:   prompt,
+		messages: append([]openai.ChatCompletionMessage(nil), messages...),
+	})
+}
+
+func (a *Agent) ExportTranscript(filename string) error {
+	var sb strings.Builder
+	sb.WriteString("# Chat Transcript\n\n")
+	sb.WriteString(fmt.Sprintf("_Model: %s | Exported: %s_\n", a.config.Model, time.Now().Format("2006-01-02 15:04")))
+
+	speaker := "Assistant"
+	if a.config.AssistantName != "" {
+		speaker = a.config.AssistantName
+	}
+
+	for i, turn := range a.transcript {
+		sb.WriteString(fmt.Sprintf("\n## Turn %d\n\n### User\n\n%s\n", i+1, strings.TrimSpace(turn.prompt)))
+
+		toolArgs := make(map[string]openai.ToolCall)
+		for _, msg := range turn.messages[1:] {
+			switch {
+			case msg.Role == openai.ChatMessageRoleAssistant:
+				if content := strings.TrimSpace(msg.Content); content != "" {
+					sb.WriteString(fmt.Sprintf("\n### %s\n\n%s\n", speaker, content))
+				}
+				for _, tc := range msg.ToolCalls {
+					toolArgs[tc.ID] = tc
+				}
+			case msg.Role == openai.ChatMessageRoleTool:
+				tc := toolArgs[msg.ToolCallID]
+				name := tc.Function.Name
+				if name == "" {
+					name = msg.Name
+				}
+				writeToolDetails(&sb, "Tool call: "+name, tc.Function.Arguments, msg.Content)
+			case msg.Role == openai.ChatMessageRoleUser && strings.HasPrefix(msg.Content, "Result of tool "):
+				writeToolDetails(&sb, "Tool results", "", msg.Content)
+			}
+		}
+	}
+
+	return os.WriteFile(filename, []byte(sb.String()), 0644)
+}
+
+func writeToolDetails(sb *strings.Builder, summary, args, result string) {
+	sb.WriteString(fmt.Sprintf("\n<details>\n<summary>%s</summary>\n\n", summary))
+	if args != "" {
+		fence := codeFence(args)
+		sb.WriteString(fmt.Sprintf("**Arguments**\n\n%sjson\n%s\n%s\n\n", fence, args, fence))
+	}
+	fence := codeFence(result)
+	sb.WriteString(fmt.Sprintf("**Result**\n\n%s\n%s\n%s\n\n</details>\n", fence, strings.TrimRight(result, "\n"), fence))
+}
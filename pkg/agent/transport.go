@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+var knownResponseFields = map[string]bool{
+	"id":                    true,
+	"object":                true,
+	"created":               true,
+	"model":                 true,
+	"choices":               true,
+	"usage":                 true,
+	"system_fingerprint":    true,
+	"service_tier":          true,
+	"prompt_filter_results": true,
+}
+
+type requestTransport struct {
+	base      http.RoundTripper
+	extraBody map[string]json.RawMessage
+	debug     bool
+	verbose   bool
+	limits    *rateLimits
+}
+
+func newHTTPClient(extraBody map[string]json.RawMessage, debug, verbose bool) *http.Client {
+	return &http.Client{
+		Transport: &requestTransport{
+			base:      http.DefaultTransport,
+			extraBody: extraBody,
+			debug:     debug,
+			verbose:   verbose,
+			limits:    newRateLimits(),
+		},
+	}
+}
+
+func (t *requestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	isChat := strings.HasSuffix(req.URL.Path, "/chat/completions")
+
+	if isChat && len(t.extraBody) > 0 && req.Body != nil {
+		if err := t.mergeExtraBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	if isChat && req.Body != nil {
+		if err := rewriteAudioParts(req); err != nil {
+			return nil, err
+		}
+	}
+
+	estimate := func() int {
+		if !isChat || req.GetBody == nil {
+			return 0
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return 0
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		return estimateRequestTokens(data)
+	}
+	if err := t.limits.wait(req.Context(), estimate); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if summary, ok := t.limits.update(resp.Header, time.Now()); ok && t.verbose {
+		ui.Printf(os.Stderr, ui.ColorBlue, "[Rate limit: %s]\n", summary)
+	}
+	if !t.debug || !isChat {
+		return resp, nil
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		reportUnknownFields(resp)
+	}
+	return resp, nil
+}
+
+func (t *requestTransport) mergeExtraBody(req *http.Request) error {
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return fmt.Errorf("failed to decode request body for extra fields: %w", err)
+	}
+
+	for key, value := range t.extraBody {
+		if _, exists := body[key]; exists {
+			return fmt.Errorf("extra body field %q collides with a field already set on the request", key)
+		}
+		body[key] = value
+	}
+
+	merged, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	setBody(req, merged)
+	return nil
+}
+
+func setBody(req *http.Request, data []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+func rewriteAudioParts(req *http.Request) error {
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	setBody(req, data)
+	if !bytes.Contains(data, []byte(`"data:audio/`)) {
+		return nil
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return fmt.Errorf("failed to decode request body for audio input: %w", err)
+	}
+	var messages []map[string]json.RawMessage
+	if err := json.Unmarshal(body["messages"], &messages); err != nil {
+		return fmt.Errorf("failed to decode messages for audio input: %w", err)
+	}
+
+	for _, msg := range messages {
+		var parts []map[string]json.RawMessage
+		if json.Unmarshal(msg["content"], &parts) != nil {
+			continue
+		}
+		for i, part := range parts {
+			var image struct {
+				URL string `json:"url"`
+			}
+			if json.Unmarshal(part["image_url"], &image) != nil || !strings.HasPrefix(image.URL, "data:audio/") {
+				continue
+			}
+			header, payload, _ := strings.Cut(strings.TrimPrefix(image.URL, "data:"), ",")
+			format, err := audioFormat(strings.TrimSuffix(header, ";base64"))
+			if err != nil {
+				return err
+			}
+			audio, _ := json.Marshal(map[string]string{"data": payload, "format": format})
+			parts[i] = map[string]json.RawMessage{"type": json.RawMessage(`"input_audio"`), "input_audio": audio}
+		}
+		msg["content"], _ = json.Marshal(parts)
+	}
+
+	body["messages"], _ = json.Marshal(messages)
+	rewritten, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	setBody(req, rewritten)
+	return nil
+}
+
+func reportUnknownFields(resp *http.Response) {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return
+	}
+
+	var keys []string
+	for key := range body {
+		if !knownResponseFields[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		ui.Printf(os.Stderr, ui.ColorBlue, "[debug] response field %s: %s\n", key, body[key])
+	}
+}
+
+func ParseExtraValue(raw string) json.RawMessage {
+	if json.Valid([]byte(raw)) {
+		return json.RawMessage(raw)
+	}
+	b, _ := json.Marshal(raw)
+	return b
+}
@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yuriiter/ai/pkg/ui"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const unknownToolLimit = 2
+
+func (a *Agent) unknownTool(name string) error {
+	a.unknownToolStreak++
+
+	names := a.Registry.Names()
+	msg := fmt.Sprintf("no tool named '%s'", name)
+	suggestion := closestName(name, names)
+	if suggestion != "" {
+		msg += fmt.Sprintf("; did you mean '%s'?", suggestion)
+	}
+	msg += fmt.Sprintf(" Available tools: %s", strings.Join(names, ", "))
+
+	ui.Printf(os.Stderr, ui.ColorRed, "[Unknown tool %s]\n", name)
+	a.emit(Event{Type: EventUnknownTool, ToolName: name, Content: suggestion})
+	return fmt.Errorf("%s", msg)
+}
+
+func (a *Agent) correctUnknownTools() {
+	if a.unknownToolStreak < unknownToolLimit {
+		return
+	}
+	a.unknownToolStreak = 0
+	a.history = append(a.history, openai.ChatCompletionMessage{
+		Role: a.instructionRole(),
+		Content: fmt.Sprintf("Your last tool calls used names that do not exist. Only these tools exist: %s. "+
+			"Call one of them with its exact name, or answer without tools.", strings.Join(a.Registry.Names(), ", ")),
+	})
+}
+
+func normalizeToolName(name, args string, names []string) (string, string) {
+	clean := strings.Trim(strings.TrimSpace(name), "`'\"")
+	known := make(map[string]bool, len(names))
+	for _, n := range names {
+		known[n] = true
+	}
+	if known[clean] {
+		return clean, args
+	}
+
+	end := strings.IndexFunc(clean, func(r rune) bool { return !isToolNameRune(r) })
+	base, rest := clean, ""
+	if end >= 0 {
+		base, rest = clean[:end], clean[end:]
+	}
+	if !known[base] {
+		longest := ""
+		for _, n := range names {
+			if len(n) > len(longest) && strings.HasPrefix(clean, n) && !startsWithToolNameRune(clean[len(n):]) {
+				longest = n
+			}
+		}
+		for _, prefix := range []string{"functions.", "tools."} {
+			if trimmed := strings.TrimPrefix(base, prefix); longest == "" && trimmed != base && known[trimmed] {
+				longest = trimmed
+			}
+		}
+		if longest == "" {
+			return base, args
+		}
+		base, rest = longest, clean[strings.Index(clean, longest)+len(longest):]
+	}
+
+	if strings.TrimSpace(args) == "" || strings.TrimSpace(args) == "{}" {
+		if embedded := strings.TrimSpace(strings.Trim(strings.TrimSpace(rest), "=():")); strings.HasPrefix(embedded, "{") && json.Valid([]byte(embedded)) {
+			args = embedded
+		}
+	}
+	return base, args
+}
+
+func isToolNameRune(r rune) bool {
+	return r == '_' || r == '-' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+func startsWithToolNameRune(s string) bool {
+	return s != "" && isToolNameRune(rune(s[0]))
+}
+
+func closestName(name string, names []string) string {
+	best, bestDistance := "", -1
+	lower := strings.ToLower(name)
+	for _, n := range names {
+		d := levenshtein(lower, strings.ToLower(n))
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = n, d
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(2, len(name)/3) {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/tools"
+)
+
+func TestNormalizeToolName(t *testing.T) {
+	names := []string{"get_weather", "get_weather_forecast", "read_file", "search"}
+	const city = `{"city":"Paris"}`
+	tests := []struct {
+		name, args         string
+		wantName, wantArgs string
+	}{
+		{"get_weather", city, "get_weather", city},
+		{"get_weather" + city, "", "get_weather", city},
+		{"get_weather" + city, "{}", "get_weather", city},
+		{"get_weather=" + city, "", "get_weather", city},
+		{"get_weather(" + city + ")", "", "get_weather", city},
+		{"get_weather: " + city, "", "get_weather", city},
+		{"get_weather " + city, "", "get_weather", city},
+		{"get_weather_forecast" + city, "", "get_weather_forecast", city},
+		{"get_weather" + city, `{"city":"Rome"}`, "get_weather", `{"city":"Rome"}`},
+		{"get_weather{city}", "", "get_weather", ""},
+		{" `read_file` ", `{"path":"a"}`, "read_file", `{"path":"a"}`},
+		{`"search"`, "{}", "search", "{}"},
+		{"functions.search", "{}", "search", "{}"},
+		{"tools.search" + city, "", "search", city},
+		{"delete_everything" + city, "", "delete_everything", ""},
+		{"get_weathers", "{}", "get_weathers", "{}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotName, gotArgs := normalizeToolName(tt.name, tt.args, names)
+			if gotName != tt.wantName || gotArgs != tt.wantArgs {
+				t.Errorf("got (%q, %q), want (%q, %q)", gotName, gotArgs, tt.wantName, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestUnknownToolListsValidNames(t *testing.T) {
+	a, _ := scriptedAgent(true)
+	for _, name := range []string{"get_weather", "read_file"} {
+		a.Registry.RegisterInternal(openai.FunctionDefinition{Name: name}, tools.Annotations{}, func(string) (string, error) { return "", nil })
+	}
+
+	err := a.unknownTool("get_wether")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"no tool named 'get_wether'", "did you mean 'get_weather'?", "Available tools: get_weather, read_file"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+	if err := a.unknownTool("completely_different"); strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("suggested a name for an unrelated tool: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/config"
+	"github.com/yuriiter/ai/pkg/ui"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type UsageRecord struct {
+	Time             time.Time          `json:"time"`
+	Model            string             `json:"model"`
+	Command          string             `json:"command"`
+	Session          string             `json:"session,omitempty"`
+	PromptTokens     int                `json:"prompt_tokens"`
+	CompletionTokens int                `json:"completion_tokens"`
+	CostUSD          *float64           `json:"cost_usd,omitempty"`
+	Price            *config.ModelPrice `json:"price,omitempty"`
+	PriceVersion     string             `json:"price_version,omitempty"`
+}
+
+func UsageLogPath() string {
+	return filepath.Join(ui.DataDir(), "ai", "usage.jsonl")
+}
+
+func (a *Agent) recordUsage(model string, usage openai.Usage) {
+	if !a.config.UsageLog || usage.PromptTokens+usage.CompletionTokens == 0 {
+		return
+	}
+
+	rec := UsageRecord{
+		Time:             time.Now().UTC(),
+		Model:            model,
+		Command:          a.config.Command,
+		Session:          a.config.Session,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	}
+	if price, err := a.config.Price(model); err == nil {
+		cost := price.Cost(usage.PromptTokens, usage.CompletionTokens)
+		rec.CostUSD = &cost
+		rec.Price = &price
+		rec.PriceVersion = a.config.PriceVersion()
+	}
+
+	if err := appendUsage(UsageLogPath(), rec); err != nil && a.config.Verbose {
+		ui.Printf(os.Stderr, ui.ColorRed, "[Could not record usage: %v]\n", err)
+	}
+}
+
+func appendUsage(path string, rec UsageRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func LoadUsage(path string, since time.Time) ([]UsageRecord, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var records []UsageRecord
+	skipped := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			skipped++
+			continue
+		}
+		if !rec.Time.Before(since) {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return records, skipped, nil
+}
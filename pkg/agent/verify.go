@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/rag"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+const verifyPrompt = "You check whether an answer is grounded in numbered source chunks. Split the answer into its factual claims " +
+	"and label each one \"supported\" (a chunk states it), \"unsupported\" (no chunk states it, or a chunk contradicts it), " +
+	"or \"uncertain\" (partly supported or ambiguous). Skip greetings, hedges, and restatements of the question. " +
+	"Reply with JSON only, in the form {\"claims\": [{\"claim\": \"...\", \"label\": \"supported\", \"chunks\": [1, 3]}]}, " +
+	"where chunks lists the numbers of the supporting chunks (empty when there are none)."
+
+const maxListedClaims = 5
+
+type groundedClaim struct {
+	Claim  string `json:"claim"`
+	Label  string `json:"label"`
+	Chunks []int  `json:"chunks"`
+}
+
+func (a *Agent) verifyGrounding(ctx context.Context, answer string, results []rag.Result, usage *openai.Usage) {
+	var sources strings.Builder
+	for i, r := range results {
+		sources.WriteString(fmt.Sprintf("[%d] %s\n%s\n\n", i+1, r.Location(), strings.TrimSpace(r.Text)))
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{Role: a.instructionRole(), Content: verifyPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Sources:\n%s\nAnswer:\n%s", sources.String(), answer)},
+	}
+
+	var claims []groundedClaim
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var resp openai.ChatCompletionResponse
+		resp, err = a.chatCompletion(ctx, openai.ChatCompletionRequest{
+			Model:       a.config.Model,
+			Messages:    messages,
+			Temperature: 0.2,
+		})
+		if err != nil {
+			err = apiError(err)
+			break
+		}
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+		if len(resp.Choices) == 0 {
+			err = fmt.Errorf("api returned empty response (no choices)")
+			continue
+		}
+
+		reply := resp.Choices[0].Message.Content
+		if claims, err = parseClaims(reply, len(results)); err == nil {
+			break
+		}
+		messages = append(messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("That reply is invalid: %v. Reply again with the JSON object only.", err)},
+		)
+	}
+	if err != nil {
+		ui.Printf(os.Stderr, ui.ColorRed, "[Grounding check skipped: %v]\n", err)
+		return
+	}
+
+	printGrounding(claims, usage)
+}
+
+func parseClaims(reply string, chunkCount int) ([]groundedClaim, error) {
+	reply = strings.TrimSpace(reply)
+	if start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}"); start >= 0 && end > start {
+		reply = reply[start : end+1]
+	}
+
+	var parsed struct {
+		Claims *[]groundedClaim `json:"claims"`
+	}
+	if err := json.Unmarshal([]byte(reply), &parsed); err != nil {
+		return nil, fmt.Errorf("not valid JSON (%v)", err)
+	}
+	if parsed.Claims == nil {
+		return nil, fmt.Errorf("the \"claims\" list is missing")
+	}
+
+	for i, c := range *parsed.Claims {
+		if strings.TrimSpace(c.Claim) == "" {
+			return nil, fmt.Errorf("claim %d has no text", i+1)
+		}
+		switch c.Label {
+		case "supported", "unsupported", "uncertain":
+		default:
+			return nil, fmt.Errorf("claim %d has label %q (expected supported, unsupported, or uncertain)", i+1, c.Label)
+		}
+		for _, n := range c.Chunks {
+			if n < 1 || n > chunkCount {
+				return nil, fmt.Errorf("claim %d cites chunk %d, but there are only %d chunks", i+1, n, chunkCount)
+			}
+		}
+	}
+	return *parsed.Claims, nil
+}
+
+func printGrounding(claims []groundedClaim, usage *openai.Usage) {
+	if len(claims) == 0 {
+		ui.Printf(os.Stdout, ui.ColorBlue, "[Grounding: no factual claims to check]\n")
+		return
+	}
+
+	counts := make(map[string]int)
+	var flagged []groundedClaim
+	for _, c := range claims {
+		counts[c.Label]++
+		if c.Label != "supported" {
+			flagged = append(flagged, c)
+		}
+	}
+
+	summary := fmt.Sprintf("[Grounding: %d/%d claims grounded", counts["supported"], len(claims))
+	if counts["uncertain"] > 0 {
+		summary += fmt.Sprintf(", %d uncertain", counts["uncertain"])
+	}
+	summary += fmt.Sprintf("; %d tokens used this turn]\n", usage.TotalTokens)
+	color := ui.ColorGreen
+	if counts["unsupported"] > 0 {
+		color = ui.ColorRed
+	}
+	ui.Printf(os.Stdout, color, "%s", summary)
+
+	for i, c := range flagged {
+		if i == maxListedClaims {
+			ui.Printf(os.Stdout, ui.ColorRed, "  ...and %d more\n", len(flagged)-maxListedClaims)
+			break
+		}
+		ui.Printf(os.Stdout, ui.ColorRed, "  %s: %s\n", c.Label, c.Claim)
+	}
+}
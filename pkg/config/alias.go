@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ModelAlias struct {
+	Model   string `yaml:"model"`
+	BaseURL string `yaml:"base_url"`
+	ApiKey  string `yaml:"api_key"`
+}
+
+func (m *ModelAlias) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&m.Model)
+	}
+	type plain ModelAlias
+	return node.Decode((*plain)(m))
+}
+
+func (c Config) ResolveModel(name string) ModelAlias {
+	if alias, ok := c.ModelAliases[name]; ok {
+		return alias
+	}
+	return ModelAlias{Model: name}
+}
+
+func (c *Config) UseModel(name string, src Source) {
+	alias := c.ResolveModel(name)
+	c.Model = alias.Model
+	c.Chat.Model = ""
+	c.SetSource("model", src)
+	c.setString("chat.base_url", &c.Chat.BaseURL, alias.BaseURL, src)
+	c.setString("chat.api_key", &c.Chat.ApiKey, alias.ApiKey, src)
+}
+
+func (c *Config) validateModelAliases() error {
+	for name, alias := range c.ModelAliases {
+		if alias.Model == "" {
+			return fmt.Errorf("model alias %q has no model", name)
+		}
+		if _, ok := c.ModelAliases[alias.Model]; ok && alias.Model != name {
+			return fmt.Errorf("model alias %q points to another alias %q", name, alias.Model)
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolveModelAliases() {
+	chat := c.ChatEndpoint()
+	if _, ok := c.ModelAliases[chat.Model]; ok {
+		c.UseModel(chat.Model, c.Source(endpointSourceKey(c.Chat.Model, "model")))
+	}
+	if c.SummaryModel != "" {
+		c.SummaryModel = c.ResolveModel(c.SummaryModel).Model
+	}
+}
+
+func endpointSourceKey(sectionValue, key string) string {
+	if sectionValue != "" {
+		return "chat." + key
+	}
+	return key
+}
@@ -1,54 +1,196 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
+	"time"
+)
+
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+	SourceProfile Source = "profile"
 )
 
 type Config struct {
-	ApiKey             string
-	BaseURL            string
-	Model              string
-	ImageModel         string
-	Editor             string
-	SystemInstructions string
-	MaxSteps           int
-	RetainHistory      bool
-	Temperature        float32
-	RagGlobs           []string
-	RagTopK            int
-	ContextGlobs       []string
-	AttachGlobs        []string
-	GenerateImage      string
-	ImageSize          string
+	ApiKey              string
+	BaseURL             string
+	Model               string
+	ImageModel          string
+	Editor              string
+	Player              string
+	SystemInstructions  string
+	NoSystem            bool
+	MaxSteps            int
+	RetainHistory       bool
+	Temperature         float32
+	ReasoningModels     []string
+	VisionModels        []string
+	AudioModels         []string
+	RagGlobs            []string
+	RagTypes            []string
+	RagTopK             int
+	RagSystemPrompt     string
+	RagTemplate         string
+	RagHierarchical     bool
+	RagVerify           bool
+	RagSeed             bool
+	RagMetric           string
+	EmbeddingProvider   string
+	EmbeddingModel      string
+	RagNormalize        bool
+	RagBoilerplate      Boilerplate
+	AutoRag             bool
+	Corpus              string
+	Converters          map[string]string
+	ExtractWorkers      int
+	EmbedWorkers        int
+	EmbedBatchSize      int
+	Verbose             bool
+	ContextGlobs        []string
+	AttachGlobs         []string
+	AttachAsBase64      bool
+	GenerateImage       string
+	ImageSize           string
+	ToolOnly            string
+	ToolChoice          string
+	WaitForTools        bool
+	TextTools           bool
+	ToolRetries         int
+	MCPMaxMessageMB     int
+	SummarizeToolOutput bool
+	SummaryModel        string
+	QuickModel          string
+	QuickMaxTokens      int
+	MaxTokens           int
+	ToolImages          bool
+	BudgetTokens        int
+	BudgetUSD           float64
+	AutoContinue        int
+	ConfirmTools        string
+	MemoryFile          string
+	PromptPrefixFile    string
+	UsageLog            bool
+	DetectBase64        bool
+	Command             string
+	Session             string
+	AssumeYes           bool
+	YesDestructive      bool
+	NoAtExpansion       bool
+	ExpandMacros        bool
+	ExtraBody           map[string]json.RawMessage
+	Debug               bool
+	DryRun              bool
+	Notify              string
+	NotifyAfter         int
+	ContextWindow       int
+	MaxContextTokens    int
+	StreamIdleTimeout   time.Duration
+	AssistantName       string
+
+	Chat       Endpoint
+	Embeddings Endpoint
+	Voice      Endpoint
+
+	Profile           string
+	Profiles          map[string]Endpoint
+	ModelAliases      map[string]ModelAlias
+	Corpora           map[string]Corpus
+	ModelPrices       map[string]ModelPrice
+	EmbeddingPrefixes map[string]EmbeddingPrefix
+	Defaults          map[string]map[string]interface{}
+	FilePath          string
+	Sources           map[string]Source
+}
+
+const DefaultRemoteEmbeddingModel = "text-embedding-3-small"
+
+const DefaultRagSystemPrompt = "Answer the user's question using only the provided context. " +
+	"If the context does not contain the answer, say \"I don't know\" instead of guessing. " +
+	"Mention the source file names you relied on."
+
+type Entry struct {
+	Key    string
+	Value  string
+	Source Source
+}
+
+func Load() (Config, error) {
+	return LoadProfile("")
 }
 
-func Load() Config {
+func LoadProfile(profile string) (Config, error) {
 	c := Config{
-		ApiKey:             os.Getenv("OPENAI_API_KEY"),
-		BaseURL:            os.Getenv("OPENAI_BASE_URL"),
-		Model:              os.Getenv("OPENAI_MODEL"),
-		ImageModel:         os.Getenv("OPENAI_IMAGE_MODEL"),
-		Editor:             os.Getenv("EDITOR"),
-		SystemInstructions: os.Getenv("OPENAI_SYSTEM_INSTRUCTIONS"),
-		MaxSteps:           10,
-		Temperature:        1.0,
-		RagTopK:            3,
+		Model:           "gemini-3-flash-preview",
+		ImageModel:      "gemini-2.5-flash-image",
+		MaxSteps:        10,
+		Temperature:     1.0,
+		ReasoningModels: []string{"o1*", "o3*", "o4*", "gpt-5*"},
+		VisionModels: []string{"gpt-4o*", "gpt-4.1*", "gpt-4-turbo*", "gpt-5*", "o1*", "o3*", "o4*", "gemini*", "claude*",
+			"*vision*", "*-vl*", "llava*", "pixtral*"},
+		AudioModels:       []string{"*audio*", "gemini*"},
+		RagTopK:           3,
+		RagSystemPrompt:   DefaultRagSystemPrompt,
+		RagTemplate:       DefaultRagTemplate,
+		RagMetric:         "cosine",
+		EmbeddingProvider: "local",
+		EmbeddingModel:    "sentence-transformers/all-MiniLM-L6-v2",
+		Notify:            "auto",
+		NotifyAfter:       10,
+		ContextWindow:     128000,
+		StreamIdleTimeout: 2 * time.Minute,
+		ToolRetries:       2,
+		MCPMaxMessageMB:   64,
+		EmbedBatchSize:    100,
+		QuickMaxTokens:    1024,
+		ConfirmTools:      "never",
+		UsageLog:          true,
+		DetectBase64:      true,
+		Sources:           make(map[string]Source),
 	}
 
-	if c.Model == "" {
-		c.Model = "gemini-3-flash-preview"
+	c.FilePath = FilePath()
+	if err := c.loadFile(c.FilePath); err != nil {
+		return c, err
 	}
 
-	if c.ImageModel == "" {
-		c.ImageModel = "gemini-2.5-flash-image"
+	c.loadEnv()
+
+	profileSource := SourceFlag
+	if profile == "" {
+		profile, profileSource = os.Getenv("AI_PROFILE"), SourceEnv
+	}
+	if err := c.applyProfile(profile, profileSource); err != nil {
+		return c, err
 	}
+	c.resolveModelAliases()
 
-	if val := os.Getenv("OPENAI_TEMPERATURE"); val != "" {
-		if f, err := strconv.ParseFloat(val, 32); err == nil {
-			c.Temperature = float32(f)
+	switch c.RagMetric {
+	case "cosine", "dot", "l2":
+	default:
+		return c, fmt.Errorf("invalid rag_metric %q from %s (expected cosine, dot, or l2)", c.RagMetric, c.Source("rag_metric"))
+	}
+	switch c.EmbeddingProvider {
+	case "local":
+	case "openai":
+		if c.Embeddings.Model != "" {
+			c.EmbeddingModel = c.Embeddings.Model
+		} else if c.Source("embedding_model") == SourceDefault {
+			c.EmbeddingModel = DefaultRemoteEmbeddingModel
 		}
+	default:
+		return c, fmt.Errorf("invalid embedding_provider %q from %s (expected local or openai)", c.EmbeddingProvider, c.Source("embedding_provider"))
+	}
+	if c.Source("rag_normalize") == SourceDefault {
+		c.RagNormalize = c.RagMetric == "cosine"
 	}
 
 	if c.Editor == "" {
@@ -61,5 +203,208 @@ func Load() Config {
 		}
 	}
 
-	return c
+	return c, nil
+}
+
+func (c *Config) loadEnv() {
+	c.setChatEnv("api_key", &c.ApiKey, &c.Chat.ApiKey, os.Getenv("OPENAI_API_KEY"))
+	c.setChatEnv("base_url", &c.BaseURL, &c.Chat.BaseURL, os.Getenv("OPENAI_BASE_URL"))
+	c.setChatEnv("model", &c.Model, &c.Chat.Model, os.Getenv("OPENAI_MODEL"))
+	c.setString("image_model", &c.ImageModel, os.Getenv("OPENAI_IMAGE_MODEL"), SourceEnv)
+	c.setString("editor", &c.Editor, os.Getenv("EDITOR"), SourceEnv)
+	c.setString("player", &c.Player, os.Getenv("AI_PLAYER"), SourceEnv)
+	c.setString("system_instructions", &c.SystemInstructions, os.Getenv("OPENAI_SYSTEM_INSTRUCTIONS"), SourceEnv)
+	c.setString("rag_system_prompt", &c.RagSystemPrompt, os.Getenv("AI_RAG_SYSTEM_PROMPT"), SourceEnv)
+	c.setString("notify", &c.Notify, os.Getenv("AI_NOTIFY"), SourceEnv)
+	c.setString("rag_metric", &c.RagMetric, os.Getenv("RAG_METRIC"), SourceEnv)
+	c.setString("embedding_provider", &c.EmbeddingProvider, os.Getenv("RAG_EMBEDDING_PROVIDER"), SourceEnv)
+	c.setString("embedding_model", &c.EmbeddingModel, os.Getenv("RAG_EMBEDDING_MODEL"), SourceEnv)
+	c.setString("quick_model", &c.QuickModel, os.Getenv("AI_QUICK_MODEL"), SourceEnv)
+	c.setString("assistant_name", &c.AssistantName, os.Getenv("AI_ASSISTANT_NAME"), SourceEnv)
+
+	if val := os.Getenv("OPENAI_TEMPERATURE"); val != "" {
+		if f, err := strconv.ParseFloat(val, 32); err == nil {
+			c.Temperature = float32(f)
+			c.SetSource("temperature", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("AI_AUTO_RAG"); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			c.AutoRag = b
+			c.SetSource("auto_rag", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("RAG_NORMALIZE"); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			c.RagNormalize = b
+			c.SetSource("rag_normalize", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("RAG_EMBED_WORKERS"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			c.EmbedWorkers = n
+			c.SetSource("embed_workers", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("AI_NOTIFY_AFTER"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			c.NotifyAfter = n
+			c.SetSource("notify_after", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("AI_CONTEXT_WINDOW"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			c.ContextWindow = n
+			c.SetSource("context_window", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("AI_MAX_CONTEXT_TOKENS"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			c.MaxContextTokens = n
+			c.SetSource("max_context_tokens", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("AI_USAGE_LOG"); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			c.UsageLog = b
+			c.SetSource("usage_log", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("AI_DETECT_BASE64"); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			c.DetectBase64 = b
+			c.SetSource("detect_base64", SourceEnv)
+		}
+	}
+
+	if val := os.Getenv("AI_STREAM_IDLE_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			c.StreamIdleTimeout = d
+			c.SetSource("stream_idle_timeout", SourceEnv)
+		}
+	}
+}
+
+func (c *Config) setChatEnv(key string, dst, chat *string, val string) {
+	c.setString(key, dst, val, SourceEnv)
+	if *chat != "" {
+		c.setString("chat."+key, chat, val, SourceEnv)
+	}
+}
+
+func (c *Config) setString(key string, dst *string, val string, src Source) {
+	if val == "" {
+		return
+	}
+	*dst = val
+	c.SetSource(key, src)
+}
+
+func (c *Config) SetSource(key string, src Source) {
+	if c.Sources == nil {
+		c.Sources = make(map[string]Source)
+	}
+	c.Sources[key] = src
+}
+
+func (c Config) Source(key string) Source {
+	if src, ok := c.Sources[key]; ok {
+		return src
+	}
+	return SourceDefault
+}
+
+func (c Config) Entries() []Entry {
+	values := []struct {
+		key   string
+		value string
+	}{
+		{"api_key", maskOptional(c.ApiKey)},
+		{"base_url", c.BaseURL},
+		{"model", c.Model},
+		{"profile", c.Profile},
+		{"chat.api_key", maskOptional(c.Chat.ApiKey)},
+		{"chat.base_url", c.Chat.BaseURL},
+		{"chat.model", c.Chat.Model},
+		{"embeddings.api_key", maskOptional(c.Embeddings.ApiKey)},
+		{"embeddings.base_url", c.Embeddings.BaseURL},
+		{"embeddings.model", c.Embeddings.Model},
+		{"voice.api_key", maskOptional(c.Voice.ApiKey)},
+		{"voice.base_url", c.Voice.BaseURL},
+		{"voice.model", c.Voice.Model},
+		{"image_model", c.ImageModel},
+		{"summary_model", c.SummaryModel},
+		{"quick_model", c.QuickModel},
+		{"quick_max_tokens", strconv.Itoa(c.QuickMaxTokens)},
+		{"assistant_name", c.AssistantName},
+		{"editor", c.Editor},
+		{"player", c.Player},
+		{"system_instructions", c.SystemInstructions},
+		{"rag_system_prompt", c.RagSystemPrompt},
+		{"max_steps", strconv.Itoa(c.MaxSteps)},
+		{"temperature", strconv.FormatFloat(float64(c.Temperature), 'g', -1, 32)},
+		{"reasoning_models", strings.Join(c.ReasoningModels, ", ")},
+		{"vision_models", strings.Join(c.VisionModels, ", ")},
+		{"audio_models", strings.Join(c.AudioModels, ", ")},
+		{"rag_top_k", strconv.Itoa(c.RagTopK)},
+		{"rag_metric", c.RagMetric},
+		{"embedding_provider", c.EmbeddingProvider},
+		{"embedding_model", c.EmbeddingModel},
+		{"rag_normalize", strconv.FormatBool(c.RagNormalize)},
+		{"rag_boilerplate.patterns", strings.Join(c.RagBoilerplate.Patterns, ", ")},
+		{"rag_boilerplate.repeated", strconv.FormatBool(c.RagBoilerplate.Repeated)},
+		{"auto_rag", strconv.FormatBool(c.AutoRag)},
+		{"notify", c.Notify},
+		{"notify_after", strconv.Itoa(c.NotifyAfter)},
+		{"context_window", strconv.Itoa(c.ContextWindow)},
+		{"max_context_tokens", strconv.Itoa(c.MaxContextTokens)},
+		{"stream_idle_timeout", c.StreamIdleTimeout.String()},
+		{"extract_workers", strconv.Itoa(c.ExtractWorkers)},
+		{"embed_workers", strconv.Itoa(c.EmbedWorkers)},
+		{"embed_batch_size", strconv.Itoa(c.EmbedBatchSize)},
+		{"tool_retries", strconv.Itoa(c.ToolRetries)},
+		{"mcp_max_message_mb", strconv.Itoa(c.MCPMaxMessageMB)},
+		{"confirm_tools", c.ConfirmTools},
+		{"memory_file", c.MemoryFile},
+		{"prompt_prefix_file", c.PromptPrefixFile},
+		{"usage_log", strconv.FormatBool(c.UsageLog)},
+		{"detect_base64", strconv.FormatBool(c.DetectBase64)},
+		{"extra_body", formatExtraBody(c.ExtraBody)},
+	}
+
+	entries := make([]Entry, 0, len(values))
+	for _, v := range values {
+		entries = append(entries, Entry{Key: v.key, Value: v.value, Source: c.Source(v.key)})
+	}
+	return entries
+}
+
+func maskOptional(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return MaskSecret(secret)
+}
+
+func formatExtraBody(extra map[string]json.RawMessage) string {
+	if len(extra) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(extra)
+	return string(b)
+}
+
+func MaskSecret(secret string) string {
+	if len(secret) <= 4 {
+		return "****"
+	}
+	return fmt.Sprintf("****%s", secret[len(secret)-4:])
 }
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AI_CONFIG_FILE", path)
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	t.Setenv("OPENAI_MODEL", "")
+	t.Setenv("OPENAI_TEMPERATURE", "")
+	t.Setenv("AI_PROFILE", "")
+
+	t.Setenv("AI_CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Model != "gemini-3-flash-preview" || c.Source("model") != SourceDefault {
+		t.Fatalf("default: got %q from %s", c.Model, c.Source("model"))
+	}
+
+	writeConfig(t, "config.yaml", "model: file-model\ntemperature: 0.2\n")
+	if c, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Model != "file-model" || c.Source("model") != SourceFile {
+		t.Fatalf("file: got %q from %s", c.Model, c.Source("model"))
+	}
+
+	t.Setenv("OPENAI_MODEL", "env-model")
+	t.Setenv("OPENAI_TEMPERATURE", "0.5")
+	if c, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Model != "env-model" || c.Source("model") != SourceEnv {
+		t.Fatalf("env: got %q from %s", c.Model, c.Source("model"))
+	}
+	if c.Temperature != 0.5 || c.Source("temperature") != SourceEnv {
+		t.Fatalf("env: got temperature %v from %s", c.Temperature, c.Source("temperature"))
+	}
+
+	c.UseModel("flag-model", SourceFlag)
+	if c.Model != "flag-model" || c.Source("model") != SourceFlag {
+		t.Fatalf("flag: got %q from %s", c.Model, c.Source("model"))
+	}
+}
+
+func TestTOMLFile(t *testing.T) {
+	t.Setenv("OPENAI_MODEL", "")
+	t.Setenv("AI_PROFILE", "")
+
+	writeConfig(t, "config.toml", `
+model = "gpt-4o"
+max_steps = 20
+
+[extra_body]
+top_k = 40
+
+[defaults.root]
+agent = true
+`)
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Model != "gpt-4o" || c.MaxSteps != 20 || string(c.ExtraBody["top_k"]) != "40" {
+		t.Fatalf("got model %q, max_steps %d, extra_body %v", c.Model, c.MaxSteps, c.ExtraBody)
+	}
+	if c.Defaults["root"]["agent"] != true {
+		t.Fatalf("got defaults %v", c.Defaults)
+	}
+}
+
+func TestTOMLFileErrors(t *testing.T) {
+	t.Setenv("AI_PROFILE", "")
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"table after empty array", "corpora = []\n[corpora.docs]\nglobs = [\"*.md\"]\n"},
+		{"leading zero", "max_steps = 010\n"},
+		{"table defined twice", "[extra_body]\na = 1\n[extra_body]\nb = 2\n"},
+		{"duplicate key", "model = \"a\"\nmodel = \"b\"\n"},
+		{"unknown key", "no_such_key = 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfig(t, "config.toml", tt.content)
+			if _, err := Load(); err == nil {
+				t.Fatal("expected an error")
+			} else if !strings.Contains(err.Error(), "config.toml") {
+				t.Fatalf("error does not name the file: %v", err)
+			}
+		})
+	}
+}
+
+func TestSaveTOMLValue(t *testing.T) {
+	path := writeConfig(t, "config.toml", "model = \"a\"\n\n[extra_body]\ntop_k = 40\n")
+	if err := SaveValue(path, "model", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveValue(path, "editor", "vim"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "model = \"b\"\neditor = \"vim\"\n\n[extra_body]\ntop_k = 40\n"
+	if string(data) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestEnvOverridesChatSection(t *testing.T) {
+	t.Setenv("AI_PROFILE", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_BASE_URL", "")
+	t.Setenv("OPENAI_MODEL", "")
+	writeConfig(t, "config.yaml", "chat:\n  api_key: file-key\n  base_url: http://file\n  model: file-model\n")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chat := c.ChatEndpoint(); chat.ApiKey != "file-key" || chat.Model != "file-model" || c.Source("chat.model") != SourceFile {
+		t.Fatalf("file: got %+v from %s", chat, c.Source("chat.model"))
+	}
+
+	t.Setenv("OPENAI_API_KEY", "env-key")
+	t.Setenv("OPENAI_BASE_URL", "http://env")
+	t.Setenv("OPENAI_MODEL", "env-model")
+	if c, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	want := Endpoint{ApiKey: "env-key", BaseURL: "http://env", Model: "env-model"}
+	if chat := c.ChatEndpoint(); chat != want {
+		t.Fatalf("env: got %+v, want %+v", chat, want)
+	}
+	for _, key := range []string{"chat.api_key", "chat.base_url", "chat.model"} {
+		if c.Source(key) != SourceEnv {
+			t.Errorf("%s from %s, want env", key, c.Source(key))
+		}
+	}
+}
+
+func TestModelAliases(t *testing.T) {
+	t.Setenv("AI_PROFILE", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_BASE_URL", "")
+	t.Setenv("OPENAI_MODEL", "mistral")
+	writeConfig(t, "config.yaml", "model_aliases:\n  fast: gpt-4o-mini\n  local:\n    model: llama3.1:8b\n    base_url: http://localhost:11434/v1\n    api_key: local-key\n")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chat := c.ChatEndpoint(); chat.Model != "mistral" {
+		t.Fatalf("plain model name: got %q, want mistral", chat.Model)
+	}
+
+	t.Setenv("OPENAI_MODEL", "local")
+	if c, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	want := Endpoint{ApiKey: "local-key", BaseURL: "http://localhost:11434/v1", Model: "llama3.1:8b"}
+	if chat := c.ChatEndpoint(); chat != want {
+		t.Fatalf("alias: got %+v, want %+v", chat, want)
+	}
+
+	for name, want := range map[string]ModelAlias{
+		"fast":    {Model: "gpt-4o-mini"},
+		"mistral": {Model: "mistral"},
+		"gpt-4o":  {Model: "gpt-4o"},
+	} {
+		if got := c.ResolveModel(name); got != want {
+			t.Errorf("ResolveModel(%q) = %+v, want %+v", name, got, want)
+		}
+	}
+}
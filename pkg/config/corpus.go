@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Corpus struct {
+	Patterns     []string `yaml:"patterns"`
+	ChunkSize    int      `yaml:"chunk_size"`
+	ChunkOverlap int      `yaml:"chunk_overlap"`
+	Embedder     string   `yaml:"embedder"`
+	Types        []string `yaml:"types"`
+	Template     string   `yaml:"template"`
+}
+
+var corpusNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func (c *Config) ResolveCorpus(name string) (Corpus, error) {
+	corpus, ok := c.Corpora[name]
+	if !ok {
+		var names []string
+		for n := range c.Corpora {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return Corpus{}, fmt.Errorf("unknown corpus %q (no corpora are defined in %s)", name, c.FilePath)
+		}
+		return Corpus{}, fmt.Errorf("unknown corpus %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return corpus, nil
+}
+
+func (c *Config) validateCorpora() error {
+	for name, corpus := range c.Corpora {
+		if !corpusNameRegex.MatchString(name) {
+			return fmt.Errorf("corpus name %q may only contain letters, digits, '-' and '_'", name)
+		}
+		if len(corpus.Patterns) == 0 {
+			return fmt.Errorf("corpus %q has no patterns", name)
+		}
+		if corpus.ChunkSize == 0 {
+			corpus.ChunkSize = 800
+		}
+		if corpus.ChunkOverlap == 0 {
+			corpus.ChunkOverlap = 100
+		}
+		if corpus.ChunkSize < 0 || corpus.ChunkOverlap < 0 || corpus.ChunkOverlap >= corpus.ChunkSize {
+			return fmt.Errorf("corpus %q: chunk_overlap must be smaller than chunk_size", name)
+		}
+		if corpus.Embedder != "" && corpus.Embedder != "local" && corpus.Embedder != "openai" {
+			return fmt.Errorf("corpus %q: unsupported embedder %q (supported: local, openai)", name, corpus.Embedder)
+		}
+		if corpus.Template != "" {
+			if _, err := ParseRagTemplate(fmt.Sprintf("corpora.%s.template", name), corpus.Template); err != nil {
+				return err
+			}
+		}
+		c.Corpora[name] = corpus
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Endpoint struct {
+	ApiKey  string `yaml:"api_key"`
+	BaseURL string `yaml:"base_url"`
+	Model   string `yaml:"model"`
+}
+
+func (c Config) ChatEndpoint() Endpoint {
+	return c.resolveEndpoint(c.Chat, c.Model)
+}
+
+func (c Config) EmbeddingsEndpoint() Endpoint {
+	return c.resolveEndpoint(c.Embeddings, "")
+}
+
+func (c Config) VoiceEndpoint() Endpoint {
+	return c.resolveEndpoint(c.Voice, "")
+}
+
+func (c Config) resolveEndpoint(section Endpoint, model string) Endpoint {
+	e := Endpoint{ApiKey: c.ApiKey, BaseURL: c.BaseURL, Model: model}
+	if section.ApiKey != "" {
+		e.ApiKey = section.ApiKey
+	}
+	if section.BaseURL != "" {
+		e.BaseURL = section.BaseURL
+	}
+	if section.Model != "" {
+		e.Model = section.Model
+	}
+	return e
+}
+
+var ErrNoAPIKey = errors.New("no API key configured")
+
+func (e Endpoint) Validate(section string) error {
+	if e.ApiKey == "" && e.BaseURL == "" {
+		return fmt.Errorf("%w for %s (set %s.api_key or api_key in the config file, or OPENAI_API_KEY)", ErrNoAPIKey, section, section)
+	}
+	return nil
+}
+
+func (c *Config) applyProfile(name string, src Source) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if name == "default" {
+			return nil
+		}
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %q (no profiles are defined in %s)", name, c.FilePath)
+		}
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(names, ", "))
+	}
+
+	c.Profile = name
+	c.SetSource("profile", src)
+	c.setString("chat.api_key", &c.Chat.ApiKey, profile.ApiKey, SourceProfile)
+	c.setString("chat.base_url", &c.Chat.BaseURL, profile.BaseURL, SourceProfile)
+	c.setString("chat.model", &c.Chat.Model, profile.Model, SourceProfile)
+	return nil
+}
+
+func (c *Config) setEndpoint(name string, dst *Endpoint, section *Endpoint) {
+	if section == nil {
+		return
+	}
+	c.setString(name+".api_key", &dst.ApiKey, section.ApiKey, SourceFile)
+	c.setString(name+".base_url", &dst.BaseURL, section.BaseURL, SourceFile)
+	c.setString(name+".model", &dst.Model, section.Model, SourceFile)
+}
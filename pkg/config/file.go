@@ -0,0 +1,464 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type fileConfig struct {
+	APIKey             *string                           `yaml:"api_key"`
+	BaseURL            *string                           `yaml:"base_url"`
+	Model              *string                           `yaml:"model"`
+	Chat               *Endpoint                         `yaml:"chat"`
+	Embeddings         *Endpoint                         `yaml:"embeddings"`
+	Voice              *Endpoint                         `yaml:"voice"`
+	ImageModel         *string                           `yaml:"image_model"`
+	SummaryModel       *string                           `yaml:"summary_model"`
+	QuickModel         *string                           `yaml:"quick_model"`
+	QuickMaxTokens     *int                              `yaml:"quick_max_tokens"`
+	AssistantName      *string                           `yaml:"assistant_name"`
+	Editor             *string                           `yaml:"editor"`
+	Player             *string                           `yaml:"player"`
+	SystemInstructions *string                           `yaml:"system_instructions"`
+	RagSystemPrompt    *string                           `yaml:"rag_system_prompt"`
+	RagTemplate        *string                           `yaml:"rag_template"`
+	MaxSteps           *int                              `yaml:"max_steps"`
+	Temperature        *float32                          `yaml:"temperature"`
+	ReasoningModels    []string                          `yaml:"reasoning_models"`
+	VisionModels       []string                          `yaml:"vision_models"`
+	AudioModels        []string                          `yaml:"audio_models"`
+	RagTopK            *int                              `yaml:"rag_top_k"`
+	RagMetric          *string                           `yaml:"rag_metric"`
+	EmbeddingProvider  *string                           `yaml:"embedding_provider"`
+	EmbeddingModel     *string                           `yaml:"embedding_model"`
+	RagNormalize       *bool                             `yaml:"rag_normalize"`
+	RagBoilerplate     *Boilerplate                      `yaml:"rag_boilerplate"`
+	AutoRag            *bool                             `yaml:"auto_rag"`
+	Notify             *string                           `yaml:"notify"`
+	NotifyAfter        *int                              `yaml:"notify_after"`
+	ContextWindow      *int                              `yaml:"context_window"`
+	MaxContextTokens   *int                              `yaml:"max_context_tokens"`
+	StreamIdleTimeout  *string                           `yaml:"stream_idle_timeout"`
+	ExtraBody          map[string]interface{}            `yaml:"extra_body"`
+	Profiles           map[string]Endpoint               `yaml:"profiles"`
+	ModelAliases       map[string]ModelAlias             `yaml:"model_aliases"`
+	Corpora            map[string]Corpus                 `yaml:"corpora"`
+	ModelPrices        map[string]ModelPrice             `yaml:"model_prices"`
+	EmbeddingPrefixes  map[string]EmbeddingPrefix        `yaml:"embedding_prefixes"`
+	Converters         map[string]string                 `yaml:"converters"`
+	ExtractWorkers     *int                              `yaml:"extract_workers"`
+	EmbedWorkers       *int                              `yaml:"embed_workers"`
+	EmbedBatchSize     *int                              `yaml:"embed_batch_size"`
+	ToolRetries        *int                              `yaml:"tool_retries"`
+	MCPMaxMessageMB    *int                              `yaml:"mcp_max_message_mb"`
+	ConfirmTools       *string                           `yaml:"confirm_tools"`
+	MemoryFile         *string                           `yaml:"memory_file"`
+	PromptPrefixFile   *string                           `yaml:"prompt_prefix_file"`
+	UsageLog           *bool                             `yaml:"usage_log"`
+	DetectBase64       *bool                             `yaml:"detect_base64"`
+	Defaults           map[string]map[string]interface{} `yaml:"defaults"`
+}
+
+type Boilerplate struct {
+	Patterns []string `yaml:"patterns"`
+	Repeated bool     `yaml:"repeated"`
+}
+
+type EmbeddingPrefix struct {
+	Query    string `yaml:"query"`
+	Document string `yaml:"document"`
+}
+
+func FilePath() string {
+	if path := os.Getenv("AI_CONFIG_FILE"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	path := filepath.Join(home, ".config", "ai", "config.yaml")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		toml := filepath.Join(home, ".config", "ai", "config.toml")
+		if _, err := os.Stat(toml); err == nil {
+			return toml
+		}
+	}
+	return path
+}
+
+func isTOML(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
+
+var yamlLineRegex = regexp.MustCompile(`line \d+: `)
+
+func decodeFile(path string, data []byte, fc *fileConfig) error {
+	if !isTOML(path) {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(fc); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	}
+
+	var doc map[string]interface{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return err
+	}
+	converted, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(converted))
+	dec.KnownFields(true)
+	if err := dec.Decode(fc); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return errors.New(yamlLineRegex.ReplaceAllString(strings.Join(typeErr.Errors, "; "), ""))
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var fc fileConfig
+	if err := decodeFile(path, data, &fc); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	if fc.APIKey != nil {
+		c.setString("api_key", &c.ApiKey, *fc.APIKey, SourceFile)
+	}
+	if fc.BaseURL != nil {
+		c.setString("base_url", &c.BaseURL, *fc.BaseURL, SourceFile)
+	}
+	if fc.Model != nil {
+		c.setString("model", &c.Model, *fc.Model, SourceFile)
+	}
+	c.setEndpoint("chat", &c.Chat, fc.Chat)
+	c.setEndpoint("embeddings", &c.Embeddings, fc.Embeddings)
+	c.setEndpoint("voice", &c.Voice, fc.Voice)
+	if fc.ImageModel != nil {
+		c.setString("image_model", &c.ImageModel, *fc.ImageModel, SourceFile)
+	}
+	if fc.SummaryModel != nil {
+		c.setString("summary_model", &c.SummaryModel, *fc.SummaryModel, SourceFile)
+	}
+	if fc.AssistantName != nil {
+		c.setString("assistant_name", &c.AssistantName, *fc.AssistantName, SourceFile)
+	}
+	if fc.QuickModel != nil {
+		c.setString("quick_model", &c.QuickModel, *fc.QuickModel, SourceFile)
+	}
+	if fc.QuickMaxTokens != nil {
+		if *fc.QuickMaxTokens <= 0 {
+			return fmt.Errorf("invalid config file %s: quick_max_tokens must be positive", path)
+		}
+		c.QuickMaxTokens = *fc.QuickMaxTokens
+		c.SetSource("quick_max_tokens", SourceFile)
+	}
+	if fc.Editor != nil {
+		c.setString("editor", &c.Editor, *fc.Editor, SourceFile)
+	}
+	if fc.Player != nil {
+		c.setString("player", &c.Player, *fc.Player, SourceFile)
+	}
+	if fc.SystemInstructions != nil {
+		c.setString("system_instructions", &c.SystemInstructions, *fc.SystemInstructions, SourceFile)
+	}
+	if fc.RagSystemPrompt != nil {
+		c.setString("rag_system_prompt", &c.RagSystemPrompt, *fc.RagSystemPrompt, SourceFile)
+	}
+	if fc.RagTemplate != nil {
+		if _, err := ParseRagTemplate("rag_template", *fc.RagTemplate); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		c.setString("rag_template", &c.RagTemplate, *fc.RagTemplate, SourceFile)
+	}
+	if fc.RagMetric != nil {
+		c.setString("rag_metric", &c.RagMetric, *fc.RagMetric, SourceFile)
+	}
+	if fc.EmbeddingProvider != nil {
+		c.setString("embedding_provider", &c.EmbeddingProvider, *fc.EmbeddingProvider, SourceFile)
+	}
+	if fc.EmbeddingModel != nil {
+		c.setString("embedding_model", &c.EmbeddingModel, *fc.EmbeddingModel, SourceFile)
+	}
+	if fc.Notify != nil {
+		c.setString("notify", &c.Notify, *fc.Notify, SourceFile)
+	}
+	if fc.MaxSteps != nil {
+		c.MaxSteps = *fc.MaxSteps
+		c.SetSource("max_steps", SourceFile)
+	}
+	for _, list := range []struct {
+		key      string
+		patterns []string
+		dst      *[]string
+	}{
+		{"reasoning_models", fc.ReasoningModels, &c.ReasoningModels},
+		{"vision_models", fc.VisionModels, &c.VisionModels},
+		{"audio_models", fc.AudioModels, &c.AudioModels},
+	} {
+		if list.patterns == nil {
+			continue
+		}
+		for _, pattern := range list.patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid config file %s: %s: bad pattern %q", path, list.key, pattern)
+			}
+		}
+		*list.dst = list.patterns
+		c.SetSource(list.key, SourceFile)
+	}
+	if fc.Temperature != nil {
+		c.Temperature = *fc.Temperature
+		c.SetSource("temperature", SourceFile)
+	}
+	if fc.RagTopK != nil {
+		c.RagTopK = *fc.RagTopK
+		c.SetSource("rag_top_k", SourceFile)
+	}
+	if fc.NotifyAfter != nil {
+		c.NotifyAfter = *fc.NotifyAfter
+		c.SetSource("notify_after", SourceFile)
+	}
+	if fc.RagNormalize != nil {
+		c.RagNormalize = *fc.RagNormalize
+		c.SetSource("rag_normalize", SourceFile)
+	}
+	if fc.AutoRag != nil {
+		c.AutoRag = *fc.AutoRag
+		c.SetSource("auto_rag", SourceFile)
+	}
+	if fc.RagBoilerplate != nil {
+		for _, p := range fc.RagBoilerplate.Patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return fmt.Errorf("invalid config file %s: rag_boilerplate.patterns: %w", path, err)
+			}
+		}
+		c.RagBoilerplate = *fc.RagBoilerplate
+		if len(c.RagBoilerplate.Patterns) > 0 {
+			c.SetSource("rag_boilerplate.patterns", SourceFile)
+		}
+		c.SetSource("rag_boilerplate.repeated", SourceFile)
+	}
+	if fc.ExtractWorkers != nil {
+		c.ExtractWorkers = *fc.ExtractWorkers
+		c.SetSource("extract_workers", SourceFile)
+	}
+	if fc.EmbedWorkers != nil {
+		c.EmbedWorkers = *fc.EmbedWorkers
+		c.SetSource("embed_workers", SourceFile)
+	}
+	if fc.EmbedBatchSize != nil {
+		if *fc.EmbedBatchSize <= 0 {
+			return fmt.Errorf("invalid config file %s: embed_batch_size must be positive", path)
+		}
+		c.EmbedBatchSize = *fc.EmbedBatchSize
+		c.SetSource("embed_batch_size", SourceFile)
+	}
+	if fc.MCPMaxMessageMB != nil {
+		if *fc.MCPMaxMessageMB <= 0 {
+			return fmt.Errorf("invalid config file %s: mcp_max_message_mb must be positive", path)
+		}
+		c.MCPMaxMessageMB = *fc.MCPMaxMessageMB
+		c.SetSource("mcp_max_message_mb", SourceFile)
+	}
+	if fc.ToolRetries != nil {
+		c.ToolRetries = *fc.ToolRetries
+		c.SetSource("tool_retries", SourceFile)
+	}
+	if fc.ConfirmTools != nil {
+		switch *fc.ConfirmTools {
+		case "always", "never":
+		default:
+			return fmt.Errorf("invalid config file %s: confirm_tools: unknown policy %q (expected always or never)", path, *fc.ConfirmTools)
+		}
+		c.setString("confirm_tools", &c.ConfirmTools, *fc.ConfirmTools, SourceFile)
+	}
+	if fc.MemoryFile != nil {
+		c.setString("memory_file", &c.MemoryFile, *fc.MemoryFile, SourceFile)
+	}
+	if fc.PromptPrefixFile != nil {
+		c.setString("prompt_prefix_file", &c.PromptPrefixFile, *fc.PromptPrefixFile, SourceFile)
+	}
+	if fc.UsageLog != nil {
+		c.UsageLog = *fc.UsageLog
+		c.SetSource("usage_log", SourceFile)
+	}
+	if fc.DetectBase64 != nil {
+		c.DetectBase64 = *fc.DetectBase64
+		c.SetSource("detect_base64", SourceFile)
+	}
+	if fc.ContextWindow != nil {
+		c.ContextWindow = *fc.ContextWindow
+		c.SetSource("context_window", SourceFile)
+	}
+	if fc.MaxContextTokens != nil {
+		c.MaxContextTokens = *fc.MaxContextTokens
+		c.SetSource("max_context_tokens", SourceFile)
+	}
+	if fc.StreamIdleTimeout != nil {
+		d, err := time.ParseDuration(*fc.StreamIdleTimeout)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid config file %s: stream_idle_timeout: expected a duration such as 90s or 0 to disable, got %q", path, *fc.StreamIdleTimeout)
+		}
+		c.StreamIdleTimeout = d
+		c.SetSource("stream_idle_timeout", SourceFile)
+	}
+
+	for key, value := range fc.ExtraBody {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: extra_body.%s: %w", path, key, err)
+		}
+		if c.ExtraBody == nil {
+			c.ExtraBody = make(map[string]json.RawMessage)
+		}
+		c.ExtraBody[key] = raw
+		c.SetSource("extra_body", SourceFile)
+	}
+
+	c.Corpora = fc.Corpora
+	c.Profiles = fc.Profiles
+	c.ModelAliases = fc.ModelAliases
+	c.ModelPrices = fc.ModelPrices
+	c.EmbeddingPrefixes = fc.EmbeddingPrefixes
+	for ext, converter := range fc.Converters {
+		switch converter {
+		case "pandoc", "soffice", "none":
+		default:
+			return fmt.Errorf("invalid config file %s: converters.%s: unknown converter %q (expected pandoc, soffice, or none)", path, ext, converter)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if c.Converters == nil {
+			c.Converters = make(map[string]string)
+		}
+		c.Converters[strings.ToLower(ext)] = converter
+	}
+	if err := c.validateCorpora(); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := c.validateModelAliases(); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	c.Defaults = fc.Defaults
+	return nil
+}
+
+func SaveValue(path, key, value string) error {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	if isTOML(path) {
+		return saveTOMLValue(path, data, key, value)
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid config file %s: top level must be a mapping", path)
+	}
+
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	replaced := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			root.Content[i+1] = valueNode
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	enc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func saveTOMLValue(path string, data []byte, key, value string) error {
+	entry := fmt.Sprintf("%s = %s", key, strconv.Quote(value))
+	var lines []string
+	if len(bytes.TrimSpace(data)) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+
+	insert := len(lines)
+	replaced := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			insert = i
+			break
+		}
+		if k, _, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(k) == key {
+			lines[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		for insert > 0 && strings.TrimSpace(lines[insert-1]) == "" {
+			insert--
+		}
+		lines = slices.Insert(lines, insert, entry)
+	}
+
+	out := strings.Join(lines, "\n") + "\n"
+	var doc map[string]interface{}
+	if _, err := toml.Decode(out, &doc); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(out), 0644)
+}
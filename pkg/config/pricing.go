@@ -0,0 +1,40 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+type ModelPrice struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+func (c Config) Price(model string) (ModelPrice, error) {
+	price, ok := c.ModelPrices[model]
+	if !ok {
+		return ModelPrice{}, fmt.Errorf("no price configured for model %q (add it under model_prices in %s)", model, c.FilePath)
+	}
+	return price, nil
+}
+
+func (c Config) PriceVersion() string {
+	models := make([]string, 0, len(c.ModelPrices))
+	for model := range c.ModelPrices {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	hasher := sha256.New()
+	for _, model := range models {
+		price := c.ModelPrices[model]
+		fmt.Fprintf(hasher, "%s=%g/%g;", model, price.Input, price.Output)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))[:12]
+}
+
+func (p ModelPrice) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
+}
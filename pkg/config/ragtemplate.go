@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+)
+
+const DefaultRagTemplate = `Use the following context to answer the user's question:
+
+{{range .Chunks}}--- Source: {{.Location}} ---
+{{.Text}}
+
+{{end}}User Question: {{.Query}}`
+
+type RagContext struct {
+	Query       string
+	TotalTokens int
+	Chunks      []RagChunk
+}
+
+type RagChunk struct {
+	Index     int
+	Filename  string
+	Location  string
+	StartLine int
+	EndLine   int
+	Section   string
+	Text      string
+	Score     float64
+}
+
+func ParseRagTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := RagContext{
+		Query:       "example question",
+		TotalTokens: 2,
+		Chunks:      []RagChunk{{Index: 1, Filename: "example.md", Location: "example.md:1-2", StartLine: 1, EndLine: 2, Text: "example", Score: 1}},
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func (c Config) RagTemplateFor(corpus string) (*template.Template, error) {
+	name, text := "rag_template", c.RagTemplate
+	if corpus != "" && c.Corpora[corpus].Template != "" {
+		name, text = fmt.Sprintf("corpora.%s.template", corpus), c.Corpora[corpus].Template
+	}
+	return ParseRagTemplate(name, text)
+}
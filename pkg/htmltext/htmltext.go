@@ -0,0 +1,196 @@
+package htmltext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true, "caption": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"li": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "title": true, "tr": true, "ul": true,
+	"annotation": true, "cite": true, "empty-line": true, "epigraph": true, "poem": true, "stanza": true,
+	"subtitle": true, "text-author": true, "v": true,
+}
+
+var skippedTags = map[string]bool{"script": true, "style": true, "binary": true}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+type writer struct {
+	sb           strings.Builder
+	pendingSpace bool
+}
+
+func (w *writer) atLineStart() bool {
+	s := w.sb.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+func (w *writer) newline() {
+	w.pendingSpace = false
+	if !w.atLineStart() {
+		w.sb.WriteByte('\n')
+	}
+}
+
+func (w *writer) text(s string, verbatim bool) {
+	if verbatim {
+		if w.pendingSpace && !w.atLineStart() {
+			w.sb.WriteByte(' ')
+		}
+		w.pendingSpace = false
+		w.sb.WriteString(s)
+		return
+	}
+	for i, word := range strings.Fields(s) {
+		if (i > 0 || w.pendingSpace || startsWithSpace(s)) && !w.atLineStart() {
+			w.sb.WriteByte(' ')
+		}
+		w.pendingSpace = false
+		w.sb.WriteString(word)
+	}
+	if s != "" && endsWithSpace(s) {
+		w.pendingSpace = true
+	}
+}
+
+func startsWithSpace(s string) bool {
+	return s != "" && strings.TrimLeft(s, " \t\r\n\f") != s
+}
+
+func endsWithSpace(s string) bool {
+	return strings.TrimRight(s, " \t\r\n\f") != s
+}
+
+func Text(markup string) string {
+	var w writer
+	verbatim := 0
+	for i := 0; i < len(markup); {
+		if markup[i] != '<' {
+			end := strings.IndexByte(markup[i:], '<')
+			if end < 0 {
+				end = len(markup) - i
+			}
+			w.text(html.UnescapeString(markup[i:i+end]), verbatim > 0)
+			i += end
+			continue
+		}
+
+		rest := markup[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			i += skipPast(rest, "-->")
+			continue
+		case strings.HasPrefix(rest, "<![CDATA["):
+			end := strings.Index(rest, "]]>")
+			if end < 0 {
+				end = len(rest)
+			}
+			w.text(rest[len("<![CDATA["):end], verbatim > 0)
+			i += min(end+3, len(rest))
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			i += skipPast(rest, ">")
+			continue
+		}
+
+		name, closing, length := parseTag(rest)
+		if length == 0 {
+			w.text("<", verbatim > 0)
+			i++
+			continue
+		}
+		selfClosing := strings.HasSuffix(rest[:length], "/>")
+		i += length
+
+		switch {
+		case skippedTags[name] && !closing && !selfClosing:
+			end := strings.Index(strings.ToLower(markup[i:]), "</"+name)
+			if end < 0 {
+				i = len(markup)
+			} else {
+				i += end
+				i += skipPast(markup[i:], ">")
+			}
+		case name == "br":
+			w.sb.WriteByte('\n')
+			w.pendingSpace = false
+		case name == "pre" || name == "code":
+			if name == "pre" {
+				w.newline()
+			}
+			if closing {
+				verbatim = max(verbatim-1, 0)
+			} else if !selfClosing {
+				verbatim++
+			}
+		case blockTags[name]:
+			w.newline()
+		case name == "td" || name == "th":
+			if !closing {
+				w.pendingSpace = true
+			}
+		}
+	}
+
+	lines := strings.Split(w.sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text := strings.Trim(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"), "\n")
+	if text == "" {
+		return ""
+	}
+	return text + "\n"
+}
+
+func skipPast(s, marker string) int {
+	if end := strings.Index(s, marker); end >= 0 {
+		return end + len(marker)
+	}
+	return len(s)
+}
+
+func parseTag(s string) (name string, closing bool, length int) {
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		closing = true
+		i++
+	}
+	start := i
+	for i < len(s) && (isLetter(s[i]) || i > start && (s[i] >= '0' && s[i] <= '9' || s[i] == '-' || s[i] == ':')) {
+		i++
+	}
+	if i == start {
+		return "", false, 0
+	}
+	name = strings.ToLower(s[start:i])
+	if colon := strings.LastIndexByte(name, ':'); colon >= 0 {
+		name = name[colon+1:]
+	}
+
+	var quote byte
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return name, closing, i + 1
+		case c == '<':
+			return "", false, 0
+		}
+	}
+	return "", false, 0
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
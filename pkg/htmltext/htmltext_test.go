@@ -0,0 +1,38 @@
+package htmltext
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"empty", "", ""},
+		{"plain", "hello world", "hello world\n"},
+		{"script and style", "<head><style>p { color: red }</style><script>if (a < b) alert(1)</script></head><p>kept</p>", "kept\n"},
+		{"uppercase script", "<SCRIPT type=\"text/javascript\">var x = '<p>';</SCRIPT>after", "after\n"},
+		{"unclosed script", "before<script>never closed", "before\n"},
+		{"named entities", "<p>Fish &amp; chips&nbsp;&mdash; &lt;tasty&gt;</p>", "Fish & chips — <tasty>\n"},
+		{"numeric entities", "It&#8217;s &#x201C;quoted&#x201D;", "It’s “quoted”\n"},
+		{"bare less-than", "<p>if a < b and b > c</p>", "if a < b and b > c\n"},
+		{"nested inline tags", "<p>one <b>two <i>three</i></b> four</p>", "one two three four\n"},
+		{"nested blocks", "<div><div><p>first</p></div><ul><li>a</li><li>b <em>c</em></li></ul></div>", "first\na\nb c\n"},
+		{"br", "line one<br>line two<br/>line three", "line one\nline two\nline three\n"},
+		{"no fused sentences", "<p>End.</p><p>Start.</p><h2>Title</h2>Body", "End.\nStart.\nTitle\nBody\n"},
+		{"pre is verbatim", "<p>Code:</p><pre>if a &lt; b {\n    return  a\n}</pre><p>done</p>", "Code:\nif a < b {\n    return  a\n}\ndone\n"},
+		{"inline code", "run <code>a  &amp;&amp;  b</code> now", "run a  &&  b now\n"},
+		{"table cells", "<table><tr><td>a</td><td>b</td></tr><tr><th>c</th><td>d</td></tr></table>", "a b\nc d\n"},
+		{"comments and doctype", "<!DOCTYPE html><!-- hidden <p>x</p> -->visible", "visible\n"},
+		{"cdata", "<p><![CDATA[x < y]]></p>", "x < y\n"},
+		{"attributes with angle brackets", `<a title="a > b" href="x">link</a>`, "link\n"},
+		{"namespaced fb2 tags", "<fb:section><fb:p>one</fb:p><fb:empty-line/><fb:p>two</fb:p></fb:section>", "one\ntwo\n"},
+		{"fb2 binary", `<binary id="cover" content-type="image/png">iVBORw0KGgo=</binary><p>text</p>`, "text\n"},
+		{"collapses blank lines", "<p>a</p><br><br><br><br><p>b</p>", "a\n\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.in); got != tt.want {
+				t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package mcp
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -28,14 +31,41 @@ type JSONRPCResponse struct {
 	ID int `json:"id"`
 }
 
+type ServerError struct {
+	Code    int
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("server error code %d: %s", e.Code, e.Message)
+}
+
 type Client struct {
 	cmd       *exec.Cmd
 	stdin     io.WriteCloser
-	stdout    *bufio.Scanner
+	stdout    *bufio.Reader
 	idCounter int
 	mu        sync.Mutex
+	writeMu   sync.Mutex
+	pending   map[int]chan callResult
+	done      chan struct{}
+	readErr   error
 }
 
+type callResult struct {
+	resp JSONRPCResponse
+	err  error
+}
+
+var MaxMessageBytes = 64 << 20
+
+var (
+	ErrConnectionClosed = errors.New("connection closed or response not received")
+	ErrMessageTooLarge  = errors.New("mcp response is too large")
+)
+
+var responseIDRegex = regexp.MustCompile(`"id"\s*:\s*(\d+)`)
+
 func NewClient(command string) (*Client, error) {
 	parts := strings.Fields(command)
 	if len(parts) == 0 {
@@ -58,18 +88,105 @@ func NewClient(command string) (*Client, error) {
 		return nil, err
 	}
 
-	scanner := bufio.NewScanner(stdoutPipe)
-	buf := make([]byte, 1024*1024*2)
-	scanner.Buffer(buf, 1024*1024*2)
+	client := newClient(stdin, stdoutPipe)
+	client.cmd = cmd
+	return client, client.initialize()
+}
 
+func newClient(stdin io.WriteCloser, stdout io.Reader) *Client {
 	client := &Client{
-		cmd:       cmd,
-		stdin:     stdin,
-		stdout:    scanner,
-		idCounter: 0,
+		stdin:   stdin,
+		stdout:  bufio.NewReaderSize(stdout, 64*1024),
+		pending: make(map[int]chan callResult),
+		done:    make(chan struct{}),
 	}
+	go client.readLoop()
+	return client
+}
 
-	return client, client.initialize()
+func (c *Client) readLoop() {
+	var err error
+	for {
+		var line []byte
+		line, err = readLine(c.stdout, MaxMessageBytes)
+		if errors.Is(err, errMessageTooLong) {
+			c.failOversized(line)
+			continue
+		}
+		if err != nil {
+			break
+		}
+
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(line, &resp); err != nil || resp.ID == 0 {
+			continue
+		}
+		c.deliver(resp.ID, callResult{resp: resp})
+	}
+
+	c.mu.Lock()
+	c.readErr = err
+	if errors.Is(err, io.EOF) {
+		c.readErr = ErrConnectionClosed
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *Client) deliver(id int, result callResult) bool {
+	c.mu.Lock()
+	ch, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+	if ok {
+		ch <- result
+	}
+	return ok
+}
+
+func (c *Client) failOversized(head []byte) {
+	err := fmt.Errorf("%w: larger than %d MB (raise mcp_max_message_mb in the config file)", ErrMessageTooLarge, MaxMessageBytes>>20)
+	if m := responseIDRegex.FindSubmatch(head); m != nil {
+		if id, convErr := strconv.Atoi(string(m[1])); convErr == nil && c.deliver(id, callResult{err: err}) {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[int]chan callResult)
+	c.mu.Unlock()
+	for _, ch := range pending {
+		ch <- callResult{err: err}
+	}
+}
+
+var errMessageTooLong = errors.New("message too long")
+
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line)+len(chunk) > max {
+			head := append(line, chunk...)
+			if len(head) > 256 {
+				head = head[:256]
+			}
+			for isPrefix {
+				if _, isPrefix, err = r.ReadLine(); err != nil {
+					return nil, err
+				}
+			}
+			return head, errMessageTooLong
+		}
+		line = append(line, chunk...)
+		if !isPrefix {
+			return line, nil
+		}
+	}
 }
 
 func (c *Client) initialize() error {
@@ -97,6 +214,8 @@ func (c *Client) Call(method string, params interface{}) (json.RawMessage, error
 	c.mu.Lock()
 	c.idCounter++
 	id := c.idCounter
+	ch := make(chan callResult, 1)
+	c.pending[id] = ch
 	c.mu.Unlock()
 
 	req := JSONRPCRequest{
@@ -108,39 +227,50 @@ func (c *Client) Call(method string, params interface{}) (json.RawMessage, error
 
 	bytes, err := json.Marshal(req)
 	if err != nil {
+		c.forget(id)
 		return nil, err
 	}
 
-	if _, err := c.stdin.Write(append(bytes, '\n')); err != nil {
+	if err := c.write(bytes); err != nil {
+		c.forget(id)
 		return nil, err
 	}
 
-	for c.stdout.Scan() {
-		line := c.stdout.Bytes()
-
-		var resp JSONRPCResponse
-		if err := json.Unmarshal(line, &resp); err != nil {
-			continue
+	select {
+	case result := <-ch:
+		if result.err != nil {
+			return nil, result.err
 		}
-
-		if resp.ID == id {
-			if resp.Error != nil {
-				return nil, fmt.Errorf("server error code %d: %s", resp.Error.Code, resp.Error.Message)
-			}
-			return resp.Result, nil
+		resp := result.resp
+		if resp.Error != nil {
+			return nil, &ServerError{Code: resp.Error.Code, Message: resp.Error.Message}
 		}
+		return resp.Result, nil
+	case <-c.done:
+		c.forget(id)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return nil, c.readErr
 	}
+}
 
-	if err := c.stdout.Err(); err != nil {
-		return nil, err
-	}
-	return nil, fmt.Errorf("connection closed or response not received")
+func (c *Client) forget(id int) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Client) write(line []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.stdin.Write(append(line, '\n'))
+	return err
 }
 
 func (c *Client) notify(method string, params interface{}) {
 	req := JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: params}
 	bytes, _ := json.Marshal(req)
-	c.stdin.Write(append(bytes, '\n'))
+	c.write(bytes)
 }
 
 func (c *Client) Close() {
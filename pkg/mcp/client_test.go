@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func fakeServer(t *testing.T, reply func(req JSONRPCRequest) string) *Client {
+	t.Helper()
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go func() {
+		defer respW.Close()
+		scanner := bufio.NewScanner(reqR)
+		for scanner.Scan() {
+			var req JSONRPCRequest
+			if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.ID == 0 {
+				continue
+			}
+			if _, err := io.WriteString(respW, reply(req)+"\n"); err != nil {
+				return
+			}
+		}
+	}()
+	c := newClient(reqW, respR)
+	t.Cleanup(func() {
+		c.Close()
+		<-c.done
+	})
+	return c
+}
+
+func TestCallReadsLongLines(t *testing.T) {
+	payload := strings.Repeat("A", 10<<20)
+	c := fakeServer(t, func(req JSONRPCRequest) string {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{"data":%q}}`, req.ID, payload)
+	})
+
+	raw, err := c.Call("tools/call", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct{ Data string }
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Data) != len(payload) {
+		t.Fatalf("got %d bytes, want %d", len(result.Data), len(payload))
+	}
+}
+
+func TestCallRejectsOversizedMessages(t *testing.T) {
+	saved := MaxMessageBytes
+	MaxMessageBytes = 1 << 20
+	t.Cleanup(func() { MaxMessageBytes = saved })
+
+	c := fakeServer(t, func(req JSONRPCRequest) string {
+		size := 10
+		if req.Method == "big" {
+			size = 2 << 20
+		}
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{"data":%q}}`, req.ID, strings.Repeat("A", size))
+	})
+
+	if _, err := c.Call("big", nil); !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("got %v, want ErrMessageTooLarge", err)
+	}
+	if _, err := c.Call("small", nil); err != nil {
+		t.Fatalf("the next call failed after an oversized message: %v", err)
+	}
+}
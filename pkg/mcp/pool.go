@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"fmt"
+	"sync"
+)
+
+type poolEntry struct {
+	client *Client
+	err    error
+	refs   int
+	ready  chan struct{}
+}
+
+var pool = struct {
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+}{entries: make(map[string]*poolEntry)}
+
+func Acquire(command string) (*Client, error) {
+	pool.mu.Lock()
+	e, ok := pool.entries[command]
+	if ok {
+		e.refs++
+		pool.mu.Unlock()
+		<-e.ready
+		if e.err != nil {
+			Release(command)
+			return nil, e.err
+		}
+		return e.client, nil
+	}
+
+	e = &poolEntry{refs: 1, ready: make(chan struct{})}
+	pool.entries[command] = e
+	pool.mu.Unlock()
+
+	e.client, e.err = NewClient(command)
+	close(e.ready)
+	if e.err != nil {
+		Release(command)
+		return nil, e.err
+	}
+	return e.client, nil
+}
+
+func Release(command string) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	e, ok := pool.entries[command]
+	if !ok {
+		return
+	}
+	e.refs--
+	if e.refs > 0 {
+		return
+	}
+	delete(pool.entries, command)
+	if e.client != nil {
+		e.client.Close()
+	}
+}
+
+func Reconnect(command string, old *Client) (*Client, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	e, ok := pool.entries[command]
+	if !ok {
+		return nil, fmt.Errorf("mcp server %s is not connected", command)
+	}
+	if e.client != old {
+		return e.client, nil
+	}
+	client, err := NewClient(command)
+	if err != nil {
+		if client != nil {
+			client.Close()
+		}
+		return nil, err
+	}
+	old.Close()
+	e.client = client
+	return client, nil
+}
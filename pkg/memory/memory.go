@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const DefaultFile = ".ai/memory.md"
+
+func ProjectRoot() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		for _, marker := range []string{".ai", ".git"} {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			return cwd
+		}
+	}
+}
+
+func Path(file string) string {
+	if file == "" {
+		file = DefaultFile
+	}
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(ProjectRoot(), file)
+}
+
+func Read(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read memory file %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+func Truncate(content string, budget int) string {
+	if len(content) <= budget {
+		return content
+	}
+	cut := content[len(content)-budget:]
+	if i := strings.Index(cut, "\n"); i >= 0 {
+		cut = cut[i+1:]
+	}
+	return "...(older notes omitted)\n" + cut
+}
+
+func Append(path, note string) error {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return fmt.Errorf("note is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create memory directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open memory file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "\n## %s\n%s\n", time.Now().Format("2006-01-02 15:04"), note)
+	return err
+}
+
+func Write(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create memory directory: %w", err)
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
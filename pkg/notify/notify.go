@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/agent"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+const (
+	ModeAuto   = "auto"
+	ModeAlways = "always"
+	ModeNever  = "never"
+)
+
+type Notifier struct {
+	mode      string
+	threshold time.Duration
+	warned    bool
+}
+
+func New(mode string, threshold time.Duration) (*Notifier, error) {
+	switch mode {
+	case "", ModeAuto:
+		mode = ModeAuto
+	case ModeAlways, ModeNever:
+	default:
+		return nil, fmt.Errorf("invalid notify mode %q (expected auto, always or never)", mode)
+	}
+	return &Notifier{mode: mode, threshold: threshold}, nil
+}
+
+func (n *Notifier) Handle(ev agent.Event) {
+	if n.mode == ModeNever {
+		return
+	}
+	if ev.Type != agent.EventTurnComplete && ev.Type != agent.EventTurnError {
+		return
+	}
+	if n.mode == ModeAuto && ev.Elapsed < n.threshold {
+		return
+	}
+
+	elapsed := ev.Elapsed.Round(time.Second)
+	title := fmt.Sprintf("ai finished in %s", elapsed)
+	body := firstLine(ev.Content)
+	if ev.Type == agent.EventTurnError {
+		title = fmt.Sprintf("ai failed after %s", elapsed)
+		body = firstLine(ev.Err.Error())
+	}
+	if body == "" {
+		body = "(no output)"
+	}
+
+	if err := Send(title, body); err != nil && !n.warned {
+		n.warned = true
+		ui.Printf(os.Stderr, ui.ColorRed, "Warning: desktop notification failed: %v\n", err)
+	}
+}
+
+func Send(title, body string) error {
+	var name string
+	var args []string
+
+	switch runtime.GOOS {
+	case "linux":
+		name = "notify-send"
+		args = []string{"--app-name=ai", title, body}
+	case "darwin":
+		name = "osascript"
+		args = []string{"-e", fmt.Sprintf("display notification %q with title %q", body, title)}
+	case "windows":
+		name = "powershell"
+		script := "[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null;" +
+			"$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02);" +
+			"$x = $t.GetElementsByTagName('text');" +
+			fmt.Sprintf("$x.Item(0).AppendChild($t.CreateTextNode('%s')) > $null;", psQuote(title)) +
+			fmt.Sprintf("$x.Item(1).AppendChild($t.CreateTextNode('%s')) > $null;", psQuote(body)) +
+			"[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('ai').Show([Windows.UI.Notifications.ToastNotification]::new($t))"
+		args = []string{"-NoProfile", "-NonInteractive", "-Command", script}
+	default:
+		return fmt.Errorf("notifications are not supported on %s", runtime.GOOS)
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s not found in PATH", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return exec.CommandContext(ctx, path, args...).Run()
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		s = s[:i]
+	}
+	if len([]rune(s)) > 200 {
+		s = string([]rune(s)[:200]) + "..."
+	}
+	return s
+}
+
+func psQuote(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
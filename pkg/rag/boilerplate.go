@@ -0,0 +1,125 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxBoilerplateLineLen = 120
+	minRepeatedLines      = 4
+)
+
+type BoilerplateFilter struct {
+	Patterns []string
+	Repeated bool
+}
+
+func (f BoilerplateFilter) compile() ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range f.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boilerplate pattern %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func (f BoilerplateFilter) apply(text string, patterns []*regexp.Regexp) string {
+	if len(patterns) == 0 && !f.Repeated {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	fenced := fencedLines(lines)
+	var repeated map[string]bool
+	if f.Repeated {
+		repeated = repeatedLines(lines, fenced)
+	}
+
+	kept := lines[:0]
+	for i, line := range lines {
+		if !fenced[i] && repeated[lineKey(line)] || matchesAny(line, patterns) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+func repeatedLines(lines []string, fenced []bool) map[string]bool {
+	counts := make(map[string]int)
+	for i, line := range lines {
+		if key := lineKey(line); !fenced[i] && hasAlphanumeric(key) && len(key) <= maxBoilerplateLineLen {
+			counts[key]++
+		}
+	}
+
+	repeated := make(map[string]bool)
+	for key, n := range counts {
+		if n >= minRepeatedLines && n*50 >= len(lines) {
+			repeated[key] = true
+		}
+	}
+	return repeated
+}
+
+func fencedLines(lines []string) []bool {
+	fenced := make([]bool, len(lines))
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			fenced[i] = true
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fenced[i] = true
+			fence = trimmed[:3]
+			for len(fence) < len(trimmed) && trimmed[len(fence)] == fence[0] {
+				fence += fence[:1]
+			}
+		}
+	}
+	return fenced
+}
+
+func hasAlphanumeric(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || r == '#' }) >= 0
+}
+
+func lineKey(line string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return '#'
+		}
+		return r
+	}, strings.TrimSpace(line))
+}
+
+func matchesAny(line string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameFilter(a, b BoilerplateFilter) bool {
+	if a.Repeated != b.Repeated || len(a.Patterns) != len(b.Patterns) {
+		return false
+	}
+	for i := range a.Patterns {
+		if a.Patterns[i] != b.Patterns[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBoilerplateRepeatedKeepsStructure(t *testing.T) {
+	var b strings.Builder
+	for page := 1; page <= 5; page++ {
+		fmt.Fprintf(&b, "ACME Corp - Internal\nPage %d of 5\n", page)
+		fmt.Fprintf(&b, "Section %d text.\n", page)
+		fmt.Fprintf(&b, "| %s |\n|---|---|\n", strings.Repeat("x", page))
+		b.WriteString("```go\nfunc f() error {\n\treturn nil\n}\n```\n")
+		b.WriteString("func g() {\n}\n\n---\n")
+	}
+
+	got := BoilerplateFilter{Repeated: true}.apply(b.String(), nil)
+
+	for _, dropped := range []string{"ACME Corp", "Page "} {
+		if strings.Contains(got, dropped) {
+			t.Errorf("kept the repeated %q line", dropped)
+		}
+	}
+	for kept, want := range map[string]int{"|---|---|": 5, "```go": 5, "```": 5, "\treturn nil": 5, "}": 10, "---": 5} {
+		if n := countLines(got, kept); n != want {
+			t.Errorf("kept %d of %d %q lines", n, want, kept)
+		}
+	}
+}
+
+func TestFencedLines(t *testing.T) {
+	lines := strings.Split("a\n````md\n```\nb\n```\n````\nc\n~~~\nd\n~~~\ne", "\n")
+	want := []bool{false, true, true, true, true, true, false, true, true, true, false}
+	got := fencedLines(lines)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d %q: fenced = %v, want %v", i, lines[i], got[i], want[i])
+		}
+	}
+}
+
+func countLines(text, line string) int {
+	n := 0
+	for _, l := range strings.Split(text, "\n") {
+		if l == line {
+			n++
+		}
+	}
+	return n
+}
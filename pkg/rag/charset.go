@@ -0,0 +1,180 @@
+package rag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"github.com/yuriiter/ai/pkg/ui"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var ErrUnknownEncoding = errors.New("binary data or an unknown text encoding")
+
+func detectCharset(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	}
+
+	if enc := guessUTF16(data); enc != "" {
+		return enc
+	}
+	if bytes.IndexByte(data, 0) != -1 {
+		return ""
+	}
+	if validUTF8Prefix(data) {
+		return "utf-8"
+	}
+
+	control := 0
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			control++
+		}
+	}
+	if control*20 > len(data) {
+		return ""
+	}
+	if isShiftJIS(data) {
+		return "shift_jis"
+	}
+	if isCyrillic1251(data) {
+		return "windows-1251"
+	}
+	return "windows-1252"
+}
+
+func isShiftJIS(data []byte) bool {
+	decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(data)
+	if err != nil || bytes.ContainsRune(decoded, utf8.RuneError) {
+		return false
+	}
+	var hiragana, japaneseRunes, other int
+	for _, r := range string(decoded) {
+		switch {
+		case r < 0x80:
+		case r >= 0x3040 && r <= 0x309F:
+			hiragana++
+			japaneseRunes++
+		case r >= 0x3000 && r <= 0x9FFF, r >= 0xFF00 && r <= 0xFFEF:
+			japaneseRunes++
+		default:
+			other++
+		}
+	}
+	return hiragana > 0 && japaneseRunes >= other*9
+}
+
+func isCyrillic1251(data []byte) bool {
+	isHigh := func(i int) bool { return i >= 0 && i < len(data) && data[i] >= 0xC0 }
+	var inRuns, isolated, lower, upper int
+	for i, b := range data {
+		if b < 0xC0 {
+			continue
+		}
+		if b >= 0xE0 {
+			lower++
+		} else {
+			upper++
+		}
+		if isHigh(i-1) || isHigh(i+1) {
+			inRuns++
+		} else {
+			isolated++
+		}
+	}
+	return inRuns > isolated*2 && lower >= upper
+}
+
+func guessUTF16(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	var evenZeros, oddZeros int
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	pairs := len(data) / 2
+	switch {
+	case oddZeros*10 > pairs*6 && evenZeros*10 < pairs:
+		return "utf-16le"
+	case evenZeros*10 > pairs*6 && oddZeros*10 < pairs:
+		return "utf-16be"
+	}
+	return ""
+}
+
+func validUTF8Prefix(data []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		if utf8.Valid(data) {
+			return true
+		}
+		data = data[:len(data)-1]
+	}
+	return false
+}
+
+func readTextFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	text, charset, err := decodeText(data)
+	if err != nil {
+		return "", err
+	}
+	if Verbose && charset != "utf-8" {
+		ui.Printf(Output, ui.ColorBlue, "Decoded %s as %s\n", path, charset)
+	}
+	return text, nil
+}
+
+func decodeText(data []byte) (string, string, error) {
+	charset := detectCharset(data)
+	var enc encoding.Encoding
+	switch charset {
+	case "utf-8":
+		data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+		if !utf8.Valid(data) {
+			return "", charset, fmt.Errorf("invalid UTF-8 text")
+		}
+		return string(data), charset, nil
+	case "utf-16le":
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case "utf-16be":
+		enc = unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	case "shift_jis":
+		enc = japanese.ShiftJIS
+	case "windows-1251":
+		enc = charmap.Windows1251
+	case "windows-1252":
+		enc = charmap.Windows1252
+	default:
+		return "", charset, ErrUnknownEncoding
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", charset, fmt.Errorf("failed to decode %s text: %w", charset, err)
+	}
+	if bytes.ContainsRune(decoded, utf8.RuneError) {
+		return "", charset, fmt.Errorf("text is not valid %s", charset)
+	}
+	return string(decoded), charset, nil
+}
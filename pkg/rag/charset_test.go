@@ -0,0 +1,83 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		file    string
+		charset string
+		want    string
+	}{
+		{"utf8.txt", "utf-8", "Grüße aus Köln"},
+		{"utf16le-bom.txt", "utf-16le", "Привет, мир!"},
+		{"utf16be-nobom.txt", "utf-16be", "without a byte order mark"},
+		{"latin1.txt", "windows-1252", "Saint-Honoré est fermé"},
+		{"latin1-mostly-accents.txt", "windows-1252", "Ça été à l'été où Ève élève"},
+		{"cp1251.txt", "windows-1251", "Съешь же ещё этих мягких французских булок"},
+		{"shift-jis.txt", "shift_jis", "これは日本語のテキストです"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "charset", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, charset, err := decodeText(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if charset != tt.charset {
+				t.Errorf("charset %q, want %q", charset, tt.charset)
+			}
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("decoded %q, want it to contain %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeTextRejectsBinary(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "charset", "binary.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := decodeText(data); !errors.Is(err, ErrUnknownEncoding) {
+		t.Fatalf("got %v, want ErrUnknownEncoding", err)
+	}
+}
+
+func TestIngestReportsSkippedFilesSeparately(t *testing.T) {
+	dir := t.TempDir()
+	binary, err := os.ReadFile(filepath.Join("testdata", "charset", "binary.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"notes.txt": "plain notes about the project",
+		"disk.iso":  string(binary),
+		"blob.txt":  string(binary),
+	})
+
+	e := newTestEngine(t)
+	var out strings.Builder
+	Output = &out
+	defer func() { Output = os.Stdout }()
+	if err := e.IngestGlobs(context.Background(), []string{filepath.Join(dir, "*")}); err != nil {
+		t.Fatal(err)
+	}
+
+	report := out.String()
+	if !strings.Contains(report, "Skipped 2 unsupported file(s)") || strings.Contains(report, "Failed to read") {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+	if e.ChunkCount() != 1 {
+		t.Fatalf("indexed %d chunks, want 1", e.ChunkCount())
+	}
+}
@@ -0,0 +1,167 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+const converterTimeout = 2 * time.Minute
+
+var (
+	Converters = map[string]string{}
+	Verbose    bool
+	Output     io.Writer = os.Stdout
+)
+
+var ErrUnsupportedType = errors.New("unsupported type")
+
+var defaultConverters = map[string]string{
+	".doc": "soffice",
+	".ppt": "soffice",
+}
+
+var documentExtensions = map[string]bool{
+	".doc": true, ".docx": true, ".docm": true, ".dot": true, ".dotx": true,
+	".odt": true, ".ott": true, ".fodt": true, ".rtf": true, ".wpd": true,
+	".ppt": true, ".pptx": true, ".pps": true, ".ppsx": true, ".odp": true,
+	".epub": true, ".docbook": true, ".opml": true, ".ipynb": true,
+}
+
+func convertExternal(path, ext string) (string, error) {
+	converter, err := pickConverter(ext)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	hasher.Write([]byte(converter))
+	hasher.Write(data)
+	cachePath := filepath.Join(os.Getenv("HOME"), ".cache", "ai-rag", "converted", hex.EncodeToString(hasher.Sum(nil))+".txt")
+
+	if cached, err := os.ReadFile(cachePath); err == nil {
+		if Verbose {
+			ui.Printf(Output, ui.ColorBlue, "Converted %s with %s (cached)\n", path, converter)
+		}
+		return string(cached), nil
+	}
+
+	var text string
+	switch converter {
+	case "pandoc":
+		text, err = convertWithPandoc(path)
+	case "soffice":
+		text, err = convertWithSoffice(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed to convert %s: %w", converter, path, err)
+	}
+
+	if Verbose {
+		ui.Printf(Output, ui.ColorBlue, "Converted %s with %s\n", path, converter)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+		os.WriteFile(cachePath, []byte(text), 0644)
+	}
+	return text, nil
+}
+
+func pickConverter(ext string) (string, error) {
+	choice, ok := Converters[ext]
+	if !ok {
+		if !documentExtensions[ext] {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedType, ext)
+		}
+		choice = defaultConverters[ext]
+	}
+
+	switch choice {
+	case "none":
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedType, ext)
+	case "pandoc", "soffice":
+		if _, err := lookPathConverter(choice); err != nil {
+			return "", fmt.Errorf("%w: %s (converter %s not found on PATH)", ErrUnsupportedType, ext, choice)
+		}
+		return choice, nil
+	case "":
+		for _, candidate := range []string{"pandoc", "soffice"} {
+			if _, err := lookPathConverter(candidate); err == nil {
+				return candidate, nil
+			}
+		}
+		return "", fmt.Errorf("%w: %s (install pandoc or LibreOffice to convert it)", ErrUnsupportedType, ext)
+	default:
+		return "", fmt.Errorf("unknown converter %q for %s (expected pandoc, soffice, or none)", choice, ext)
+	}
+}
+
+func lookPathConverter(name string) (string, error) {
+	if name == "soffice" {
+		if p, err := exec.LookPath("soffice"); err == nil {
+			return p, nil
+		}
+		return exec.LookPath("libreoffice")
+	}
+	return exec.LookPath(name)
+}
+
+func convertWithPandoc(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), converterTimeout)
+	defer cancel()
+
+	out, err := os.CreateTemp("", "ai-convert-*.txt")
+	if err != nil {
+		return "", err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	cmd := exec.CommandContext(ctx, "pandoc", "--to", "plain", "--wrap", "none", "--output", out.Name(), path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	b, err := os.ReadFile(out.Name())
+	return string(b), err
+}
+
+func convertWithSoffice(path string) (string, error) {
+	bin, err := lookPathConverter("soffice")
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), converterTimeout)
+	defer cancel()
+
+	outDir, err := os.MkdirTemp("", "ai-convert-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(outDir)
+
+	cmd := exec.CommandContext(ctx, bin, "--headless", "--convert-to", "txt:Text", "--outdir", outDir, path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	b, err := os.ReadFile(filepath.Join(outDir, base+".txt"))
+	return string(b), err
+}
@@ -0,0 +1,34 @@
+package rag
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPickConverterOnlyConvertsDocuments(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	old := Converters
+	defer func() { Converters = old }()
+	Converters = map[string]string{".rst": "pandoc", ".pptx": "none"}
+
+	tests := []struct {
+		ext  string
+		want string
+	}{
+		{".iso", "unsupported type: .iso"},
+		{".so", "unsupported type: .so"},
+		{".pptx", "unsupported type: .pptx"},
+		{".odt", "install pandoc or LibreOffice"},
+		{".rst", "converter pandoc not found"},
+	}
+	for _, tt := range tests {
+		_, err := pickConverter(tt.ext)
+		if !errors.Is(err, ErrUnsupportedType) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("pickConverter(%q) = %v, want an unsupported type error containing %q", tt.ext, err, tt.want)
+		}
+		if strings.HasSuffix(tt.want, tt.ext) && err.Error() != tt.want {
+			t.Errorf("pickConverter(%q) = %v, want exactly %q", tt.ext, err, tt.want)
+		}
+	}
+}
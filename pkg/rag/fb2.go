@@ -0,0 +1,216 @@
+package rag
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var xmlEncodingDecl = regexp.MustCompile(`^\s*<\?xml[^>]*encoding\s*=\s*["']([^"']+)["']`)
+
+var fb2Charsets = map[string]encoding.Encoding{
+	"windows-1251": charmap.Windows1251,
+	"cp1251":       charmap.Windows1251,
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+	"koi8-r":       charmap.KOI8R,
+	"koi8-u":       charmap.KOI8U,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"iso-8859-5":   charmap.ISO8859_5,
+}
+
+var fb2Paragraphs = map[string]bool{"p": true, "v": true, "text-author": true, "subtitle": true}
+
+type fb2Writer struct {
+	lines    []string
+	sections []string
+	titles   []string
+}
+
+func (w *fb2Writer) section() string {
+	for i := len(w.titles) - 1; i >= 0; i-- {
+		if w.titles[i] != "" {
+			return w.titles[i]
+		}
+	}
+	return ""
+}
+
+func (w *fb2Writer) line(s string) {
+	w.lines = append(w.lines, s)
+	w.sections = append(w.sections, w.section())
+}
+
+func (w *fb2Writer) blank() {
+	if len(w.lines) > 0 && w.lines[len(w.lines)-1] != "" {
+		w.line("")
+	}
+}
+
+func (w *fb2Writer) heading(level int, text string) {
+	w.blank()
+	w.line(strings.Repeat("#", min(max(level, 1), 6)) + " " + text)
+	w.line("")
+}
+
+func readFB2(path string) (string, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return parseFB2(data)
+}
+
+func parseFB2(data []byte) (string, []string, error) {
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	decoded := false
+	if charset := detectCharset(data); charset != "utf-8" && (!xmlEncodingDecl.Match(data) || strings.HasPrefix(charset, "utf-16")) {
+		text, _, err := decodeText(data)
+		if err != nil {
+			return "", nil, err
+		}
+		data, decoded = []byte(text), true
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		label = strings.ToLower(label)
+		if decoded || label == "utf-8" || label == "utf8" {
+			return input, nil
+		}
+		enc, ok := fb2Charsets[label]
+		if !ok {
+			return nil, fmt.Errorf("unsupported encoding %q", label)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+
+	var w fb2Writer
+	var para, title strings.Builder
+	var stack []string
+	inBody, inTitle, inPara, sectionTitle := false, false, false, false
+	depth := 0
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if len(w.lines) > 0 {
+				break
+			}
+			return "", nil, fmt.Errorf("invalid FB2: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			switch {
+			case name == "description" || name == "binary" || name == "image":
+				dec.Skip()
+				continue
+			case name == "a" && attr(t, "type") == "note":
+				dec.Skip()
+				continue
+			case name == "body":
+				inBody = true
+				w.titles = []string{""}
+				depth = 0
+			case !inBody:
+			case name == "section":
+				depth++
+				w.titles = append(w.titles, "")
+				w.blank()
+			case name == "title":
+				inTitle = true
+				sectionTitle = len(stack) > 0 && (stack[len(stack)-1] == "section" || stack[len(stack)-1] == "body")
+				title.Reset()
+			case name == "empty-line" || name == "stanza":
+				w.blank()
+			case fb2Paragraphs[name] && !inTitle:
+				inPara = true
+				para.Reset()
+			case name == "p" && inTitle && title.Len() > 0:
+				title.WriteString(" ")
+			}
+			stack = append(stack, name)
+
+		case xml.EndElement:
+			name := t.Name.Local
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			switch {
+			case name == "body":
+				inBody = false
+				w.blank()
+			case !inBody:
+			case name == "section":
+				depth--
+				w.titles = w.titles[:len(w.titles)-1]
+				w.blank()
+			case name == "title":
+				inTitle = false
+				text := strings.Join(strings.Fields(title.String()), " ")
+				switch {
+				case text == "":
+				case sectionTitle:
+					w.titles[len(w.titles)-1] = text
+					w.heading(depth+1, text)
+				default:
+					w.line(text)
+				}
+			case name == "stanza":
+				w.blank()
+			case fb2Paragraphs[name] && inPara:
+				inPara = false
+				text := strings.Join(strings.Fields(para.String()), " ")
+				switch {
+				case text == "":
+				case name == "subtitle":
+					w.heading(depth+2, text)
+				default:
+					w.line(text)
+				}
+			}
+
+		case xml.CharData:
+			switch {
+			case !inBody:
+			case inTitle:
+				title.Write(t)
+			case inPara:
+				para.Write(t)
+			}
+		}
+	}
+
+	for len(w.lines) > 0 && w.lines[len(w.lines)-1] == "" {
+		w.lines = w.lines[:len(w.lines)-1]
+		w.sections = w.sections[:len(w.sections)-1]
+	}
+	if len(w.lines) == 0 {
+		return "", nil, nil
+	}
+	return strings.Join(w.lines, "\n") + "\n", w.sections, nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
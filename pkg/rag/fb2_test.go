@@ -0,0 +1,72 @@
+package rag
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestParseFB2(t *testing.T) {
+	text, sections, err := readFB2(filepath.Join("testdata", "fb2", "book.fb2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "fb2", "book.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if text != string(want) {
+		t.Errorf("output differs from %s:\n%s", golden, text)
+	}
+
+	for _, bad := range []string{"iVBORw0KGgo", "Petrov", "sf", "[1]"} {
+		if strings.Contains(text, bad) {
+			t.Errorf("output contains %q", bad)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(sections) != len(lines) {
+		t.Fatalf("got %d sections for %d lines", len(sections), len(lines))
+	}
+	section := make(map[string]string)
+	for i, line := range lines {
+		section[line] = sections[i]
+	}
+	for line, want := range map[string]string{
+		"The train arrived late.":    "Chapter One",
+		"Rails hum at night,":        "Chapter One",
+		"Nobody came back the same.": "The Return",
+		"All roads lead somewhere.":  "The Quiet Station",
+	} {
+		if got, ok := section[line]; !ok || got != want {
+			t.Errorf("section of %q is %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestParseFB2Windows1251(t *testing.T) {
+	text, sections, err := readFB2(filepath.Join("testdata", "fb2", "book-cp1251.fb2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## Глава первая", "Анна ждала & считала минуты.", "### Возвращение", "Никто не вернулся прежним."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output does not contain %q:\n%s", want, text)
+		}
+	}
+	if len(sections) == 0 || sections[len(sections)-1] == "" {
+		t.Errorf("the last line has no section: %q", sections)
+	}
+}
@@ -0,0 +1,89 @@
+package rag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (c Chunk) Location() string {
+	switch {
+	case c.StartLine == 0 && c.Section != "":
+		return fmt.Sprintf("%s (%s)", c.Filename, c.Section)
+	case c.StartLine == 0:
+		return c.Filename
+	case c.StartLine == c.EndLine:
+		return fmt.Sprintf("%s:%d", c.Filename, c.StartLine)
+	default:
+		return fmt.Sprintf("%s:%d-%d", c.Filename, c.StartLine, c.EndLine)
+	}
+}
+
+func sectionAt(sections []string, line int) string {
+	if line < 1 || line > len(sections) {
+		return ""
+	}
+	return sections[line-1]
+}
+
+func sourceLines(original, cleaned string) []int {
+	source := strings.Split(original, "\n")
+	lines := strings.Split(cleaned, "\n")
+	numbers := make([]int, len(lines))
+
+	j := 0
+	for i, line := range lines {
+		key := lineIdentity(line)
+		for j < len(source) && lineIdentity(source[j]) != key {
+			j++
+		}
+		if j == len(source) {
+			return nil
+		}
+		numbers[i] = j + 1
+		j++
+	}
+	return numbers
+}
+
+func lineIdentity(line string) string {
+	line = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\r':
+			return ' '
+		case r < 32:
+			return -1
+		}
+		return r
+	}, line)
+	return strings.Join(strings.Fields(line), " ")
+}
+
+func lineLocator(text string, numbers []int) func(start, end int) (int, int) {
+	if numbers == nil {
+		return func(int, int) (int, int) { return 0, 0 }
+	}
+
+	var breaks []int
+	offset := 0
+	for _, r := range text {
+		if r == '\n' {
+			breaks = append(breaks, offset)
+		}
+		offset++
+	}
+
+	lineAt := func(pos int) int {
+		idx := sort.SearchInts(breaks, pos)
+		if idx >= len(numbers) {
+			idx = len(numbers) - 1
+		}
+		return numbers[idx]
+	}
+	return func(start, end int) (int, int) {
+		if end > start {
+			end--
+		}
+		return lineAt(start), lineAt(end)
+	}
+}
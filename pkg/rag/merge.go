@@ -0,0 +1,91 @@
+package rag
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+func MergeCaches(outPath string, inputs []string) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("no caches to merge")
+	}
+
+	var merged *EmbeddingCache
+	var base string
+	dim := 0
+	seenPatterns := make(map[string]bool)
+
+	for _, input := range inputs {
+		cache, err := readCache(input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", input, err)
+		}
+		if cache.Metric == "" {
+			cache.Metric = MetricCosine
+		}
+		cacheDim := cacheDimension(cache)
+
+		if merged == nil {
+			merged = &EmbeddingCache{
+				Metric:      cache.Metric,
+				Normalized:  cache.Normalized,
+				Provider:    cache.Provider,
+				Model:       cache.Model,
+				QueryPrefix: cache.QueryPrefix,
+				DocPrefix:   cache.DocPrefix,
+				Version:     1,
+			}
+			base, dim = input, cacheDim
+		} else if err := mergeable(merged, dim, cache, cacheDim); err != nil {
+			return fmt.Errorf("%w: cannot merge %s into %s: %w", ErrIncompatibleCache, input, base, err)
+		}
+		if dim == 0 {
+			dim = cacheDim
+		}
+
+		merged.Chunks = append(merged.Chunks, cache.Chunks...)
+		merged.Summaries = append(merged.Summaries, cache.Summaries...)
+		merged.FileMetadata = append(merged.FileMetadata, cache.FileMetadata...)
+		for _, pattern := range cache.GlobPatterns {
+			if !seenPatterns[pattern] {
+				seenPatterns[pattern] = true
+				merged.GlobPatterns = append(merged.GlobPatterns, pattern)
+			}
+		}
+	}
+
+	merged.CreatedAt = time.Now()
+	if err := writeCache(outPath, merged); err != nil {
+		return err
+	}
+
+	ui.Printf(Output, ui.ColorGreen, "Merged %d caches into %s (%d chunks, %d files)\n", len(inputs), outPath, len(merged.Chunks), len(merged.FileMetadata))
+	return nil
+}
+
+func mergeable(merged *EmbeddingCache, dim int, cache *EmbeddingCache, cacheDim int) error {
+	switch {
+	case cache.Provider != merged.Provider || cache.Model != merged.Model:
+		return fmt.Errorf("embedding model differs (%s/%s vs %s/%s)", cache.Provider, cache.Model, merged.Provider, merged.Model)
+	case dim != 0 && cacheDim != 0 && cacheDim != dim:
+		return fmt.Errorf("embedding dimension differs (%d vs %d)", cacheDim, dim)
+	case cache.Metric != merged.Metric:
+		return fmt.Errorf("similarity metric differs (%s vs %s)", cache.Metric, merged.Metric)
+	case cache.Normalized != merged.Normalized:
+		return fmt.Errorf("embedding normalization differs")
+	case cache.QueryPrefix != merged.QueryPrefix || cache.DocPrefix != merged.DocPrefix:
+		return fmt.Errorf("embedding prefixes differ")
+	}
+	return nil
+}
+
+func cacheDimension(cache *EmbeddingCache) int {
+	for _, chunk := range cache.Chunks {
+		if len(chunk.Vector) > 0 {
+			return len(chunk.Vector)
+		}
+	}
+	return 0
+}
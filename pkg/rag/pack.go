@@ -0,0 +1,274 @@
+package rag
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+const (
+	packFormat   = "ai-ragpack"
+	packVersion  = 1
+	manifestName = "manifest.json"
+	packDataName = "cache.gob"
+)
+
+type PackManifest struct {
+	Format      string     `json:"format"`
+	Version     int        `json:"version"`
+	Corpus      string     `json:"corpus"`
+	Root        string     `json:"root,omitempty"`
+	Provider    string     `json:"provider"`
+	Model       string     `json:"model"`
+	Metric      string     `json:"metric"`
+	Normalized  bool       `json:"normalized"`
+	QueryPrefix string     `json:"query_prefix,omitempty"`
+	DocPrefix   string     `json:"doc_prefix,omitempty"`
+	Chunks      int        `json:"chunks"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Files       []PackFile `json:"files"`
+}
+
+type PackFile struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+type PackDrift struct {
+	Missing  []string
+	Modified []string
+}
+
+func ExportPack(cachePath, outPath string) (*PackManifest, error) {
+	cache, err := readCache(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	if cache.Metric == "" {
+		cache.Metric = MetricCosine
+	}
+
+	portable := make(map[string]string)
+	manifest := &PackManifest{
+		Format:      packFormat,
+		Version:     packVersion,
+		Corpus:      cache.Settings.Corpus,
+		Root:        cache.Root,
+		Provider:    cache.Provider,
+		Model:       cache.Model,
+		Metric:      cache.Metric,
+		Normalized:  cache.Normalized,
+		QueryPrefix: cache.QueryPrefix,
+		DocPrefix:   cache.DocPrefix,
+		Chunks:      len(cache.Chunks),
+		CreatedAt:   cache.CreatedAt,
+	}
+	for i, m := range cache.FileMetadata {
+		rel := m.Rel
+		if rel == "" {
+			rel = packPath(cache.Root, m.Path)
+		}
+		if m.Hash == "" {
+			return nil, fmt.Errorf("%s has no content hashes; rebuild it with 'ai rag index' before exporting", cachePath)
+		}
+		portable[m.Path] = rel
+		cache.FileMetadata[i] = FileMetadata{Path: rel, Rel: rel, Size: m.Size, Hash: m.Hash}
+		manifest.Files = append(manifest.Files, PackFile{Path: rel, Hash: m.Hash})
+	}
+	for i := range cache.Chunks {
+		cache.Chunks[i].Filename = portableName(portable, cache.Root, cache.Chunks[i].Filename)
+	}
+	for i := range cache.Summaries {
+		cache.Summaries[i].Filename = portableName(portable, cache.Root, cache.Summaries[i].Filename)
+	}
+	cache.Root = ""
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(cache); err != nil {
+		return nil, fmt.Errorf("failed to encode cache: %w", err)
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writePack(outPath, manifestJSON, data.Bytes()); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func portableName(portable map[string]string, root, name string) string {
+	if rel, ok := portable[name]; ok {
+		return rel
+	}
+	return packPath(root, name)
+}
+
+func packPath(root, path string) string {
+	if filepath.IsAbs(path) && root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+func writePack(outPath string, manifest, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outPath, err)
+	}
+	defer os.Remove(file.Name())
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, entry := range []struct {
+		name string
+		body []byte
+	}{{manifestName, manifest}, {packDataName, data}} {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.body)), ModTime: time.Now()}
+		if err := tw.WriteHeader(header); err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := tw.Write(entry.body); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	for _, c := range []io.Closer{tw, gz, file} {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", outPath, err)
+		}
+	}
+	return os.Rename(file.Name(), outPath)
+}
+
+func ReadPackManifest(packPath string) (*PackManifest, error) {
+	manifest, _, err := readPack(packPath, true)
+	return manifest, err
+}
+
+func readPack(packPath string, manifestOnly bool) (*PackManifest, *EmbeddingCache, error) {
+	file, err := os.Open(packPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not a RAG pack: %w", packPath, err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *PackManifest
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s is not a RAG pack: %w", packPath, err)
+		}
+
+		switch header.Name {
+		case manifestName:
+			manifest = &PackManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, nil, fmt.Errorf("%s: invalid manifest: %w", packPath, err)
+			}
+			if manifest.Format != packFormat {
+				return nil, nil, fmt.Errorf("%s is not a RAG pack (format %q)", packPath, manifest.Format)
+			}
+			if manifest.Version != packVersion {
+				return nil, nil, fmt.Errorf("%s uses pack format version %d, but this version of ai reads version %d; export it again with a matching version", packPath, manifest.Version, packVersion)
+			}
+			if manifestOnly {
+				return manifest, nil, nil
+			}
+		case packDataName:
+			if manifest == nil {
+				return nil, nil, fmt.Errorf("%s: cache data appears before the manifest", packPath)
+			}
+			var cache EmbeddingCache
+			if err := gob.NewDecoder(tr).Decode(&cache); err != nil {
+				return nil, nil, fmt.Errorf("%s: failed to decode cache: %w", packPath, err)
+			}
+			return manifest, &cache, nil
+		}
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("%s is not a RAG pack (no manifest)", packPath)
+	}
+	return nil, nil, fmt.Errorf("%s has no cache data", packPath)
+}
+
+func (e *Engine) ImportPack(packPath, cachePath, root string) (*PackManifest, PackDrift, error) {
+	var drift PackDrift
+	manifest, cache, err := readPack(packPath, false)
+	if err != nil {
+		return nil, drift, err
+	}
+	if cache.Provider != "" && !slices.Contains(Providers, cache.Provider) {
+		return nil, drift, fmt.Errorf("%s was built with the %s embedder, which is not available here", packPath, cache.Provider)
+	}
+	if ok, reason := e.compatible(cache); !ok {
+		return nil, drift, fmt.Errorf("%w: %s does not match the local configuration: %s", ErrIncompatibleCache, packPath, reason)
+	}
+
+	if root == "" {
+		root = cacheRoot()
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return nil, drift, err
+	}
+	for i, m := range cache.FileMetadata {
+		local := localPackPath(root, m.Rel)
+		m.Path = local
+		info, err := os.Stat(local)
+		if err != nil {
+			drift.Missing = append(drift.Missing, local)
+			cache.FileMetadata[i] = m
+			continue
+		}
+		if hash, err := hashFile(local); err != nil || hash != m.Hash {
+			drift.Modified = append(drift.Modified, local)
+		} else {
+			m.ModTime, m.Size = info.ModTime(), info.Size()
+		}
+		cache.FileMetadata[i] = m
+	}
+	for i := range cache.Chunks {
+		cache.Chunks[i].Filename = localPackPath(root, cache.Chunks[i].Filename)
+	}
+	for i := range cache.Summaries {
+		cache.Summaries[i].Filename = localPackPath(root, cache.Summaries[i].Filename)
+	}
+	cache.Root = root
+
+	if err := writeCache(cachePath, cache); err != nil {
+		return nil, drift, err
+	}
+	ui.Printf(Output, ui.ColorGreen, "Installed %s as %s (%d chunks, %d files)\n", packPath, cachePath, len(cache.Chunks), len(cache.FileMetadata))
+	return manifest, drift, nil
+}
+
+func localPackPath(root, rel string) string {
+	local := filepath.FromSlash(rel)
+	if filepath.IsAbs(local) || root == cacheRoot() {
+		return local
+	}
+	return filepath.Join(root, local)
+}
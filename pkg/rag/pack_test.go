@@ -0,0 +1,71 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportPackResolvesAgainstRoot(t *testing.T) {
+	src, elsewhere := t.TempDir(), t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"a.txt": "alpha document about apples",
+		"b.txt": "beta document about bananas",
+	})
+	t.Chdir(src)
+
+	globs := []string{"*.txt"}
+	cachePath := filepath.Join(t.TempDir(), "cache.gob")
+	e := newTestEngine(t)
+	if err := e.IngestGlobs(context.Background(), globs); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SaveEmbeddings(cachePath, globs); err != nil {
+		t.Fatal(err)
+	}
+	packPath := filepath.Join(t.TempDir(), "docs.ragpack")
+	manifest, err := ExportPack(cachePath, packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Root != src {
+		t.Fatalf("manifest root is %q, want %q", manifest.Root, src)
+	}
+
+	t.Chdir(elsewhere)
+	installed := filepath.Join(t.TempDir(), "installed.gob")
+	_, drift, err := newTestEngine(t).ImportPack(packPath, installed, manifest.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift.Missing) != 0 || len(drift.Modified) != 0 {
+		t.Fatalf("got drift %+v against the recorded root", drift)
+	}
+
+	cache, err := readCache(installed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Root != src {
+		t.Errorf("installed cache root is %q, want %q", cache.Root, src)
+	}
+	for _, m := range cache.FileMetadata {
+		if _, err := os.Stat(m.Path); err != nil {
+			t.Errorf("file %q does not resolve: %v", m.Path, err)
+		}
+	}
+	for _, c := range cache.Chunks {
+		if filepath.Dir(c.Filename) != src {
+			t.Errorf("chunk points at %q, want a file in %q", c.Filename, src)
+		}
+	}
+
+	_, drift, err = newTestEngine(t).ImportPack(packPath, installed, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift.Missing) != 2 {
+		t.Errorf("importing against the working directory found %d missing files, want 2", len(drift.Missing))
+	}
+}
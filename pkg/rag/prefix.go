@@ -0,0 +1,26 @@
+package rag
+
+import "strings"
+
+var defaultPrefixes = []struct {
+	family   string
+	query    string
+	document string
+}{
+	{"e5", "query: ", "passage: "},
+	{"bge", "Represent this sentence for searching relevant passages: ", ""},
+	{"nomic-embed", "search_query: ", "search_document: "},
+}
+
+func DefaultPrefixes(model string) (query, document string) {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, p := range defaultPrefixes {
+		if strings.HasPrefix(name, p.family) || strings.Contains(name, "-"+p.family) {
+			return p.query, p.document
+		}
+	}
+	return "", ""
+}
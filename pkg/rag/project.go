@@ -0,0 +1,56 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+type projectIndex struct {
+	Root     string   `json:"root"`
+	Patterns []string `json:"patterns"`
+	Types    []string `json:"types,omitempty"`
+}
+
+func projectIndexPath(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(os.Getenv("HOME"), ".cache", "ai-rag", "project_"+hex.EncodeToString(sum[:])[:16]+".json")
+}
+
+func RememberProject(globPatterns, types []string) error {
+	root := cacheRoot()
+	if root == "" || len(globPatterns) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(projectIndex{Root: root, Patterns: globPatterns, Types: NormalizeTypes(types)})
+	if err != nil {
+		return err
+	}
+	path := projectIndexPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func ProjectCache() (globPatterns, types []string, cachePath string, ok bool) {
+	root := cacheRoot()
+	if root == "" {
+		return nil, nil, "", false
+	}
+	data, err := os.ReadFile(projectIndexPath(root))
+	if err != nil {
+		return nil, nil, "", false
+	}
+	var index projectIndex
+	if err := json.Unmarshal(data, &index); err != nil || index.Root != root || len(index.Patterns) == 0 {
+		return nil, nil, "", false
+	}
+	cachePath = GetDefaultCachePath(append([]string(nil), index.Patterns...), index.Types)
+	if _, err := os.Stat(cachePath); err != nil {
+		return nil, nil, "", false
+	}
+	return index.Patterns, index.Types, cachePath, true
+}
@@ -7,11 +7,13 @@ import (
 	"encoding/gob"
 	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"runtime"
@@ -26,6 +28,7 @@ import (
 	"github.com/nlpodyssey/cybertron/pkg/tasks/textencoding"
 	"github.com/rs/zerolog"
 	"github.com/taylorskalyo/goreader/epub"
+	"github.com/yuriiter/ai/pkg/htmltext"
 	"github.com/yuriiter/ai/pkg/ui"
 )
 
@@ -36,27 +39,114 @@ type Embedder interface {
 type LocalEmbedder struct {
 	interfaceModel textencoding.Interface
 	mu             sync.Mutex
+	Workers        int
 }
 
-func NewLocalEmbedder() (*LocalEmbedder, error) {
-	fmt.Printf("%sInitializing local embedding model (downloading if needed)...%s\n", ui.ColorBlue, ui.ColorReset)
+var (
+	ErrNoFiles           = errors.New("no files found matching patterns")
+	ErrIncompatibleCache = errors.New("incompatible embedding cache")
+	ErrInvalidTopK       = errors.New("the number of results must be at least 1")
+)
+
+const (
+	localModelName = "sentence-transformers/all-MiniLM-L6-v2"
+	spinnerFrames  = `|/-\`
+)
+
+func NewLocalEmbedder(ctx context.Context, modelName string) (*LocalEmbedder, error) {
+	ui.Printf(Output, ui.ColorBlue, "Initializing local embedding model (downloading if needed)...\n")
 
 	zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
-	model, err := tasks.Load[textencoding.Interface](&tasks.Config{
-		ModelsDir: filepath.Join(os.Getenv("HOME"), ".cybertron"),
-		ModelName: "sentence-transformers/all-MiniLM-L6-v2",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to load local model: %w", err)
+	modelsDir := filepath.Join(os.Getenv("HOME"), ".cybertron")
+	modelPath := filepath.Join(modelsDir, modelName)
+	loadDir := modelsDir
+	if _, err := os.Stat(modelPath); err != nil {
+		if err := os.MkdirAll(modelsDir, 0755); err != nil {
+			return nil, err
+		}
+		tmp, err := os.MkdirTemp(modelsDir, ".download-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+		loadDir = tmp
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	type loadResult struct {
+		model textencoding.Interface
+		err   error
+	}
+	done := make(chan loadResult, 1)
+	go func() {
+		model, err := tasks.Load[textencoding.Interface](&tasks.Config{
+			ModelsDir: loadDir,
+			ModelName: modelName,
+		})
+		done <- loadResult{model: model, err: err}
+	}()
+
+	start := time.Now()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	cancelled := ctx.Done()
+	var cancelErr error
+	for frame := 0; ; frame++ {
+		select {
+		case res := <-done:
+			ui.ClearProgress()
+			if cancelErr != nil {
+				return nil, fmt.Errorf("loading local model cancelled: %w", cancelErr)
+			}
+			if res.err != nil {
+				return nil, fmt.Errorf("failed to load local model: %w", res.err)
+			}
+			if loadDir != modelsDir {
+				if err := installModel(filepath.Join(loadDir, modelName), modelPath); err != nil {
+					return nil, err
+				}
+			}
+			return &LocalEmbedder{interfaceModel: res.model}, nil
+		case <-cancelled:
+			ui.ClearProgress()
+			if loadDir == modelsDir {
+				return nil, fmt.Errorf("loading local model cancelled: %w", ctx.Err())
+			}
+			cancelErr, cancelled = ctx.Err(), nil
+			stop()
+			ui.Printf(Output, ui.ColorRed, "Stopping the model download and removing partial files (press Ctrl+C again to quit now)...\n")
+		case <-ticker.C:
+			if cancelErr == nil {
+				elapsed := time.Since(start).Truncate(time.Second)
+				ui.SetProgress(fmt.Sprintf("%c Loading %s... %s", spinnerFrames[frame%len(spinnerFrames)], modelName, elapsed))
+			}
+		}
 	}
-	return &LocalEmbedder{interfaceModel: model}, nil
+}
+
+func installModel(downloaded, modelPath string) error {
+	if err := os.MkdirAll(filepath.Dir(modelPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(downloaded, modelPath); err != nil {
+		if _, statErr := os.Stat(modelPath); statErr == nil {
+			return nil
+		}
+		return fmt.Errorf("failed to install the local model: %w", err)
+	}
+	return nil
 }
 
 func (l *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	results := make([][]float32, len(texts))
 
-	numWorkers := runtime.NumCPU()
+	numWorkers := l.Workers
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
 	if len(texts) < numWorkers {
 		numWorkers = len(texts)
 	}
@@ -77,7 +167,7 @@ func (l *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32,
 			for j := range jobs {
 				vec, err := l.safeEncode(ctx, j.text)
 				if err != nil {
-					fmt.Printf("\nWarning: Skipping chunk %d due to encoding error: %v\n", j.index, err)
+					ui.Printf(os.Stderr, ui.ColorRed, "Warning: Skipping chunk %d due to encoding error: %v\n", j.index, err)
 					continue
 				}
 
@@ -125,45 +215,182 @@ func (l *LocalEmbedder) safeEncode(ctx context.Context, text string) ([]float32,
 }
 
 type Chunk struct {
-	Text     string
+	Text      string
+	Filename  string
+	StartLine int
+	EndLine   int
+	Section   string
+	Vector    []float32
+}
+
+type Summary struct {
 	Filename string
+	Text     string
 	Vector   []float32
 }
 
+type IndexSettings struct {
+	Corpus       string
+	Patterns     []string
+	ChunkSize    int
+	ChunkOverlap int
+	Embedder     string
+	Types        []string
+}
+
+type SummarizeFunc func(ctx context.Context, filename, content string) (string, error)
+
 type FileMetadata struct {
 	Path    string
+	Rel     string
 	ModTime time.Time
 	Size    int64
+	Hash    string
+}
+
+func (m FileMetadata) key() string {
+	if m.Rel != "" {
+		return m.Rel
+	}
+	return filepath.Clean(m.Path)
 }
 
 type EmbeddingCache struct {
 	Chunks       []Chunk
+	Summaries    []Summary
+	Settings     IndexSettings
+	Metric       string
+	Normalized   bool
+	Boilerplate  BoilerplateFilter
 	GlobPatterns []string
 	Provider     string
 	Model        string
+	QueryPrefix  string
+	DocPrefix    string
 	Version      int
 	CreatedAt    time.Time
 	FileMetadata []FileMetadata
+	Root         string
 	ContentHash  string
 }
 
 type Engine struct {
-	embedder Embedder
-	Chunks   []Chunk
+	embedder       Embedder
+	embedderMu     sync.Mutex
+	mu             sync.RWMutex
+	Chunks         []Chunk
+	Summaries      []Summary
+	Settings       IndexSettings
+	Metric         string
+	Normalize      bool
+	Boilerplate    BoilerplateFilter
+	Provider       string
+	Model          string
+	APIKey         string
+	BaseURL        string
+	QueryPrefix    string
+	DocPrefix      string
+	ExtractWorkers int
+	EmbedWorkers   int
+	EmbedBatchSize int
 }
 
-func New() (*Engine, error) {
-	emb, err := NewLocalEmbedder()
-	if err != nil {
-		return nil, err
-	}
+const (
+	MetricCosine = "cosine"
+	MetricDot    = "dot"
+	MetricL2     = "l2"
+)
 
+func New() (*Engine, error) {
 	return &Engine{
-		embedder: emb,
-		Chunks:   make([]Chunk, 0),
+		Chunks: make([]Chunk, 0),
 	}, nil
 }
 
+func NewWithEmbedder(emb Embedder) (*Engine, error) {
+	e := &Engine{
+		Chunks:   make([]Chunk, 0),
+		embedder: emb,
+	}
+	if remote, ok := emb.(*RemoteEmbedder); ok {
+		e.Provider, e.Model = ProviderOpenAI, remote.Model
+	}
+	return e, nil
+}
+
+func (e *Engine) provider() string {
+	if e.Provider == "" {
+		return ProviderLocal
+	}
+	return e.Provider
+}
+
+func (e *Engine) model() string {
+	if e.Model == "" {
+		return DefaultModel(e.provider())
+	}
+	return e.Model
+}
+
+func (e *Engine) newEmbedder(ctx context.Context) (Embedder, error) {
+	switch e.provider() {
+	case ProviderLocal:
+		emb, err := NewLocalEmbedder(ctx, e.model())
+		if err != nil {
+			return nil, err
+		}
+		emb.Workers = e.EmbedWorkers
+		return emb, nil
+	case ProviderOpenAI:
+		return NewRemoteEmbedder(e.APIKey, e.BaseURL, e.model()), nil
+	}
+	return nil, fmt.Errorf("unsupported embedding provider %q (supported: %s)", e.Provider, strings.Join(Providers, ", "))
+}
+
+func (e *Engine) embed(ctx context.Context, texts []string, prefix string) ([][]float32, error) {
+	e.embedderMu.Lock()
+	if e.embedder == nil {
+		emb, err := e.newEmbedder(ctx)
+		if err != nil {
+			e.embedderMu.Unlock()
+			return nil, err
+		}
+		e.embedder = emb
+	}
+	e.embedderMu.Unlock()
+
+	if prefix != "" {
+		prefixed := make([]string, len(texts))
+		for i, text := range texts {
+			prefixed[i] = prefix + text
+		}
+		texts = prefixed
+	}
+
+	vectors, err := e.embedder.Embed(ctx, texts)
+	if err != nil || !e.Normalize {
+		return vectors, err
+	}
+	for _, vec := range vectors {
+		normalize(vec)
+	}
+	return vectors, nil
+}
+
+func normalize(vec []float32) {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v * v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vec {
+		vec[i] /= norm
+	}
+}
+
 func calculateContentHash(files []string) (string, error) {
 	hasher := sha256.New()
 
@@ -183,7 +410,7 @@ func calculateContentHash(files []string) (string, error) {
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
-func getFileMetadata(files []string) ([]FileMetadata, error) {
+func getFileMetadata(files []string, root string, withHash bool) ([]FileMetadata, error) {
 	var metadata []FileMetadata
 
 	for _, file := range files {
@@ -192,16 +419,52 @@ func getFileMetadata(files []string) ([]FileMetadata, error) {
 			return nil, err
 		}
 
-		metadata = append(metadata, FileMetadata{
+		m := FileMetadata{
 			Path:    file,
+			Rel:     relativePath(root, file),
 			ModTime: info.ModTime(),
 			Size:    info.Size(),
-		})
+		}
+		if withHash {
+			if m.Hash, err = hashFile(file); err != nil {
+				return nil, err
+			}
+		}
+		metadata = append(metadata, m)
 	}
 
 	return metadata, nil
 }
 
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func cacheRoot() string {
+	root, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return root
+}
+
+func relativePath(root, path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil || root == "" {
+		return filepath.Clean(path)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return abs
+	}
+	return filepath.ToSlash(rel)
+}
+
 func (e *Engine) ValidateCache(cachePath string, globPatterns []string) (bool, string) {
 	file, err := os.Open(cachePath)
 	if err != nil {
@@ -215,6 +478,10 @@ func (e *Engine) ValidateCache(cachePath string, globPatterns []string) (bool, s
 		return false, "failed to decode cache"
 	}
 
+	if ok, reason := e.compatible(&cache); !ok {
+		return false, reason
+	}
+
 	if len(cache.GlobPatterns) != len(globPatterns) {
 		return false, "pattern count mismatch"
 	}
@@ -230,7 +497,7 @@ func (e *Engine) ValidateCache(cachePath string, globPatterns []string) (bool, s
 		}
 	}
 
-	currentFiles := FindFiles(globPatterns)
+	currentFiles := e.findFiles(globPatterns)
 	if len(currentFiles) == 0 {
 		return false, "no files found matching patterns"
 	}
@@ -239,71 +506,262 @@ func (e *Engine) ValidateCache(cachePath string, globPatterns []string) (bool, s
 		return false, fmt.Sprintf("file count changed: cached=%d vs current=%d", len(cache.FileMetadata), len(currentFiles))
 	}
 
-	currentMetadata, err := getFileMetadata(currentFiles)
+	root := cacheRoot()
+	currentMetadata, err := getFileMetadata(currentFiles, root, false)
 	if err != nil {
 		return false, "failed to read current file metadata"
 	}
 
-	cachedMap := make(map[string]FileMetadata)
+	cachedByKey := make(map[string]FileMetadata)
 	for _, m := range cache.FileMetadata {
-		cachedMap[m.Path] = m
+		cachedByKey[m.key()] = m
 	}
 
-	for _, current := range currentMetadata {
-		cached, exists := cachedMap[current.Path]
+	renamed := make(map[string]string)
+	updated := cache.Root != root
+	var unmatched []int
+	for i, current := range currentMetadata {
+		cached, exists := cachedByKey[current.Rel]
 		if !exists {
-			return false, fmt.Sprintf("new file detected: %s", current.Path)
+			unmatched = append(unmatched, i)
+			continue
 		}
+		delete(cachedByKey, current.Rel)
 
 		if !current.ModTime.Equal(cached.ModTime) || current.Size != cached.Size {
-			return false, fmt.Sprintf("file changed: %s", current.Path)
+			if cached.Hash == "" || current.Size != cached.Size {
+				return false, fmt.Sprintf("file changed: %s", current.Path)
+			}
+			if hash, err := hashFile(current.Path); err != nil || hash != cached.Hash {
+				return false, fmt.Sprintf("file changed: %s", current.Path)
+			}
+			updated = true
+		}
+		currentMetadata[i].Hash = cached.Hash
+		if cached.Hash == "" {
+			currentMetadata[i].Hash, _ = hashFile(current.Path)
+			updated = true
+		}
+		if cached.Path != current.Path {
+			renamed[cached.Path] = current.Path
+		}
+	}
+
+	if len(unmatched) > 0 {
+		byHash := make(map[string][]FileMetadata)
+		for _, m := range cachedByKey {
+			if m.Hash != "" {
+				byHash[m.Hash] = append(byHash[m.Hash], m)
+			}
+		}
+		for _, i := range unmatched {
+			current := currentMetadata[i]
+			hash, err := hashFile(current.Path)
+			if err != nil || len(byHash[hash]) == 0 {
+				return false, fmt.Sprintf("new file detected: %s", current.Path)
+			}
+			cached := byHash[hash][0]
+			byHash[hash] = byHash[hash][1:]
+			currentMetadata[i].Hash = hash
+			renamed[cached.Path] = current.Path
+		}
+	}
+
+	if len(renamed) > 0 || updated {
+		cache.Root = root
+		cache.FileMetadata = currentMetadata
+		if err := remapCache(cachePath, &cache, renamed); err != nil {
+			return false, fmt.Sprintf("failed to update cache: %v", err)
 		}
 	}
 
 	return true, ""
 }
 
-func (e *Engine) SaveEmbeddings(filepath string, globPatterns []string) error {
-	files := FindFiles(globPatterns)
-	metadata, err := getFileMetadata(files)
+func (e *Engine) compatible(cache *EmbeddingCache) (bool, string) {
+	if e.Settings.Corpus != "" && !sameSettings(cache.Settings, e.Settings) {
+		return false, "corpus settings changed"
+	}
+	if !sameStrings(NormalizeTypes(cache.Settings.Types), NormalizeTypes(e.Settings.Types)) {
+		return false, "file type filter changed"
+	}
+
+	if cache.Metric == "" {
+		cache.Metric = MetricCosine
+	}
+	if cache.Metric != e.metric() {
+		return false, fmt.Sprintf("similarity metric changed: cached=%s vs current=%s", cache.Metric, e.metric())
+	}
+	if cache.Normalized != e.Normalize {
+		return false, "embedding normalization changed"
+	}
+	if !sameFilter(cache.Boilerplate, e.Boilerplate) {
+		return false, "boilerplate filter changed"
+	}
+	if cache.Provider == "" {
+		cache.Provider = ProviderLocal
+	}
+	if cache.Provider != e.provider() {
+		return false, fmt.Sprintf("embedding provider changed: cached=%s vs current=%s", cache.Provider, e.provider())
+	}
+	if cache.Model != e.model() {
+		return false, fmt.Sprintf("embedding model changed: cached=%s vs current=%s", cache.Model, e.model())
+	}
+	if cache.QueryPrefix != e.QueryPrefix || cache.DocPrefix != e.DocPrefix {
+		return false, "embedding prefixes changed"
+	}
+	return true, ""
+}
+
+func (e *Engine) StaleFiles(cachePath string, globPatterns []string) (changed, removed []string, err error) {
+	file, err := os.Open(cachePath)
 	if err != nil {
-		return fmt.Errorf("failed to get file metadata: %w", err)
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var cache EmbeddingCache
+	if err := gob.NewDecoder(file).Decode(&cache); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode cache: %w", err)
+	}
+	if ok, reason := e.compatible(&cache); !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrIncompatibleCache, reason)
 	}
 
-	contentHash, err := calculateContentHash(files)
+	current, err := getFileMetadata(e.findFiles(globPatterns), cacheRoot(), false)
 	if err != nil {
-		return fmt.Errorf("failed to calculate content hash: %w", err)
+		return nil, nil, err
 	}
 
-	cache := EmbeddingCache{
-		Chunks:       e.Chunks,
-		GlobPatterns: globPatterns,
-		Provider:     "local",
-		Model:        "sentence-transformers/all-MiniLM-L6-v2",
-		Version:      1,
-		CreatedAt:    time.Now(),
-		FileMetadata: metadata,
-		ContentHash:  contentHash,
+	cachedByKey := make(map[string]FileMetadata)
+	for _, m := range cache.FileMetadata {
+		cachedByKey[m.key()] = m
+	}
+	for _, m := range current {
+		cached, exists := cachedByKey[m.Rel]
+		delete(cachedByKey, m.Rel)
+		if exists && cached.Path == m.Path {
+			if m.ModTime.Equal(cached.ModTime) && m.Size == cached.Size {
+				continue
+			}
+			if hash, err := hashFile(m.Path); err == nil && hash == cached.Hash {
+				continue
+			}
+		}
+		if exists && cached.Path != m.Path {
+			removed = append(removed, cached.Path)
+		}
+		changed = append(changed, m.Path)
+	}
+	for _, m := range cachedByKey {
+		removed = append(removed, m.Path)
+	}
+	sort.Strings(removed)
+	return changed, removed, nil
+}
+
+func remapCache(cachePath string, cache *EmbeddingCache, renamed map[string]string) error {
+	for i := range cache.Chunks {
+		if path, ok := renamed[cache.Chunks[i].Filename]; ok {
+			cache.Chunks[i].Filename = path
+		}
+	}
+	for i := range cache.Summaries {
+		if path, ok := renamed[cache.Summaries[i].Filename]; ok {
+			cache.Summaries[i].Filename = path
+		}
+	}
+
+	if err := writeCache(cachePath, cache); err != nil {
+		return err
 	}
 
-	file, err := os.Create(filepath)
+	if len(renamed) > 0 {
+		ui.Printf(Output, ui.ColorBlue, "Remapped %d renamed or moved file(s) in the embedding cache:\n", len(renamed))
+		oldPaths := make([]string, 0, len(renamed))
+		for old := range renamed {
+			oldPaths = append(oldPaths, old)
+		}
+		sort.Strings(oldPaths)
+		for _, old := range oldPaths {
+			ui.Printf(Output, ui.ColorBlue, "  %s -> %s\n", old, renamed[old])
+		}
+	}
+	return nil
+}
+
+func writeCache(cachePath string, cache *EmbeddingCache) error {
+	file, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create cache file: %w", err)
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
 
 	encoder := gob.NewEncoder(file)
 	if err := encoder.Encode(cache); err != nil {
+		file.Close()
 		return fmt.Errorf("failed to encode cache: %w", err)
 	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(file.Name(), cachePath); err != nil {
+		return fmt.Errorf("failed to replace cache file: %w", err)
+	}
+	return nil
+}
 
-	fmt.Printf("%sEmbeddings saved to %s (%d chunks, %d files)%s\n",
-		ui.ColorGreen, filepath, len(e.Chunks), len(files), ui.ColorReset)
+func (e *Engine) SaveEmbeddings(filepath string, globPatterns []string) error {
+	chunks, files, err := e.saveCache(filepath, globPatterns)
+	if err != nil {
+		return err
+	}
+
+	ui.Printf(Output, ui.ColorGreen, "Embeddings saved to %s (%d chunks, %d files)\n", filepath, chunks, files)
 	return nil
 }
 
-func (e *Engine) LoadEmbeddings(filepath string) (*EmbeddingCache, error) {
-	file, err := os.Open(filepath)
+func (e *Engine) saveCache(filepath string, globPatterns []string) (int, int, error) {
+	files := e.findFiles(globPatterns)
+	root := cacheRoot()
+	metadata, err := getFileMetadata(files, root, true)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+
+	contentHash, err := calculateContentHash(files)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to calculate content hash: %w", err)
+	}
+
+	chunks, summaries := e.snapshot()
+	cache := EmbeddingCache{
+		Chunks:       chunks,
+		Summaries:    summaries,
+		Settings:     e.Settings,
+		Metric:       e.metric(),
+		Normalized:   e.Normalize,
+		Boilerplate:  e.Boilerplate,
+		GlobPatterns: globPatterns,
+		Provider:     e.provider(),
+		Model:        e.model(),
+		QueryPrefix:  e.QueryPrefix,
+		DocPrefix:    e.DocPrefix,
+		Version:      1,
+		CreatedAt:    time.Now(),
+		FileMetadata: metadata,
+		Root:         root,
+		ContentHash:  contentHash,
+	}
+
+	if err := writeCache(filepath, &cache); err != nil {
+		return 0, 0, err
+	}
+	return len(chunks), len(files), nil
+}
+
+func readCache(cachePath string) (*EmbeddingCache, error) {
+	file, err := os.Open(cachePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open cache file: %w", err)
 	}
@@ -314,15 +772,30 @@ func (e *Engine) LoadEmbeddings(filepath string) (*EmbeddingCache, error) {
 	if err := decoder.Decode(&cache); err != nil {
 		return nil, fmt.Errorf("failed to decode cache: %w", err)
 	}
+	return &cache, nil
+}
 
-	e.Chunks = cache.Chunks
-	fmt.Printf("%sLoaded %d cached embeddings from %s%s\n",
-		ui.ColorGreen, len(e.Chunks), filepath, ui.ColorReset)
-	fmt.Printf("%s  Patterns: %s | Provider: %s | Model: %s | Created: %s%s\n",
-		ui.ColorBlue, strings.Join(cache.GlobPatterns, ", "), cache.Provider, cache.Model,
-		cache.CreatedAt.Format("2006-01-02 15:04"), ui.ColorReset)
+func (e *Engine) LoadEmbeddings(filepath string) (*EmbeddingCache, error) {
+	cache, err := readCache(filepath)
+	if err != nil {
+		return nil, err
+	}
 
-	return &cache, nil
+	e.mu.Lock()
+	e.Chunks = cache.Chunks
+	e.Summaries = cache.Summaries
+	e.mu.Unlock()
+	e.Metric = cache.Metric
+	e.Normalize = cache.Normalized
+	e.Provider = cache.Provider
+	e.Model = cache.Model
+	e.QueryPrefix = cache.QueryPrefix
+	e.DocPrefix = cache.DocPrefix
+	ui.Printf(Output, ui.ColorGreen, "Loaded %d cached embeddings from %s\n", len(cache.Chunks), filepath)
+	ui.Printf(Output, ui.ColorBlue, "  Patterns: %s | Provider: %s | Model: %s | Metric: %s | Created: %s\n",
+		strings.Join(cache.GlobPatterns, ", "), cache.Provider, cache.Model, e.metric(), cache.CreatedAt.Format("2006-01-02 15:04"))
+
+	return cache, nil
 }
 
 func (e *Engine) CacheExists(filepath string) bool {
@@ -330,7 +803,37 @@ func (e *Engine) CacheExists(filepath string) bool {
 	return err == nil
 }
 
-func GetDefaultCachePath(globPatterns []string) string {
+func sameSettings(a, b IndexSettings) bool {
+	if a.Corpus != b.Corpus || a.ChunkSize != b.ChunkSize || a.ChunkOverlap != b.ChunkOverlap || a.Embedder != b.Embedder {
+		return false
+	}
+	pa := append([]string(nil), a.Patterns...)
+	pb := append([]string(nil), b.Patterns...)
+	sort.Strings(pa)
+	sort.Strings(pb)
+	return sameStrings(pa, pb) && sameStrings(NormalizeTypes(a.Types), NormalizeTypes(b.Types))
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func CorpusCachePath(name string) string {
+	cacheDir := filepath.Join(os.Getenv("HOME"), ".cache", "ai-rag")
+	os.MkdirAll(cacheDir, 0755)
+
+	return filepath.Join(cacheDir, fmt.Sprintf("corpus_%s.gob", name))
+}
+
+func GetDefaultCachePath(globPatterns, types []string) string {
 	sort.Strings(globPatterns)
 
 	cwd, err := os.Getwd()
@@ -339,6 +842,9 @@ func GetDefaultCachePath(globPatterns []string) string {
 	}
 
 	combined := fmt.Sprintf("%s:%s", cwd, strings.Join(globPatterns, ";"))
+	if types := NormalizeTypes(types); len(types) > 0 {
+		combined += ":types=" + strings.Join(types, ",")
+	}
 
 	hasher := sha256.New()
 	hasher.Write([]byte(combined))
@@ -351,51 +857,242 @@ func GetDefaultCachePath(globPatterns []string) string {
 }
 
 func (e *Engine) IngestGlobs(ctx context.Context, globPatterns []string) error {
-	files := FindFiles(globPatterns)
+	files := e.findFiles(globPatterns)
+	if len(files) == 0 {
+		return ErrNoFiles
+	}
+
+	chunks, err := e.ingestFiles(ctx, files, false)
+	if len(chunks) > 0 {
+		e.mu.Lock()
+		e.Chunks = append(e.Chunks, chunks...)
+		e.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("no text content extracted")
+	}
+	return nil
+}
+
+const refreshGroupSize = 20
+
+func (e *Engine) Refresh(ctx context.Context, changed, removed []string) error {
+	e.dropFiles(removed)
+	for start := 0; start < len(changed); start += refreshGroupSize {
+		group := changed[start:min(start+refreshGroupSize, len(changed))]
+		chunks, err := e.ingestFiles(ctx, group, true)
+		if err != nil {
+			return err
+		}
+		e.dropFiles(group)
+		e.mu.Lock()
+		e.Chunks = append(e.Chunks, chunks...)
+		e.mu.Unlock()
+	}
+	return nil
+}
+
+func (e *Engine) UpdateFromCache(ctx context.Context, cachePath string, globPatterns []string, summarize SummarizeFunc) error {
+	if len(e.findFiles(globPatterns)) == 0 {
+		return ErrNoFiles
+	}
+	changed, removed, err := e.StaleFiles(cachePath, globPatterns)
+	if err != nil {
+		if errors.Is(err, ErrIncompatibleCache) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrIncompatibleCache, err)
+	}
+	if _, err := e.LoadEmbeddings(cachePath); err != nil {
+		return fmt.Errorf("%w: %v", ErrIncompatibleCache, err)
+	}
+
+	ui.Printf(Output, ui.ColorBlue, "Re-embedding %d new or changed file(s) and dropping %d removed file(s); the rest is kept from the cache.\n",
+		len(changed), len(removed))
+	if err := e.Refresh(ctx, changed, removed); err != nil {
+		return err
+	}
+	if e.ChunkCount() == 0 {
+		return fmt.Errorf("no text content extracted")
+	}
+	if summarize != nil {
+		if err := e.BuildSummaries(ctx, summarize); err != nil {
+			return err
+		}
+	}
+	return e.SaveEmbeddings(cachePath, globPatterns)
+}
+
+func (e *Engine) dropFiles(files []string) {
 	if len(files) == 0 {
-		return fmt.Errorf("no files found matching patterns")
+		return
+	}
+	drop := make(map[string]bool, len(files))
+	for _, f := range files {
+		drop[f] = true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	chunks := make([]Chunk, 0, len(e.Chunks))
+	for _, c := range e.Chunks {
+		if !drop[c.Filename] {
+			chunks = append(chunks, c)
+		}
+	}
+	e.Chunks = chunks
+	summaries := make([]Summary, 0, len(e.Summaries))
+	for _, sm := range e.Summaries {
+		if !drop[sm.Filename] {
+			summaries = append(summaries, sm)
+		}
+	}
+	e.Summaries = summaries
+}
+
+func (e *Engine) ChunkCount() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.Chunks)
+}
+
+func (e *Engine) snapshot() ([]Chunk, []Summary) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.Chunks, e.Summaries
+}
+
+func (e *Engine) ingestFiles(ctx context.Context, files []string, quiet bool) ([]Chunk, error) {
+	boilerplate, err := e.Boilerplate.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	out := Output
+	setProgress, clearProgress := ui.SetProgress, ui.ClearProgress
+	if quiet {
+		out = io.Discard
+		setProgress, clearProgress = func(string) {}, func() {}
 	}
 
-	fmt.Printf("%sRAG: Found %d files. Processing...%s\n", ui.ColorBlue, len(files), ui.ColorReset)
+	ui.Printf(out, ui.ColorBlue, "RAG: Found %d files. Processing...\n", len(files))
 
 	var textsToEmbed []string
-	var mapIndexToMeta []struct {
-		Text     string
-		Filename string
+	var mapIndexToMeta []Chunk
+
+	workers := e.ExtractWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	type extracted struct {
+		content  string
+		lines    []int
+		sections []string
+		err      error
+	}
+
+	slots := make([]chan extracted, len(files))
+	for i := range slots {
+		slots[i] = make(chan extracted, 1)
 	}
 
+	window := make(chan struct{}, workers*2)
+	jobs := make(chan int)
+	go func() {
+		for i := range files {
+			window <- struct{}{}
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				content, ext, sections, err := extractText(files[i])
+				var lines []int
+				if err == nil {
+					cleaned := cleanText(e.Boilerplate.apply(cleanText(content), boilerplate))
+					if textExtensions[ext] || sections != nil {
+						lines = sourceLines(content, cleaned)
+					}
+					content = cleaned
+				}
+				slots[i] <- extracted{content: content, lines: lines, sections: sections, err: err}
+			}
+		}()
+	}
+
+	var skipped, failures []string
 	for i, file := range files {
-		content, err := ExtractText(file)
-		if err != nil {
-			fmt.Printf("\rSkipping %s: %v", file, err)
+		res := <-slots[i]
+		<-window
+
+		if errors.Is(res.err, ErrUnsupportedType) || errors.Is(res.err, ErrUnknownEncoding) {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", file, res.err))
 			continue
 		}
-
-		content = cleanText(content)
+		if res.err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", file, res.err))
+			continue
+		}
+		content := res.content
 
 		if content == "" {
+			setProgress(fmt.Sprintf("Processed %d/%d files...", i+1, len(files)))
 			continue
 		}
 
-		chunks := chunkText(content, 800, 100)
-		for _, c := range chunks {
-			textsToEmbed = append(textsToEmbed, c)
-			mapIndexToMeta = append(mapIndexToMeta, struct {
-				Text     string
-				Filename string
-			}{Text: c, Filename: file})
+		chunkSize, overlap := 800, 100
+		if e.Settings.ChunkSize > 0 {
+			chunkSize, overlap = e.Settings.ChunkSize, e.Settings.ChunkOverlap
+		}
+		locate := lineLocator(content, res.lines)
+		for _, c := range chunkText(content, chunkSize, overlap) {
+			chunk := Chunk{Text: c.text, Filename: file}
+			chunk.StartLine, chunk.EndLine = locate(c.start, c.end)
+			if res.sections != nil {
+				chunk.Section = sectionAt(res.sections, chunk.StartLine)
+				chunk.StartLine, chunk.EndLine = 0, 0
+			}
+			textsToEmbed = append(textsToEmbed, c.text)
+			mapIndexToMeta = append(mapIndexToMeta, chunk)
+		}
+		setProgress(fmt.Sprintf("Processed %d/%d files...", i+1, len(files)))
+	}
+	clearProgress()
+
+	if len(skipped) > 0 {
+		ui.Printf(out, ui.ColorBlue, "Skipped %d unsupported file(s):\n", len(skipped))
+		for _, f := range skipped {
+			ui.Printf(out, "", "  %s\n", f)
+		}
+	}
+	if len(failures) > 0 {
+		ui.Printf(out, ui.ColorRed, "Failed to read %d file(s):\n", len(failures))
+		for _, f := range failures {
+			ui.Printf(out, "", "  %s\n", f)
 		}
-		fmt.Printf("\rProcessed %d/%d files...", i+1, len(files))
 	}
-	fmt.Println()
 
 	if len(textsToEmbed) == 0 {
-		return fmt.Errorf("no text content extracted")
+		return nil, nil
 	}
 
-	fmt.Printf("Generating embeddings for %d chunks...\n", len(textsToEmbed))
+	ui.Printf(out, "", "Generating embeddings for %d chunks...\n", len(textsToEmbed))
 
-	batchSize := 100
+	batchSize := e.EmbedBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	var chunks []Chunk
 
 	for i := 0; i < len(textsToEmbed); i += batchSize {
 		end := i + batchSize
@@ -404,9 +1101,9 @@ func (e *Engine) IngestGlobs(ctx context.Context, globPatterns []string) error {
 		}
 
 		batch := textsToEmbed[i:end]
-		vectors, err := e.embedder.Embed(ctx, batch)
+		vectors, err := e.embed(ctx, batch, e.DocPrefix)
 		if err != nil {
-			return fmt.Errorf("embedding error: %w", err)
+			return chunks, fmt.Errorf("embedding error: %w", err)
 		}
 
 		for j, vec := range vectors {
@@ -414,42 +1111,191 @@ func (e *Engine) IngestGlobs(ctx context.Context, globPatterns []string) error {
 				continue
 			}
 
-			meta := mapIndexToMeta[i+j]
-			e.Chunks = append(e.Chunks, Chunk{
-				Text:     meta.Text,
-				Filename: meta.Filename,
-				Vector:   vec,
-			})
+			chunk := mapIndexToMeta[i+j]
+			chunk.Vector = vec
+			chunks = append(chunks, chunk)
 		}
 
 		progress := float64(end) / float64(len(textsToEmbed)) * 100
-		fmt.Printf("\rProgress: %.1f%% (%d/%d chunks)", progress, end, len(textsToEmbed))
+		setProgress(fmt.Sprintf("Progress: %.1f%% (%d/%d chunks)", progress, end, len(textsToEmbed)))
+	}
+	clearProgress()
+	fmt.Fprintln(out, "Done.")
+
+	return chunks, nil
+}
+
+const maxSummaryInput = 12000
+
+func (e *Engine) BuildSummaries(ctx context.Context, summarize SummarizeFunc) error {
+	boilerplate, err := e.Boilerplate.compile()
+	if err != nil {
+		return err
+	}
+
+	chunks, existing := e.snapshot()
+	indexed := make(map[string]bool)
+	for _, c := range chunks {
+		indexed[c.Filename] = true
+	}
+	var kept []Summary
+	summarized := make(map[string]bool)
+	for _, sm := range existing {
+		if indexed[sm.Filename] && !summarized[sm.Filename] {
+			summarized[sm.Filename] = true
+			kept = append(kept, sm)
+		}
+	}
+	var files []string
+	for _, c := range chunks {
+		if !summarized[c.Filename] {
+			summarized[c.Filename] = true
+			files = append(files, c.Filename)
+		}
+	}
+
+	if len(kept) > 0 {
+		ui.Printf(Output, ui.ColorBlue, "RAG: Summarizing %d new or changed files for hierarchical search (%d kept from the cache)...\n", len(files), len(kept))
+	} else {
+		ui.Printf(Output, ui.ColorBlue, "RAG: Summarizing %d files for hierarchical search...\n", len(files))
+	}
+
+	var summaries []Summary
+	for i, file := range files {
+		ui.SetProgress(fmt.Sprintf("Summarized %d/%d files...", i, len(files)))
+
+		content, err := ExtractText(file)
+		if err != nil {
+			ui.Printf(Output, "", "Skipping %s: %v\n", file, err)
+			continue
+		}
+		content = cleanText(e.Boilerplate.apply(cleanText(content), boilerplate))
+		if len(content) > maxSummaryInput {
+			content = content[:maxSummaryInput]
+		}
+
+		text, err := summarize(ctx, file, content)
+		if err != nil {
+			ui.ClearProgress()
+			return fmt.Errorf("failed to summarize %s: %w", file, err)
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		summaries = append(summaries, Summary{Filename: file, Text: text})
 	}
-	fmt.Println("\nDone.")
+	ui.ClearProgress()
 
+	if len(summaries) > 0 {
+		texts := make([]string, len(summaries))
+		for i, s := range summaries {
+			texts[i] = s.Text
+		}
+		vectors, err := e.embed(ctx, texts, e.DocPrefix)
+		if err != nil {
+			return fmt.Errorf("embedding error: %w", err)
+		}
+		for i := range summaries {
+			summaries[i].Vector = vectors[i]
+		}
+	}
+
+	e.mu.Lock()
+	e.Summaries = append(kept, summaries...)
+	e.mu.Unlock()
 	return nil
 }
 
 func (e *Engine) Search(ctx context.Context, query string, topK int) ([]Chunk, error) {
-	vectors, err := e.embedder.Embed(ctx, []string{query})
+	if topK < 1 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidTopK, topK)
+	}
+	queryVector, err := e.embedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	chunks, _ := e.snapshot()
+	return e.rankChunks(queryVector, chunks, topK), nil
+}
+
+type Result struct {
+	Chunk
+	Score float64
+}
+
+func (e *Engine) SearchScored(ctx context.Context, query string, topK int) ([]Result, error) {
+	if topK < 1 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidTopK, topK)
+	}
+	queryVector, err := e.embedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	chunks, _ := e.snapshot()
+	return e.scoreChunks(queryVector, chunks, topK), nil
+}
+
+func (e *Engine) SearchHierarchical(ctx context.Context, query string, topDocs, topK int) ([]Result, error) {
+	if topK < 1 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidTopK, topK)
+	}
+	queryVector, err := e.embedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	chunks, summaries := e.snapshot()
+	if len(summaries) == 0 {
+		return e.scoreChunks(queryVector, chunks, topK), nil
+	}
+
+	docScores := make(map[string]float64, len(summaries))
+	docs := make([]string, 0, len(summaries))
+	for _, s := range summaries {
+		docScores[s.Filename] = similarity(e.searchMetric(), queryVector, s.Vector)
+		docs = append(docs, s.Filename)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docScores[docs[i]] > docScores[docs[j]]
+	})
+
+	selected := make(map[string]bool)
+	for i := 0; i < len(docs) && i < topDocs; i++ {
+		selected[docs[i]] = true
+	}
+
+	var candidates []Chunk
+	for _, chunk := range chunks {
+		if selected[chunk.Filename] {
+			candidates = append(candidates, chunk)
+		}
+	}
+	return e.scoreChunks(queryVector, candidates, topK), nil
+}
+
+func (e *Engine) embedQuery(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := e.embed(ctx, []string{query}, e.QueryPrefix)
 	if err != nil {
 		return nil, err
 	}
 	if len(vectors) == 0 || len(vectors[0]) == 0 {
 		return nil, fmt.Errorf("failed to embed query")
 	}
+	return vectors[0], nil
+}
 
-	queryVector := vectors[0]
-
-	type scoredChunk struct {
-		Chunk Chunk
-		Score float64
+func (e *Engine) rankChunks(queryVector []float32, chunks []Chunk, topK int) []Chunk {
+	var results []Chunk
+	for _, r := range e.scoreChunks(queryVector, chunks, topK) {
+		results = append(results, r.Chunk)
 	}
+	return results
+}
 
-	var scores []scoredChunk
-	for _, chunk := range e.Chunks {
-		score := cosineSimilarity(queryVector, chunk.Vector)
-		scores = append(scores, scoredChunk{Chunk: chunk, Score: score})
+func (e *Engine) scoreChunks(queryVector []float32, chunks []Chunk, topK int) []Result {
+	var scores []Result
+	for _, chunk := range chunks {
+		score := similarity(e.searchMetric(), queryVector, chunk.Vector)
+		scores = append(scores, Result{Chunk: chunk, Score: score})
 	}
 
 	sort.Slice(scores, func(i, j int) bool {
@@ -459,13 +1305,42 @@ func (e *Engine) Search(ctx context.Context, query string, topK int) ([]Chunk, e
 	if len(scores) < topK {
 		topK = len(scores)
 	}
+	return scores[:topK]
+}
 
-	var results []Chunk
-	for i := 0; i < topK; i++ {
-		results = append(results, scores[i].Chunk)
+func NormalizeTypes(types []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(t), "."))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			normalized = append(normalized, t)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func FilterTypes(files, types []string) []string {
+	if len(types) == 0 {
+		return files
 	}
+	allowed := make(map[string]bool)
+	for _, t := range NormalizeTypes(types) {
+		allowed[t] = true
+	}
+	var filtered []string
+	for _, file := range files {
+		if allowed[strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))] {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
 
-	return results, nil
+func (e *Engine) findFiles(globPatterns []string) []string {
+	return FilterTypes(FindFiles(globPatterns), e.Settings.Types)
 }
 
 func FindFiles(patterns []string) []string {
@@ -524,6 +1399,54 @@ func FindFiles(patterns []string) []string {
 	return files
 }
 
+func (e *Engine) metric() string {
+	if e.Metric == "" {
+		return MetricCosine
+	}
+	return e.Metric
+}
+
+func (e *Engine) searchMetric() string {
+	if e.Normalize && e.metric() == MetricCosine {
+		return MetricDot
+	}
+	return e.metric()
+}
+
+func similarity(metric string, a, b []float32) float64 {
+	switch metric {
+	case MetricDot:
+		return dotProduct(a, b)
+	case MetricL2:
+		return -euclideanDistance(a, b)
+	default:
+		return cosineSimilarity(a, b)
+	}
+}
+
+func dotProduct(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i] * b[i])
+	}
+	return dot
+}
+
+func euclideanDistance(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return math.Inf(1)
+	}
+	var sum float64
+	for i := range a {
+		d := float64(a[i] - b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
 func cosineSimilarity(a, b []float32) float64 {
 	if len(a) != len(b) {
 		return 0
@@ -540,8 +1463,13 @@ func cosineSimilarity(a, b []float32) float64 {
 	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
 }
 
-func chunkText(text string, chunkSize, overlap int) []string {
-	var chunks []string
+type textSpan struct {
+	text       string
+	start, end int
+}
+
+func chunkText(text string, chunkSize, overlap int) []textSpan {
+	var chunks []textSpan
 	runes := []rune(text)
 	if len(runes) == 0 {
 		return chunks
@@ -551,7 +1479,7 @@ func chunkText(text string, chunkSize, overlap int) []string {
 		if end > len(runes) {
 			end = len(runes)
 		}
-		chunks = append(chunks, string(runes[i:end]))
+		chunks = append(chunks, textSpan{text: string(runes[i:end]), start: i, end: end})
 		if end == len(runes) {
 			break
 		}
@@ -579,18 +1507,36 @@ func cleanText(s string) string {
 	return strings.TrimSpace(s)
 }
 
-func ExtractText(path string) (text string, err error) {
+func ExtractText(path string) (string, error) {
+	text, _, _, err := extractText(path)
+	return text, err
+}
+
+func extractText(path string) (text string, ext string, sections []string, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("panic recovering file %s: %v", path, r)
 		}
 	}()
 
-	ext := strings.ToLower(filepath.Ext(path))
+	ext, err = detectType(path)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if ext == ".fb2" {
+		text, sections, err = readFB2(path)
+		return text, ext, sections, err
+	}
+	text, err = extractAs(path, ext)
+	return text, ext, nil, err
+}
+
+func extractAs(path, ext string) (string, error) {
+	if textExtensions[ext] {
+		return readTextFile(path)
+	}
+
 	switch ext {
-	case ".txt", ".md", ".go", ".js", ".json", ".py", ".html", ".css", ".java", ".c", ".h", ".cpp":
-		b, err := os.ReadFile(path)
-		return string(b), err
 	case ".pdf":
 		f, r, err := pdf.Open(path)
 		if err != nil {
@@ -631,19 +1577,13 @@ func ExtractText(path string) (text string, err error) {
 					}
 					b, _ := io.ReadAll(f)
 					f.Close()
-					sb.WriteString(stripTags(string(b)) + "\n")
+					sb.WriteString(htmltext.Text(string(b)) + "\n")
 				}
 			}
 		}
 		return sb.String(), nil
-	case ".fb2":
-		b, err := os.ReadFile(path)
-		if err != nil {
-			return "", err
-		}
-		return stripTags(string(b)), nil
 	}
-	return "", fmt.Errorf("unsupported type: %s", ext)
+	return convertExternal(path, ext)
 }
 
 func parseDocx(path string) (string, error) {
@@ -712,22 +1652,3 @@ func parseXlsx(path string) (string, error) {
 	}
 	return sb.String(), nil
 }
-
-func stripTags(c string) string {
-	var sb strings.Builder
-	in := false
-	for _, r := range c {
-		if r == '<' {
-			in = true
-			continue
-		}
-		if r == '>' {
-			in = false
-			continue
-		}
-		if !in {
-			sb.WriteRune(r)
-		}
-	}
-	return sb.String()
-}
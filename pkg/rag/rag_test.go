@@ -0,0 +1,183 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec := make([]float32, 8)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vec[h.Sum32()%8]++
+		}
+		vectors[i] = vec
+	}
+	return vectors, nil
+}
+
+func newTestEngine(t testing.TB) *Engine {
+	t.Helper()
+	Output = io.Discard
+	e, err := NewWithEmbedder(fakeEmbedder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func writeFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUpdateFromCacheSummarizesOnlyChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt": "alpha document about apples",
+		"b.txt": "beta document about bananas",
+		"c.txt": "gamma document about cherries",
+	})
+	globs := []string{filepath.Join(dir, "*.txt")}
+	cachePath := filepath.Join(dir, "cache.gob")
+
+	var summarized []string
+	summarize := func(ctx context.Context, filename, content string) (string, error) {
+		summarized = append(summarized, filepath.Base(filename))
+		return "summary of " + content, nil
+	}
+
+	e := newTestEngine(t)
+	ctx := context.Background()
+	if err := e.IngestGlobs(ctx, globs); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.BuildSummaries(ctx, summarize); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SaveEmbeddings(cachePath, globs); err != nil {
+		t.Fatal(err)
+	}
+	if len(summarized) != 3 {
+		t.Fatalf("summarized %v on the first build", summarized)
+	}
+
+	writeFiles(t, dir, map[string]string{"b.txt": "beta document about blueberries"})
+	if err := os.Remove(filepath.Join(dir, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	summarized = nil
+	e = newTestEngine(t)
+	if err := e.UpdateFromCache(ctx, cachePath, globs, summarize); err != nil {
+		t.Fatal(err)
+	}
+	if len(summarized) != 1 || summarized[0] != "b.txt" {
+		t.Fatalf("summarized %v, want only b.txt", summarized)
+	}
+
+	got := make(map[string]string)
+	for _, sm := range e.Summaries {
+		got[filepath.Base(sm.Filename)] = sm.Text
+	}
+	if len(got) != 2 || !strings.Contains(got["a.txt"], "apples") || !strings.Contains(got["b.txt"], "blueberries") {
+		t.Fatalf("got summaries %v", got)
+	}
+
+	if valid, reason := newTestEngine(t).ValidateCache(cachePath, globs); !valid {
+		t.Fatalf("cache is stale after the update: %s", reason)
+	}
+}
+
+func writeCorpus(t testing.TB, dir string, files int) {
+	t.Helper()
+	for i := 0; i < files; i++ {
+		var sb strings.Builder
+		for p := 0; p < 40; p++ {
+			fmt.Fprintf(&sb, "File %d paragraph %d talks about retries, backoff, caching and indexing.\n\n", i, p)
+		}
+		writeFiles(t, dir, map[string]string{fmt.Sprintf("doc%03d.md", i): sb.String()})
+	}
+}
+
+func TestIngestOrderIndependentOfWorkers(t *testing.T) {
+	dir := t.TempDir()
+	writeCorpus(t, dir, 20)
+	globs := []string{filepath.Join(dir, "*.md")}
+
+	var orders [][]string
+	for _, workers := range []int{1, 8} {
+		e := newTestEngine(t)
+		e.ExtractWorkers = workers
+		if err := e.IngestGlobs(context.Background(), globs); err != nil {
+			t.Fatal(err)
+		}
+		var order []string
+		for _, c := range e.Chunks {
+			order = append(order, c.Filename+":"+c.Text)
+		}
+		orders = append(orders, order)
+	}
+	if strings.Join(orders[0], "\n") != strings.Join(orders[1], "\n") {
+		t.Fatal("chunk order depends on the number of extraction workers")
+	}
+}
+
+func BenchmarkIngestGlobs(b *testing.B) {
+	dir := b.TempDir()
+	writeCorpus(b, dir, 200)
+	globs := []string{filepath.Join(dir, "*.md")}
+
+	counts := []int{1}
+	if n := runtime.NumCPU(); n > 1 {
+		counts = append(counts, n)
+	}
+	for _, workers := range counts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				e := newTestEngine(b)
+				e.ExtractWorkers = workers
+				if err := e.IngestGlobs(context.Background(), globs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchRejectsInvalidTopK(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "alpha document about apples"})
+	e := newTestEngine(t)
+	if err := e.IngestGlobs(context.Background(), []string{filepath.Join(dir, "*.txt")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, topK := range []int{0, -1} {
+		if _, err := e.SearchScored(context.Background(), "apples", topK); !errors.Is(err, ErrInvalidTopK) {
+			t.Errorf("SearchScored(top %d) = %v, want ErrInvalidTopK", topK, err)
+		}
+		if _, err := e.SearchHierarchical(context.Background(), "apples", 1, topK); !errors.Is(err, ErrInvalidTopK) {
+			t.Errorf("SearchHierarchical(top %d) = %v, want ErrInvalidTopK", topK, err)
+		}
+	}
+	if results, err := e.SearchScored(context.Background(), "apples", 5); err != nil || len(results) != 1 {
+		t.Fatalf("got %d results, %v", len(results), err)
+	}
+}
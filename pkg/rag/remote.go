@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+const (
+	ProviderLocal  = "local"
+	ProviderOpenAI = "openai"
+
+	remoteModelName = string(openai.SmallEmbedding3)
+	remoteBatchSize = 100
+	remoteRetries   = 5
+)
+
+var Providers = []string{ProviderLocal, ProviderOpenAI}
+
+func DefaultModel(provider string) string {
+	if provider == ProviderOpenAI {
+		return remoteModelName
+	}
+	return localModelName
+}
+
+type RemoteEmbedder struct {
+	client *openai.Client
+	Model  string
+}
+
+func NewRemoteEmbedder(apiKey, baseURL, model string) *RemoteEmbedder {
+	clientConfig := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		clientConfig.BaseURL = baseURL
+	}
+	if model == "" {
+		model = remoteModelName
+	}
+	return &RemoteEmbedder{
+		client: openai.NewClientWithConfig(clientConfig),
+		Model:  model,
+	}
+}
+
+func (r *RemoteEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	results := make([][]float32, len(texts))
+
+	var batch []string
+	var indexes []int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		vectors, err := r.embedBatch(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for i, vec := range vectors {
+			results[indexes[i]] = vec
+		}
+		batch, indexes = batch[:0], indexes[:0]
+		return nil
+	}
+
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		batch = append(batch, text)
+		indexes = append(indexes, i)
+		if len(batch) == remoteBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *RemoteEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	req := openai.EmbeddingRequestStrings{Input: texts, Model: openai.EmbeddingModel(r.Model)}
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		resp, err := r.client.CreateEmbeddings(ctx, req)
+		if err == nil {
+			if len(resp.Data) != len(texts) {
+				return nil, fmt.Errorf("embedding API returned %d vectors for %d inputs", len(resp.Data), len(texts))
+			}
+			vectors := make([][]float32, len(texts))
+			for _, d := range resp.Data {
+				if d.Index < 0 || d.Index >= len(texts) {
+					return nil, fmt.Errorf("embedding API returned an out-of-range index %d", d.Index)
+				}
+				vectors[d.Index] = d.Embedding
+			}
+			return vectors, nil
+		}
+		if attempt >= remoteRetries || !retryable(err) {
+			return nil, fmt.Errorf("embedding API error: %w", err)
+		}
+
+		ui.Printf(os.Stderr, ui.ColorRed, "[Embedding request failed: %v, retrying in %s (%d/%d)]\n", err, backoff, attempt+1, remoteRetries)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func retryable(err error) bool {
+	status := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		if fmt.Sprint(apiErr.Code) == "rate_limit_exceeded" {
+			return true
+		}
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,106 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".go": true, ".js": true, ".json": true, ".py": true,
+	".html": true, ".css": true, ".java": true, ".c": true, ".h": true, ".cpp": true,
+}
+
+var builtinExtensions = map[string]bool{
+	".pdf": true, ".docx": true, ".xlsx": true, ".epub": true, ".fb2": true,
+}
+
+func detectType(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	header := make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	f.Close()
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	header = header[:n]
+
+	sniffed := sniffType(path, header)
+	switch {
+	case sniffed == "" && textExtensions[ext] && n > 0:
+		return "", fmt.Errorf("%s has a text extension but contains %w", path, ErrUnknownEncoding)
+	case sniffed == "":
+		return ext, nil
+	case sniffed == ".txt":
+		if textExtensions[ext] || builtinExtensions[ext] {
+			return ext, nil
+		}
+		if _, ok := Converters[ext]; ok {
+			return ext, nil
+		}
+		return sniffed, nil
+	case sniffed == ext:
+		return ext, nil
+	case textExtensions[ext] || builtinExtensions[ext] || ext == "":
+		return sniffed, nil
+	}
+	return ext, nil
+}
+
+func sniffType(path string, header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("%PDF-")):
+		return ".pdf"
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return sniffZip(path)
+	case bytes.HasPrefix(header, []byte("{\\rtf")):
+		return ".rtf"
+	case bytes.HasPrefix(header, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")):
+		return ".doc"
+	case len(header) > 0 && detectCharset(header) != "":
+		return ".txt"
+	}
+	return ""
+}
+
+func sniffZip(path string) string {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		switch {
+		case f.Name == "word/document.xml":
+			return ".docx"
+		case f.Name == "xl/workbook.xml":
+			return ".xlsx"
+		case strings.HasPrefix(f.Name, "ppt/"):
+			return ".pptx"
+		case f.Name == "mimetype":
+			rc, err := f.Open()
+			if err != nil {
+				continue
+			}
+			b, _ := io.ReadAll(io.LimitReader(rc, 128))
+			rc.Close()
+			switch strings.TrimSpace(string(b)) {
+			case "application/epub+zip":
+				return ".epub"
+			case "application/vnd.oasis.opendocument.text":
+				return ".odt"
+			}
+		}
+	}
+	return ""
+}
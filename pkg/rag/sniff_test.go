@@ -0,0 +1,35 @@
+package rag
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectType(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+		err  error
+	}{
+		{"report.txt", ".pdf", nil},
+		{"README", ".txt", nil},
+		{"notes.md", ".md", nil},
+		{"letter", ".docx", nil},
+		{"budget", ".xlsx", nil},
+		{"novel.txt", ".epub", nil},
+		{"logo.png", ".png", nil},
+		{"data.json", "", ErrUnknownEncoding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := detectType(filepath.Join("testdata", "sniff", tt.file))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("detected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+const maxLoggedFiles = 5
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func (e *Engine) scanFiles(globPatterns []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	for _, file := range e.findFiles(globPatterns) {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps
+}
+
+func diffFiles(before, after map[string]fileStamp) (changed, removed []string) {
+	for file, stamp := range after {
+		if old, ok := before[file]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = append(changed, file)
+		}
+	}
+	for file := range before {
+		if _, ok := after[file]; !ok {
+			removed = append(removed, file)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+func (e *Engine) Watch(ctx context.Context, cachePath string, globPatterns []string, interval, debounce time.Duration) error {
+	known := e.scanFiles(globPatterns)
+	changed, removed, err := e.StaleFiles(cachePath, globPatterns)
+	if err != nil {
+		return err
+	}
+	dirty := false
+	if len(changed) > 0 || len(removed) > 0 {
+		_, dirty = e.update(ctx, cachePath, globPatterns, changed, removed)
+	}
+
+	ui.Printf(Output, ui.ColorGreen, "Watching %s (%d files, %d chunks). Press Ctrl+C to stop.\n",
+		strings.Join(globPatterns, ", "), len(known), e.ChunkCount())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return e.flush(cachePath, globPatterns, dirty)
+		case <-ticker.C:
+		}
+
+		current := e.scanFiles(globPatterns)
+		if changed, removed := diffFiles(known, current); len(changed) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		for settled := false; !settled; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(debounce):
+			}
+			next := e.scanFiles(globPatterns)
+			changed, removed := diffFiles(current, next)
+			settled = len(changed) == 0 && len(removed) == 0
+			current = next
+		}
+
+		changed, removed := diffFiles(known, current)
+		if refreshed, unsaved := e.update(ctx, cachePath, globPatterns, changed, removed); refreshed {
+			known, dirty = current, unsaved
+		}
+	}
+}
+
+func (e *Engine) flush(cachePath string, globPatterns []string, dirty bool) error {
+	if !dirty {
+		return nil
+	}
+	ui.Printf(Output, ui.ColorBlue, "Saving the index before exiting...\n")
+	_, _, err := e.saveCache(cachePath, globPatterns)
+	return err
+}
+
+func (e *Engine) update(ctx context.Context, cachePath string, globPatterns []string, changed, removed []string) (refreshed, unsaved bool) {
+	stop := context.AfterFunc(ctx, func() {
+		ui.Printf(Output, ui.ColorBlue, "Finishing the current update before exiting...\n")
+	})
+	defer stop()
+
+	start := time.Now()
+	if err := e.Refresh(context.WithoutCancel(ctx), changed, removed); err != nil {
+		ui.Printf(Output, ui.ColorRed, "[%s] Update failed: %v\n", start.Format("15:04:05"), err)
+		return false, false
+	}
+	if _, _, err := e.saveCache(cachePath, globPatterns); err != nil {
+		ui.Printf(Output, ui.ColorRed, "[%s] Re-indexed, but the cache could not be saved: %v\n", start.Format("15:04:05"), err)
+		return true, true
+	}
+
+	var parts []string
+	if len(changed) > 0 {
+		parts = append(parts, fmt.Sprintf("re-indexed %s", fileList(changed)))
+	}
+	if len(removed) > 0 {
+		parts = append(parts, fmt.Sprintf("removed %s", fileList(removed)))
+	}
+	ui.Printf(Output, "", "[%s] %s (%d chunks, %s)\n", start.Format("15:04:05"), strings.Join(parts, "; "),
+		e.ChunkCount(), time.Since(start).Round(time.Millisecond))
+	return true, false
+}
+
+func fileList(files []string) string {
+	if len(files) <= maxLoggedFiles {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(files[:maxLoggedFiles], ", "), len(files)-maxLoggedFiles)
+}
@@ -0,0 +1,249 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	vocabTimeout    = 30 * time.Second
+	vocabRetryAfter = 24 * time.Hour
+	minVocabRanks   = 50000
+)
+
+var vocabURL = "https://openaipublic.blob.core.windows.net/encodings/%s.tiktoken"
+
+var vocabHashes = map[string]string{
+	"cl100k_base": "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+	"o200k_base":  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+}
+
+const space = `\s\x{0B}\x{85}\p{Z}`
+
+var splitPatterns = map[string]string{
+	"cl100k_base": `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}` +
+		`| ?[^` + space + `\p{L}\p{N}]+[\r\n]*|[` + space + `]*[\r\n]+|[` + space + `]+`,
+	"o200k_base": `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^` + space + `\p{L}\p{N}]+[\r\n/]*|[` + space + `]*[\r\n]+|[` + space + `]+`,
+}
+
+type BPE struct {
+	name  string
+	ranks map[string]int
+	split *regexp.Regexp
+}
+
+func NewBPE(name string, ranks map[string]int) (*BPE, error) {
+	pattern, ok := splitPatterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	return &BPE{name: name, ranks: ranks, split: regexp.MustCompile(`^(?:` + pattern + `)`)}, nil
+}
+
+func (e *BPE) Name() string { return e.name }
+
+func (e *BPE) Exact() bool { return true }
+
+func (e *BPE) Count(text string) int {
+	count := 0
+	for _, piece := range e.pieces(text) {
+		count += e.countPiece([]byte(piece))
+	}
+	return count
+}
+
+func (e *BPE) pieces(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		var end int
+		if loc := e.split.FindStringIndex(text); loc != nil && loc[1] > 0 {
+			end = spaceLookaheadEnd(text, loc[1])
+		} else {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
+
+func spaceLookaheadEnd(text string, end int) int {
+	match := text[:end]
+	if end == len(text) || !isSpaceOnly(match) || match[len(match)-1] == '\n' || match[len(match)-1] == '\r' {
+		return end
+	}
+	next, _ := utf8.DecodeRuneInString(text[end:])
+	if unicode.IsSpace(next) {
+		return end
+	}
+	_, last := utf8.DecodeLastRuneInString(match)
+	if last == len(match) {
+		return end
+	}
+	return end - last
+}
+
+func isSpaceOnly(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *BPE) countPiece(piece []byte) int {
+	if _, ok := e.ranks[string(piece)]; ok || len(piece) < 2 {
+		return 1
+	}
+
+	type part struct{ start, rank int }
+	parts := make([]part, len(piece)+1)
+	for i := range parts {
+		parts[i] = part{start: i, rank: math.MaxInt}
+	}
+	rankAt := func(i int) int {
+		if i+2 < len(parts) {
+			if r, ok := e.ranks[string(piece[parts[i].start:parts[i+2].start])]; ok {
+				return r
+			}
+		}
+		return math.MaxInt
+	}
+	for i := range parts {
+		parts[i].rank = rankAt(i)
+	}
+
+	for len(parts) > 2 {
+		best, idx := math.MaxInt, -1
+		for i := 0; i < len(parts)-1; i++ {
+			if parts[i].rank < best {
+				best, idx = parts[i].rank, i
+			}
+		}
+		if idx < 0 {
+			break
+		}
+		parts = append(parts[:idx+1], parts[idx+2:]...)
+		parts[idx].rank = rankAt(idx)
+		if idx > 0 {
+			parts[idx-1].rank = rankAt(idx - 1)
+		}
+	}
+	return len(parts) - 1
+}
+
+func loadEncoding(name string) (*BPE, error) {
+	cachePath := filepath.Join(os.Getenv("HOME"), ".cache", "ai", "tiktoken", name+".tiktoken")
+	data, err := os.ReadFile(cachePath)
+	if err == nil {
+		err = checkVocab(name, data)
+	}
+	if err != nil {
+		if data, err = fetchVocab(name, cachePath); err != nil {
+			return nil, err
+		}
+	}
+
+	ranks, err := ParseRanks(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cachePath, err)
+	}
+	return NewBPE(name, ranks)
+}
+
+func fetchVocab(name, cachePath string) ([]byte, error) {
+	failedPath := cachePath + ".failed"
+	if info, err := os.Stat(failedPath); err == nil && time.Since(info.ModTime()) < vocabRetryAfter {
+		return nil, fmt.Errorf("the %s vocabulary download failed at %s; delete %s to retry sooner",
+			name, info.ModTime().Format(time.DateTime), failedPath)
+	}
+
+	data, err := downloadVocab(name)
+	if err == nil {
+		err = checkVocab(name, data)
+	}
+	os.MkdirAll(filepath.Dir(cachePath), 0755)
+	if err != nil {
+		os.WriteFile(failedPath, []byte(err.Error()+"\n"), 0644)
+		return nil, err
+	}
+	os.Remove(failedPath)
+	os.WriteFile(cachePath, data, 0644)
+	return data, nil
+}
+
+func checkVocab(name string, data []byte) error {
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != vocabHashes[name] {
+		return fmt.Errorf("the %s vocabulary has sha256 %s, expected %s", name, got, vocabHashes[name])
+	}
+	return nil
+}
+
+func downloadVocab(name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), vocabTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(vocabURL, name), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the %s vocabulary: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download the %s vocabulary: %s", name, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func ParseRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		token, rank, ok := bytes.Cut(line, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("invalid vocabulary line %q", line)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, fmt.Errorf("invalid vocabulary token %q: %w", token, err)
+		}
+		n, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("invalid vocabulary rank %q", rank)
+		}
+		ranks[string(decoded)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) < minVocabRanks {
+		return nil, fmt.Errorf("vocabulary has only %d tokens", len(ranks))
+	}
+	return ranks, nil
+}
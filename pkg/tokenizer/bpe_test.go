@@ -0,0 +1,89 @@
+package tokenizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func cachedEncoding(t *testing.T, name string) *BPE {
+	t.Helper()
+	path := filepath.Join(os.Getenv("HOME"), ".cache", "ai", "tiktoken", name+".tiktoken")
+	if _, err := os.Stat(path); err != nil {
+		t.Skipf("%s is not cached: %v", name, err)
+	}
+	e, err := loadEncoding(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestBPECount(t *testing.T) {
+	tests := []struct {
+		text         string
+		cl100k, o200 int
+	}{
+		{"hello world!你好，世界！", 10, 7},
+		{"Hello, world!", 4, 4},
+		{"The quick brown fox jumps over the lazy dog.", 10, 10},
+		{"antidisestablishmentarianism", 6, 6},
+		{"func main() {\n\tfmt.Println(\"hi\")\n}\n", 10, 10},
+		{"  leading spaces and trailing   \n\n", 6, 6},
+		{"Привет, как дела? Всё хорошо.", 17, 9},
+		{"1234567890 3.14159 2024-01-01", 16, 16},
+		{"I'm sure they'll say we've done it, don't you think?", 16, 12},
+		{"emoji: 🎉🚀 and ümlauts", 13, 10},
+	}
+
+	for _, enc := range []struct {
+		name string
+		want func(i int) int
+	}{
+		{"cl100k_base", func(i int) int { return tests[i].cl100k }},
+		{"o200k_base", func(i int) int { return tests[i].o200 }},
+	} {
+		t.Run(enc.name, func(t *testing.T) {
+			e := cachedEncoding(t, enc.name)
+			for i, tt := range tests {
+				if got := e.Count(tt.text); got != enc.want(i) {
+					t.Errorf("Count(%q) = %d, want %d", tt.text, got, enc.want(i))
+				}
+			}
+		})
+	}
+}
+
+func TestFetchVocabRejectsBadHashAndCachesFailure(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("not a vocabulary\n"))
+	}))
+	defer server.Close()
+
+	oldURL := vocabURL
+	vocabURL = server.URL + "/%s.tiktoken"
+	defer func() { vocabURL = oldURL }()
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := loadEncoding("cl100k_base")
+	if err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Fatalf("expected a hash mismatch, got %v", err)
+	}
+	cachePath := filepath.Join(os.Getenv("HOME"), ".cache", "ai", "tiktoken", "cl100k_base.tiktoken")
+	if _, err := os.Stat(cachePath); err == nil {
+		t.Fatal("a vocabulary with the wrong hash was cached")
+	}
+
+	if _, err := loadEncoding("cl100k_base"); err == nil || !strings.Contains(err.Error(), "failed at") {
+		t.Fatalf("expected the cached failure, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("made %d requests, want 1", n)
+	}
+}
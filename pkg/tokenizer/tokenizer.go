@@ -0,0 +1,121 @@
+package tokenizer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+	Exact() bool
+}
+
+const (
+	tokensPerMessage = 3
+	tokensPerName    = 1
+	tokensPerReply   = 3
+)
+
+var encodingPatterns = []struct {
+	pattern  string
+	encoding string
+}{
+	{"gpt-4o*", "o200k_base"},
+	{"gpt-4.1*", "o200k_base"},
+	{"gpt-4.5*", "o200k_base"},
+	{"gpt-5*", "o200k_base"},
+	{"o1*", "o200k_base"},
+	{"o3*", "o200k_base"},
+	{"o4*", "o200k_base"},
+	{"chatgpt-4o*", "o200k_base"},
+	{"gpt-4*", "cl100k_base"},
+	{"gpt-3.5*", "cl100k_base"},
+	{"text-embedding-3*", "cl100k_base"},
+	{"text-embedding-ada-002", "cl100k_base"},
+}
+
+type encodingEntry struct {
+	once sync.Once
+	t    Tokenizer
+}
+
+var (
+	mu        sync.Mutex
+	encodings = make(map[string]*encodingEntry)
+)
+
+func ForModel(model string) Tokenizer {
+	name := EncodingForModel(model)
+	if name == "" {
+		return Heuristic{}
+	}
+
+	mu.Lock()
+	entry, ok := encodings[name]
+	if !ok {
+		entry = &encodingEntry{}
+		encodings[name] = entry
+	}
+	mu.Unlock()
+
+	entry.once.Do(func() {
+		t, err := loadEncoding(name)
+		if err != nil {
+			entry.t = Heuristic{}
+			return
+		}
+		entry.t = t
+	})
+	return entry.t
+}
+
+func EncodingForModel(model string) string {
+	name := strings.ToLower(model)
+	name = name[strings.LastIndex(name, "/")+1:]
+	for _, p := range encodingPatterns {
+		if ok, _ := path.Match(p.pattern, name); ok {
+			return p.encoding
+		}
+	}
+	return ""
+}
+
+func CountTokens(t Tokenizer, messages []openai.ChatCompletionMessage) int {
+	total := tokensPerReply
+	for _, msg := range messages {
+		total += tokensPerMessage + t.Count(msg.Role) + t.Count(msg.Content)
+		for _, part := range msg.MultiContent {
+			total += t.Count(part.Text)
+		}
+		if msg.Name != "" {
+			total += tokensPerName + t.Count(msg.Name)
+		}
+		for _, call := range msg.ToolCalls {
+			total += t.Count(call.Function.Name) + t.Count(call.Function.Arguments)
+		}
+	}
+	return total
+}
+
+func Format(t Tokenizer, n int) string {
+	if t.Exact() {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("≈%d", n)
+}
+
+type Heuristic struct{}
+
+func (Heuristic) Name() string { return "heuristic" }
+
+func (Heuristic) Exact() bool { return false }
+
+func (Heuristic) Count(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
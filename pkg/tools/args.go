@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidArguments = errors.New("invalid tool arguments")
+
+func parseArguments(t ToolEntry, argsJSON string) (map[string]interface{}, error) {
+	argsJSON = strings.TrimSpace(argsJSON)
+	if argsJSON == "" || argsJSON == "null" {
+		return map[string]interface{}{}, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(argsJSON), &value); err != nil {
+		return nil, fmt.Errorf("%w: the arguments for %s are not valid JSON (%v); send them as a JSON object like %s", ErrInvalidArguments, t.Definition.Name, err, exampleArgs(t))
+	}
+
+	if s, ok := value.(string); ok && strings.HasPrefix(strings.TrimSpace(s), "{") {
+		var inner map[string]interface{}
+		if json.Unmarshal([]byte(s), &inner) == nil {
+			return inner, nil
+		}
+	}
+	if list, ok := value.([]interface{}); ok && len(list) == 1 {
+		if obj, ok := list[0].(map[string]interface{}); ok {
+			return obj, nil
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return v, nil
+	}
+
+	if params := parameterNames(t); len(params) == 1 {
+		return map[string]interface{}{params[0]: value}, nil
+	}
+	return nil, fmt.Errorf("%w: the arguments for %s must be a JSON object, but got a JSON %s; call it again with an object like %s", ErrInvalidArguments, t.Definition.Name, jsonKind(value), exampleArgs(t))
+}
+
+func parameterNames(t ToolEntry) []string {
+	raw, ok := t.Definition.Parameters.(json.RawMessage)
+	if !ok {
+		if b, err := json.Marshal(t.Definition.Parameters); err == nil {
+			raw = b
+		}
+	}
+
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func exampleArgs(t ToolEntry) string {
+	params := parameterNames(t)
+	if len(params) == 0 {
+		return "{}"
+	}
+	fields := make([]string, len(params))
+	for i, p := range params {
+		fields[i] = fmt.Sprintf("%q: ...", p)
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "value"
+}
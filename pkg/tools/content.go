@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/yuriiter/ai/pkg/ui"
+)
+
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Data     string `json:"data"`
+	MimeType string `json:"mimeType"`
+	URI      string `json:"uri"`
+	Name     string `json:"name"`
+	Resource *struct {
+		URI      string `json:"uri"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Blob     string `json:"blob"`
+	} `json:"resource"`
+}
+
+type Image struct {
+	MimeType string
+	Data     string
+	Path     string
+}
+
+func (i Image) DataURI() string {
+	return fmt.Sprintf("data:%s;base64,%s", i.MimeType, i.Data)
+}
+
+var outputDir struct {
+	mu   sync.Mutex
+	path string
+}
+
+// OutputDir returns a directory for files written by tools during this
+// session, creating it on first use. RemoveOutputDir deletes it.
+func OutputDir() (string, error) {
+	outputDir.mu.Lock()
+	defer outputDir.mu.Unlock()
+
+	if outputDir.path == "" {
+		dir, err := os.MkdirTemp("", "ai-tool-output-*")
+		if err != nil {
+			return "", err
+		}
+		outputDir.path = dir
+	}
+	return outputDir.path, nil
+}
+
+func RemoveOutputDir() error {
+	outputDir.mu.Lock()
+	defer outputDir.mu.Unlock()
+
+	if outputDir.path == "" {
+		return nil
+	}
+	err := os.RemoveAll(outputDir.path)
+	outputDir.path = ""
+	return err
+}
+
+func saveImage(toolName string, p contentPart) (Image, error) {
+	img := Image{MimeType: p.MimeType, Data: p.Data}
+	data, err := base64.StdEncoding.DecodeString(p.Data)
+	if err != nil {
+		return img, fmt.Errorf("invalid base64 image data: %w", err)
+	}
+	dir, err := OutputDir()
+	if err != nil {
+		return img, err
+	}
+
+	ext := ".bin"
+	if exts, _ := mime.ExtensionsByType(p.MimeType); len(exts) > 0 {
+		ext = exts[len(exts)-1]
+	}
+	f, err := os.CreateTemp(dir, SafeFileName(toolName)+"-*"+ext)
+	if err != nil {
+		return img, err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return img, err
+	}
+	img.Path = f.Name()
+	return img, nil
+}
+
+func SafeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func formatContent(toolName string, parts []contentPart, structured json.RawMessage) (string, []Image) {
+	if len(parts) == 1 && parts[0].Type == "text" {
+		return parts[0].Text, nil
+	}
+
+	var sections []string
+	var images []Image
+	hasText := false
+	for i, p := range parts {
+		label := fmt.Sprintf("[Part %d/%d: %s", i+1, len(parts), p.Type)
+		switch p.Type {
+		case "text":
+			hasText = true
+			sections = append(sections, label+"]\n"+p.Text)
+		case "image":
+			img, err := saveImage(toolName, p)
+			if err != nil {
+				sections = append(sections, fmt.Sprintf("%s, %s, could not be saved: %v]", label, p.MimeType, err))
+				continue
+			}
+			images = append(images, img)
+			ui.Printf(os.Stderr, ui.ColorBlue, "[Tool %s returned an image, saved to %s]\n", toolName, img.Path)
+			sections = append(sections, fmt.Sprintf("%s, %s, saved to %s]", label, p.MimeType, img.Path))
+		case "audio":
+			sections = append(sections, fmt.Sprintf("%s, %s, %d bytes of base64 data not shown]", label, p.MimeType, len(p.Data)))
+		case "resource":
+			if p.Resource == nil {
+				sections = append(sections, label+", empty]")
+				continue
+			}
+			if p.Resource.Text != "" {
+				hasText = true
+				sections = append(sections, fmt.Sprintf("%s %s]\n%s", label, p.Resource.URI, p.Resource.Text))
+			} else {
+				sections = append(sections, fmt.Sprintf("%s %s, %s, %d bytes of binary data not shown]", label, p.Resource.URI, p.Resource.MimeType, len(p.Resource.Blob)))
+			}
+		case "resource_link":
+			sections = append(sections, fmt.Sprintf("%s %s %s]", label, p.Name, p.URI))
+		default:
+			sections = append(sections, label+", unsupported content type]")
+		}
+	}
+
+	if len(structured) > 0 && !hasText {
+		sections = append(sections, "[Structured content]\n"+string(structured))
+	}
+	return strings.Join(sections, "\n\n"), images
+}
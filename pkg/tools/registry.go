@@ -2,8 +2,18 @@ package tools
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/yuriiter/ai/pkg/mcp"
+	"github.com/yuriiter/ai/pkg/ui"
 
 	openai "github.com/sashabaranov/go-openai"
 )
@@ -16,31 +26,103 @@ const (
 )
 
 type ToolEntry struct {
-	Type       ToolType
-	Definition openai.FunctionDefinition
-	InternalFn func(args string) (string, error)
-	MCPClient  *mcp.Client
+	Type        ToolType
+	Definition  openai.FunctionDefinition
+	Annotations Annotations
+	InternalFn  func(args string) (string, error)
+	MCPClient   *mcp.Client
+	Server      string
+}
+
+type Annotations struct {
+	Title           string `json:"title"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint"`
+	DestructiveHint *bool  `json:"destructiveHint"`
+	IdempotentHint  *bool  `json:"idempotentHint"`
+	OpenWorldHint   *bool  `json:"openWorldHint"`
+}
+
+func (a Annotations) ReadOnly() bool {
+	return a.ReadOnlyHint != nil && *a.ReadOnlyHint
+}
+
+func (a Annotations) Idempotent() bool {
+	return a.ReadOnly() || a.IdempotentHint != nil && *a.IdempotentHint
+}
+
+// Destructive follows the MCP defaults: a tool declared as not read-only is
+// assumed destructive unless it says otherwise.
+func (a Annotations) Destructive() bool {
+	if a.ReadOnly() {
+		return false
+	}
+	if a.DestructiveHint == nil {
+		return a.ReadOnlyHint != nil
+	}
+	return *a.DestructiveHint
+}
+
+func (a Annotations) Labels() []string {
+	var labels []string
+	for _, hint := range []struct {
+		value *bool
+		label string
+	}{
+		{a.ReadOnlyHint, "read-only"},
+		{a.DestructiveHint, "destructive"},
+		{a.IdempotentHint, "idempotent"},
+		{a.OpenWorldHint, "open-world"},
+	} {
+		if hint.value == nil {
+			continue
+		}
+		if *hint.value {
+			labels = append(labels, hint.label)
+		} else {
+			labels = append(labels, "not "+hint.label)
+		}
+	}
+	return labels
 }
 
 type Registry struct {
-	tools []ToolEntry
+	mu      sync.RWMutex
+	tools   []ToolEntry
+	servers []string
+	closed  bool
+
+	Retries      int
+	RetryBackoff time.Duration
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
-		tools: make([]ToolEntry, 0),
+		tools:        make([]ToolEntry, 0),
+		RetryBackoff: 500 * time.Millisecond,
 	}
 }
 
+func (r *Registry) RegisterInternal(def openai.FunctionDefinition, annotations Annotations, fn func(args string) (string, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tools = append(r.tools, ToolEntry{
+		Type:        TypeInternal,
+		Definition:  def,
+		Annotations: annotations,
+		InternalFn:  fn,
+	})
+}
+
 func (r *Registry) LoadMCPTools(command string) error {
-	client, err := mcp.NewClient(command)
+	client, err := mcp.Acquire(command)
 	if err != nil {
 		return err
 	}
 
 	resBytes, err := client.Call("tools/list", nil)
 	if err != nil {
-		client.Close()
+		mcp.Release(command)
 		return err
 	}
 
@@ -49,14 +131,24 @@ func (r *Registry) LoadMCPTools(command string) error {
 			Name        string          `json:"name"`
 			Description string          `json:"description"`
 			InputSchema json.RawMessage `json:"inputSchema"`
+			Annotations Annotations     `json:"annotations"`
 		} `json:"tools"`
 	}
 
 	if err := json.Unmarshal(resBytes, &result); err != nil {
-		client.Close()
+		mcp.Release(command)
 		return err
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		mcp.Release(command)
+		return ErrRegistryClosed
+	}
+	r.servers = append(r.servers, command)
+
 	for _, t := range result.Tools {
 		cleanSchema := sanitizeSchema(t.InputSchema)
 
@@ -67,7 +159,9 @@ func (r *Registry) LoadMCPTools(command string) error {
 				Description: t.Description,
 				Parameters:  cleanSchema,
 			},
-			MCPClient: client,
+			Annotations: t.Annotations,
+			MCPClient:   client,
+			Server:      command,
 		})
 	}
 
@@ -101,6 +195,9 @@ func sanitizeSchema(raw json.RawMessage) json.RawMessage {
 	return cleanBytes
 }
 func (r *Registry) GetOpenAITools() []openai.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var apiTools []openai.Tool
 	for _, t := range r.tools {
 		apiTools = append(apiTools, openai.Tool{
@@ -111,73 +208,188 @@ func (r *Registry) GetOpenAITools() []openai.Tool {
 	return apiTools
 }
 
-func (r *Registry) Execute(name string, argsJSON string) (string, error) {
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.tools))
+	for _, t := range r.tools {
+		names = append(names, t.Definition.Name)
+	}
+	return names
+}
+
+func (r *Registry) List() []ToolEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]ToolEntry(nil), r.tools...)
+}
+
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	for _, t := range r.tools {
 		if t.Definition.Name == name {
-			if t.Type == TypeInternal {
-				return t.InternalFn(argsJSON)
-			}
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registry) Lookup(name string) (ToolEntry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.tools {
+		if t.Definition.Name == name {
+			return t, true
+		}
+	}
+	return ToolEntry{}, false
+}
+
+var (
+	ErrToolNotFound   = errors.New("tool not found")
+	ErrRegistryClosed = errors.New("tool registry is closed")
+)
+
+func (r *Registry) Execute(name string, argsJSON string) (string, []Image, error) {
+	t, ok := r.Lookup(name)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+
+	backoff := r.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		out, images, err := r.execute(t, name, argsJSON)
+		if err == nil {
+			return out, images, nil
+		}
 
-			if t.Type == TypeMCP {
-				var argsMap map[string]interface{}
-
-				if argsJSON == "" || argsJSON == "null" {
-					argsMap = make(map[string]interface{})
-				} else {
-					if err := json.Unmarshal([]byte(argsJSON), &argsMap); err != nil {
-						return "", fmt.Errorf("invalid json args from model: %w", err)
-					}
-				}
-
-				if argsMap == nil {
-					argsMap = make(map[string]interface{})
-				}
-
-				callParams := map[string]interface{}{
-					"name":      name,
-					"arguments": argsMap,
-				}
-
-				resBytes, err := t.MCPClient.Call("tools/call", callParams)
-				if err != nil {
-					return "", err
-				}
-
-				var output struct {
-					Content []struct {
-						Type string `json:"type"`
-						Text string `json:"text"`
-					} `json:"content"`
-					IsError bool `json:"isError"`
-				}
-
-				if err := json.Unmarshal(resBytes, &output); err != nil {
-					return "", fmt.Errorf("failed to parse mcp response: %w", err)
-				}
-
-				if output.IsError {
-					if len(output.Content) > 0 {
-						return fmt.Sprintf("Tool Error: %s", output.Content[0].Text), nil
-					}
-					return "Tool failed with unspecified error", nil
-				}
-
-				if len(output.Content) > 0 {
-					return output.Content[0].Text, nil
-				}
-				return "success", nil
+		closed := t.Type == TypeMCP && IsClosed(err)
+		if closed {
+			client, reconnectErr := r.reconnect(t)
+			if reconnectErr != nil {
+				return "", nil, fmt.Errorf("%w (reconnecting to %s failed: %v)", err, t.Server, reconnectErr)
 			}
+			t.MCPClient = client
+			ui.Printf(os.Stderr, ui.ColorRed, "[MCP server %s closed the connection; reconnected]\n", t.Server)
 		}
+		if attempt >= r.Retries || !(closed || IsTransient(err)) {
+			return out, images, err
+		}
+		if !t.Annotations.Idempotent() {
+			return out, images, fmt.Errorf("%w (not retried because %s is not marked read-only or idempotent)", err, name)
+		}
+		ui.Printf(os.Stderr, ui.ColorRed, "[Tool %s failed: %v, retrying in %s (%d/%d)]\n", name, err, backoff, attempt+1, r.Retries)
+		time.Sleep(backoff)
+		backoff *= 2
 	}
-	return "", fmt.Errorf("tool %s not found", name)
 }
 
-func (r *Registry) Close() {
-	seen := make(map[*mcp.Client]bool)
-	for _, t := range r.tools {
-		if t.Type == TypeMCP && t.MCPClient != nil && !seen[t.MCPClient] {
-			t.MCPClient.Close()
-			seen[t.MCPClient] = true
+func (r *Registry) reconnect(t ToolEntry) (*mcp.Client, error) {
+	client, err := mcp.Reconnect(t.Server, t.MCPClient)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tools {
+		if r.tools[i].MCPClient == t.MCPClient {
+			r.tools[i].MCPClient = client
+		}
+	}
+	return client, nil
+}
+
+func IsClosed(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, os.ErrClosed) || errors.Is(err, mcp.ErrConnectionClosed) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "file already closed")
+}
+
+func IsTransient(err error) bool {
+	var serverErr *mcp.ServerError
+	if errors.As(err, &serverErr) || IsClosed(err) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, hint := range []string{"timeout", "timed out", "temporar", "connection reset", "connection refused", "unavailable"} {
+		if strings.Contains(msg, hint) {
+			return true
 		}
 	}
+	return false
+}
+
+func (r *Registry) execute(t ToolEntry, name string, argsJSON string) (string, []Image, error) {
+	argsMap, err := parseArguments(t, argsJSON)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if t.Type == TypeInternal {
+		normalized, _ := json.Marshal(argsMap)
+		out, err := t.InternalFn(string(normalized))
+		return out, nil, err
+	}
+
+	callParams := map[string]interface{}{
+		"name":      name,
+		"arguments": argsMap,
+	}
+
+	resBytes, err := t.MCPClient.Call("tools/call", callParams)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var output struct {
+		Content           []contentPart   `json:"content"`
+		StructuredContent json.RawMessage `json:"structuredContent"`
+		IsError           bool            `json:"isError"`
+	}
+
+	if err := json.Unmarshal(resBytes, &output); err != nil {
+		return "", nil, fmt.Errorf("failed to parse mcp response: %w", err)
+	}
+
+	text, images := formatContent(name, output.Content, output.StructuredContent)
+	if output.IsError {
+		if text != "" {
+			return fmt.Sprintf("Tool Error: %s", text), images, nil
+		}
+		return "Tool failed with unspecified error", images, nil
+	}
+
+	if text != "" {
+		return text, images, nil
+	}
+	return "success", images, nil
+}
+
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, command := range r.servers {
+		mcp.Release(command)
+	}
+	r.servers = nil
+	r.closed = true
 }
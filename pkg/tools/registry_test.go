@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv("AI_TEST_MCP_SERVER"); dir != "" {
+		runFakeServer(dir)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func runFakeServer(dir string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+			Params struct {
+				Name string `json:"name"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &req) != nil || req.ID == 0 {
+			continue
+		}
+
+		var result interface{} = map[string]interface{}{}
+		switch req.Method {
+		case "tools/list":
+			result = map[string]interface{}{"tools": []map[string]interface{}{
+				{"name": "flaky_read", "annotations": map[string]bool{"readOnlyHint": true}},
+				{"name": "flaky_write", "annotations": map[string]bool{"idempotentHint": false}},
+			}}
+		case "tools/call":
+			marker := filepath.Join(dir, req.Params.Name)
+			if _, err := os.Stat(marker); err != nil {
+				os.WriteFile(marker, nil, 0644)
+				os.Exit(1)
+			}
+			result = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": "ok"}}}
+		}
+		resp, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		fmt.Println(string(resp))
+	}
+}
+
+func newFakeServerRegistry(t *testing.T) *Registry {
+	t.Helper()
+	t.Setenv("AI_TEST_MCP_SERVER", t.TempDir())
+	r := NewRegistry()
+	r.Retries = 2
+	r.RetryBackoff = 0
+	if err := r.LoadMCPTools(os.Args[0] + " -test.run=^$ " + t.Name()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestExecuteReconnectsAndRetriesReadOnlyTool(t *testing.T) {
+	r := newFakeServerRegistry(t)
+	out, _, err := r.Execute("flaky_read", "{}")
+	if err != nil || out != "ok" {
+		t.Fatalf("got %q, %v", out, err)
+	}
+}
+
+func TestExecuteDoesNotResendNonIdempotentTool(t *testing.T) {
+	r := newFakeServerRegistry(t)
+	_, _, err := r.Execute("flaky_write", "{}")
+	if err == nil || !strings.Contains(err.Error(), "not retried") {
+		t.Fatalf("expected the call not to be re-sent, got %v", err)
+	}
+
+	out, _, err := r.Execute("flaky_write", "{}")
+	if err != nil || out != "ok" {
+		t.Fatalf("after reconnecting: got %q, %v", out, err)
+	}
+}
+
+func TestExecuteRetriesOnlyIdempotentInternalTools(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name        string
+		annotations Annotations
+		calls       int
+	}{
+		{"read-only", Annotations{ReadOnlyHint: &yes}, 3},
+		{"idempotent", Annotations{IdempotentHint: &yes}, 3},
+		{"not idempotent", Annotations{IdempotentHint: &no}, 1},
+		{"no hints", Annotations{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.Retries = 2
+			r.RetryBackoff = 0
+			calls := 0
+			r.RegisterInternal(openai.FunctionDefinition{Name: "tool"}, tt.annotations, func(string) (string, error) {
+				calls++
+				return "", errors.New("request timed out")
+			})
+			if _, _, err := r.Execute("tool", "{}"); err == nil {
+				t.Fatal("expected an error")
+			}
+			if calls != tt.calls {
+				t.Fatalf("called %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestAnnotationsDestructive(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name        string
+		annotations Annotations
+		want        bool
+	}{
+		{"no hints", Annotations{}, false},
+		{"read-only", Annotations{ReadOnlyHint: &yes, DestructiveHint: &yes}, false},
+		{"not read-only", Annotations{ReadOnlyHint: &no}, true},
+		{"not read-only, not destructive", Annotations{ReadOnlyHint: &no, DestructiveHint: &no}, false},
+		{"destructive", Annotations{DestructiveHint: &yes}, true},
+		{"not destructive", Annotations{DestructiveHint: &no}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.annotations.Destructive(); got != tt.want {
+			t.Errorf("%s: Destructive() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
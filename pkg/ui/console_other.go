@@ -0,0 +1,7 @@
+//go:build !windows
+
+package ui
+
+func enableVirtualTerminal() bool {
+	return true
+}
@@ -0,0 +1,173 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+type outputKind int
+
+const (
+	outputText outputKind = iota
+	outputProgress
+	outputProgressClear
+	outputFinish
+	outputRedirect
+	outputAnswered
+)
+
+type outputEvent struct {
+	kind  outputKind
+	w     io.Writer
+	color string
+	text  string
+	done  chan struct{}
+}
+
+type outputBroker struct {
+	events   chan outputEvent
+	stdout   io.Writer
+	progress string
+	midLine  map[io.Writer]bool
+	showBars bool
+}
+
+var (
+	brokerOnce sync.Once
+	broker     *outputBroker
+)
+
+func output() *outputBroker {
+	brokerOnce.Do(func() {
+		broker = &outputBroker{
+			events:   make(chan outputEvent),
+			stdout:   os.Stdout,
+			midLine:  make(map[io.Writer]bool),
+			showBars: IsStderrTTY(),
+		}
+		go broker.run()
+	})
+	return broker
+}
+
+func IsStderrTTY() bool {
+	stat, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return (stat.Mode() & os.ModeCharDevice) != 0
+}
+
+func (b *outputBroker) send(ev outputEvent) {
+	ev.done = make(chan struct{})
+	b.events <- ev
+	<-ev.done
+}
+
+func (b *outputBroker) run() {
+	for ev := range b.events {
+		switch ev.kind {
+		case outputText:
+			b.clearProgress()
+			b.write(b.target(ev.w), ev.color, ev.text)
+			b.drawProgress()
+		case outputProgress:
+			b.clearProgress()
+			b.progress = ev.text
+			b.drawProgress()
+		case outputProgressClear:
+			b.clearProgress()
+			b.progress = ""
+		case outputFinish:
+			b.clearProgress()
+			b.progress = ""
+			b.finish(b.stdout, ColorReset)
+			if b.stdout != io.Writer(os.Stderr) {
+				b.finish(os.Stderr, "")
+			}
+		case outputRedirect:
+			b.stdout = ev.w
+		case outputAnswered:
+			b.midLine[ev.w] = false
+			b.drawProgress()
+		}
+		close(ev.done)
+	}
+}
+
+func (b *outputBroker) write(w io.Writer, color, text string) {
+	if text == "" {
+		return
+	}
+	b.midLine[w] = !strings.HasSuffix(text, "\n")
+	if color != "" {
+		text = color + text + ColorReset
+	}
+	io.WriteString(w, text)
+}
+
+func (b *outputBroker) target(w io.Writer) io.Writer {
+	if w == io.Writer(os.Stdout) {
+		return b.stdout
+	}
+	return w
+}
+
+func (b *outputBroker) finish(w io.Writer, reset string) {
+	if b.midLine[w] {
+		reset += "\n"
+	}
+	if reset != "" {
+		io.WriteString(w, reset)
+	}
+	b.midLine[w] = false
+}
+
+func (b *outputBroker) progressVisible() bool {
+	return b.showBars && b.progress != "" && !b.midLine[b.stdout] && !b.midLine[os.Stderr]
+}
+
+func (b *outputBroker) drawProgress() {
+	if b.progressVisible() {
+		fmt.Fprintf(os.Stderr, "%s%s%s", ColorBlue, b.progress, ColorReset)
+	}
+}
+
+func (b *outputBroker) clearProgress() {
+	if b.progressVisible() {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
+
+func Print(w io.Writer, color, text string) {
+	output().send(outputEvent{kind: outputText, w: w, color: color, text: text})
+}
+
+func Printf(w io.Writer, color, format string, args ...interface{}) {
+	Print(w, color, fmt.Sprintf(format, args...))
+}
+
+func SetProgress(text string) {
+	output().send(outputEvent{kind: outputProgress, text: text})
+}
+
+func ClearProgress() {
+	output().send(outputEvent{kind: outputProgressClear})
+}
+
+func FinishOutput() {
+	output().send(outputEvent{kind: outputFinish})
+}
+
+func RedirectStdout(w io.Writer) {
+	output().send(outputEvent{kind: outputRedirect, w: w})
+}
+
+// answered records that the user ended a prompt written to w by pressing
+// Enter, so the cursor is at the start of a line again.
+func answered(w io.Writer) {
+	output().send(outputEvent{kind: outputAnswered, w: w})
+}
@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func Pick(title string, options []string, defaultIdx int, timeout time.Duration) int {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return defaultIdx
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(os.Stderr, "%s%s%s\n", ColorBlue, title, ColorReset)
+	for i, opt := range options {
+		marker := " "
+		if i == defaultIdx {
+			marker = "*"
+		}
+		fmt.Fprintf(os.Stderr, " %s %d) %s\n", marker, i+1, opt)
+	}
+	fmt.Fprintf(os.Stderr, "Choose 1-%d (default %d in %ds): ", len(options), defaultIdx+1, int(timeout.Seconds()))
+
+	answers := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(tty).ReadString('\n')
+		answers <- strings.TrimSpace(line)
+	}()
+
+	select {
+	case answer := <-answers:
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return n - 1
+		}
+	case <-time.After(timeout):
+		fmt.Fprintln(os.Stderr)
+	}
+	return defaultIdx
+}
+
+func Confirm(question string) bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	Print(os.Stderr, "", question)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	answered(os.Stderr)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -1,11 +1,18 @@
 package ui
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io"
+	"mime"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 var (
@@ -16,7 +23,7 @@ var (
 )
 
 func init() {
-	if !IsStdoutTTY() {
+	if !IsStdoutTTY() || !enableVirtualTerminal() {
 		ColorRed, ColorGreen, ColorBlue, ColorReset = "", "", "", ""
 	}
 }
@@ -30,41 +37,229 @@ func IsStdoutTTY() bool {
 }
 
 func IsStdinPiped() bool {
-	stat, _ := os.Stdin.Stat()
-	return (stat.Mode() & os.ModeCharDevice) == 0
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	mode := stat.Mode()
+	return mode&os.ModeCharDevice == 0 && (mode&(os.ModeNamedPipe|os.ModeSocket) != 0 || mode.IsRegular())
 }
 
-func GatherInput(args []string, useEditor bool, editorCmd string) (string, error) {
+const stdinHintDelay = 3 * time.Second
+
+type stdinHintReader struct {
+	r     io.Reader
+	timer *time.Timer
+}
+
+func (h *stdinHintReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.timer.Stop()
+	return n, err
+}
+
+func ReadStdin() ([]byte, error) {
+	timer := time.AfterFunc(stdinHintDelay, func() {
+		Printf(os.Stderr, ColorBlue, "Waiting for input on stdin (finish with Ctrl+D). "+
+			"To run without it, pass the prompt as an argument and add </dev/null, or use -e or -i.\n")
+	})
+	defer timer.Stop()
+	return io.ReadAll(&stdinHintReader{r: os.Stdin, timer: timer})
+}
+
+type InputOptions struct {
+	UseEditor    bool
+	EditorCmd    string
+	DecodeBase64 bool
+	DetectBase64 bool
+	PromptURL    string
+	BinaryStdin  bool
+}
+
+const (
+	maxPromptURLBytes = 1 << 20
+	promptURLTimeout  = 15 * time.Second
+)
+
+func GatherInput(args []string, opts InputOptions) (string, error) {
 	var initialContent string
 	if len(args) > 0 {
-		initialContent = strings.Join(args, " ")
+		normalized := make([]string, len(args))
+		for i, arg := range args {
+			normalized[i] = NormalizeInput(arg)
+		}
+		initialContent = strings.Join(normalized, " ")
+	}
+
+	if opts.PromptURL != "" {
+		remote, err := FetchPrompt(opts.PromptURL)
+		if err != nil {
+			return "", err
+		}
+		initialContent = joinInput(NormalizeInput(remote), "\n\n", initialContent)
 	}
 
-	if IsStdinPiped() {
-		stdinBytes, err := io.ReadAll(os.Stdin)
+	if IsStdinPiped() && !opts.BinaryStdin {
+		stdinBytes, err := ReadStdin()
 		if err != nil {
 			return "", err
 		}
-		if initialContent != "" {
-			initialContent = fmt.Sprintf("%s\n\n---\n%s", initialContent, string(stdinBytes))
-		} else {
-			initialContent = string(stdinBytes)
+		stdinText, err := decodeStdin(NormalizeInput(string(stdinBytes)), opts.DecodeBase64, opts.DetectBase64)
+		if err != nil {
+			return "", err
 		}
+		initialContent = joinInput(initialContent, "\n\n---\n", NormalizeInput(stdinText))
 	}
 
-	if useEditor {
-		return OpenEditor(editorCmd, initialContent)
+	if opts.UseEditor {
+		return OpenEditor(opts.EditorCmd, initialContent)
 	}
 
 	return initialContent, nil
 }
 
+func NormalizeInput(text string) string {
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	lines := strings.Split(text, "\n")
+	end := len(lines)
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return strings.Join(lines[:end], "\n")
+}
+
+func joinInput(first, separator, second string) string {
+	first = strings.TrimRight(first, "\n")
+	second = strings.TrimLeft(second, "\n")
+	switch {
+	case first == "":
+		return second
+	case second == "":
+		return first
+	}
+	return first + separator + second
+}
+
+func FetchPrompt(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid --prompt-url %q (expected an http or https URL)", rawURL)
+	}
+
+	client := &http.Client{Timeout: promptURLTimeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch prompt from %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch prompt from %s: %s", rawURL, resp.Status)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || !isTextMediaType(mediaType) {
+			return "", fmt.Errorf("prompt URL %s returned non-text content (%s)", rawURL, ct)
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPromptURLBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt from %s: %w", rawURL, err)
+	}
+	if len(body) > maxPromptURLBytes {
+		return "", fmt.Errorf("prompt at %s is larger than %d KB", rawURL, maxPromptURLBytes/1024)
+	}
+	if !utf8.Valid(body) {
+		return "", fmt.Errorf("prompt URL %s returned non-text content", rawURL)
+	}
+
+	prompt := strings.TrimSpace(string(body))
+	if prompt == "" {
+		return "", fmt.Errorf("prompt URL %s returned an empty body", rawURL)
+	}
+	return prompt, nil
+}
+
+func isTextMediaType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/yaml", "application/x-yaml", "application/xml", "application/markdown":
+		return true
+	}
+	return false
+}
+
+func decodeStdin(content string, force, detect bool) (string, error) {
+	if !force && !detect {
+		return content, nil
+	}
+	decoded, ok := DecodeBase64Text(content)
+	if force {
+		if !ok {
+			return "", fmt.Errorf("stdin is not valid base64-encoded text")
+		}
+		return decoded, nil
+	}
+	if ok {
+		Printf(os.Stderr, ColorBlue, "[Detected base64 input on stdin, decoded %d bytes]\n", len(decoded))
+		return decoded, nil
+	}
+	return content, nil
+}
+
+func DecodeBase64Text(content string) (string, bool) {
+	compact := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(content))
+
+	if len(compact) < 16 || len(compact)%4 != 0 {
+		return "", false
+	}
+
+	var decoded []byte
+	var err error
+	if strings.ContainsAny(compact, "-_") {
+		decoded, err = base64.URLEncoding.DecodeString(compact)
+	} else {
+		decoded, err = base64.StdEncoding.DecodeString(compact)
+	}
+	if err != nil || !utf8.Valid(decoded) {
+		return "", false
+	}
+
+	for _, r := range string(decoded) {
+		if r < 32 && r != '\n' && r != '\r' && r != '\t' {
+			return "", false
+		}
+	}
+	return string(decoded), true
+}
+
 func OpenEditor(editor string, content string) (string, error) {
+	text, path, err := EditFile(editor, content)
+	if path != "" && err == nil {
+		os.Remove(path)
+	}
+	return text, err
+}
+
+// EditFile opens the editor on a temporary file holding content and returns
+// the edited text along with the file's path. The file is left in place so
+// the caller can remove it once the text is no longer needed.
+func EditFile(editor string, content string) (string, string, error) {
 	tmpFile, err := os.CreateTemp("", "ai-prompt-*.md")
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	defer os.Remove(tmpFile.Name())
 
 	if content != "" {
 		tmpFile.WriteString(content)
@@ -77,24 +272,85 @@ func OpenEditor(editor string, content string) (string, error) {
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("failed to run editor %q: %w", editor, err)
+		return "", tmpFile.Name(), fmt.Errorf("failed to run editor %q (the text is kept in %s): %w", editor, tmpFile.Name(), err)
 	}
 
 	finalBytes, err := os.ReadFile(tmpFile.Name())
 	if err != nil {
+		return "", tmpFile.Name(), err
+	}
+	return NormalizeInput(string(finalBytes)), tmpFile.Name(), nil
+}
+
+func DataDir() string {
+	if dataDir := os.Getenv("XDG_DATA_HOME"); dataDir != "" {
+		return dataDir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "share")
+}
+
+func LastPromptPath() string {
+	return filepath.Join(DataDir(), "ai", "last-prompt.md")
+}
+
+func SaveLastPrompt(prompt string) (string, error) {
+	path := LastPromptPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(prompt), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func LoadLastPrompt() (string, error) {
+	data, err := os.ReadFile(LastPromptPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no saved prompt found at %s", LastPromptPath())
+		}
 		return "", err
 	}
-	return string(finalBytes), nil
+	return string(data), nil
 }
 
 func PrintUserPrompt(prompt string) {
-	fmt.Printf("%s> %s%s\n", ColorBlue, prompt, ColorReset)
+	Printf(os.Stdout, ColorBlue, "> %s\n", prompt)
+}
+
+var (
+	assistantLabel string
+	agentLineStart = true
+)
+
+func SetAssistantName(name string) {
+	assistantLabel = ""
+	if name != "" {
+		assistantLabel = "[" + name + "] "
+	}
 }
 
 func PrintAgentMessage(msg string) {
-	fmt.Printf("%s%s%s", ColorGreen, msg, ColorReset)
+	if assistantLabel == "" {
+		Print(os.Stdout, ColorGreen, msg)
+		return
+	}
+
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(msg, "\n") {
+		if line == "" {
+			continue
+		}
+		if agentLineStart {
+			sb.WriteString(assistantLabel)
+		}
+		sb.WriteString(line)
+		agentLineStart = strings.HasSuffix(line, "\n")
+	}
+	Print(os.Stdout, ColorGreen, sb.String())
 }
 
 func PrintToolUse(toolName string, args string) {
-	fmt.Printf("%s[Agent using tool: %s (%s)]%s\n", ColorRed, toolName, args, ColorReset)
+	Printf(os.Stdout, ColorRed, "[Agent using tool: %s (%s)]\n", toolName, args)
 }
@@ -0,0 +1,144 @@
+package ui
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func withStdin(t *testing.T, content *string) {
+	t.Helper()
+	saved := os.Stdin
+	t.Cleanup(func() { os.Stdin = saved })
+
+	if content == nil {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		os.Stdin = f
+		return
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	go func() {
+		w.WriteString(*content)
+		w.Close()
+	}()
+	os.Stdin = r
+}
+
+func TestGatherInputMatrix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake editor is a shell script")
+	}
+
+	const body = "line one\n\n  indented\nline three"
+	const want = body
+	endings := map[string]func(string) string{
+		"lf":       func(s string) string { return s + "\n \n\n" },
+		"crlf":     func(s string) string { return strings.ReplaceAll(s, "\n", "\r\n") + "\r\n\r\n" },
+		"bom+crlf": func(s string) string { return "\ufeff" + strings.ReplaceAll(s, "\n", "\r\n") + "\r\n" },
+	}
+
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+	if err := os.WriteFile(editor, []byte("#!/bin/sh\nprintf '\\r\\nedited\\r\\n\\r\\n' >> \"$1\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ending := range endings {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(ending("from the url")))
+		}))
+		defer server.Close()
+
+		piped := ending(body)
+		tests := []struct {
+			source string
+			args   []string
+			stdin  *string
+			opts   InputOptions
+			want   string
+		}{
+			{"args", []string{"explain", ending("this")}, nil, InputOptions{}, "explain this"},
+			{"stdin", nil, &piped, InputOptions{}, want},
+			{"args+stdin", []string{ending("explain")}, &piped, InputOptions{}, "explain\n\n---\n" + want},
+			{"url+args", []string{"explain"}, nil, InputOptions{PromptURL: server.URL}, "from the url\n\nexplain"},
+			{"url+args+stdin", []string{"explain"}, &piped, InputOptions{PromptURL: server.URL}, "from the url\n\nexplain\n\n---\n" + want},
+			{"args+stdin+editor", []string{"explain"}, &piped, InputOptions{UseEditor: true, EditorCmd: editor}, "explain\n\n---\n" + want + "\nedited"},
+			{"editor", nil, nil, InputOptions{UseEditor: true, EditorCmd: editor}, "\nedited"},
+		}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.source, func(t *testing.T) {
+				withStdin(t, tt.stdin)
+				got, err := GatherInput(tt.args, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestNormalizeInput(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"\ufeff", ""},
+		{"a\r\nb", "a\nb"},
+		{"a\n\n\nb", "a\n\n\nb"},
+		{"  indented\n\tcode  \n", "  indented\n\tcode  "},
+		{"a\r\n  \r\n\t\r\n", "a"},
+		{"a\rb", "a\rb"},
+		{"x\ufeffy", "x\ufeffy"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeInput(tt.in); got != tt.want {
+			t.Errorf("NormalizeInput(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeStdin(t *testing.T) {
+	const plain = "hello, base64 world"
+	encoded := base64.StdEncoding.EncodeToString([]byte(plain))
+	tests := []struct {
+		name          string
+		in            string
+		force, detect bool
+		want          string
+		wantErr       bool
+	}{
+		{"detected", encoded, false, true, plain, false},
+		{"detection off", encoded, false, false, encoded, false},
+		{"forced with detection off", encoded, true, false, plain, false},
+		{"plain text", plain, false, true, plain, false},
+		{"forced plain text", plain, true, true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeStdin(tt.in, tt.force, tt.detect)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,27 +8,59 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"regexp"
 	"runtime"
-	"time"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gordonklaus/portaudio"
 	openai "github.com/sashabaranov/go-openai"
 )
 
+const DefaultVoice = openai.VoiceAlloy
+
+var Voices = []openai.SpeechVoice{
+	openai.VoiceAlloy,
+	openai.VoiceAsh,
+	openai.VoiceBallad,
+	openai.VoiceCoral,
+	openai.VoiceEcho,
+	openai.VoiceFable,
+	openai.VoiceNova,
+	openai.VoiceOnyx,
+	openai.VoiceShimmer,
+	openai.VoiceVerse,
+}
+
+const (
+	maxSpeechInput  = 4000
+	speechLookahead = 2
+)
+
 type Manager struct {
-	client *openai.Client
+	client      *openai.Client
+	speechModel openai.SpeechModel
+	Player      string
+	Out         string
+	Progress    func(segment, total int)
 }
 
-func NewManager(apiKey string) (*Manager, error) {
-	if apiKey == "" {
-		return nil, fmt.Errorf("API key required for voice")
+func NewManager(apiKey, baseURL, model string) (*Manager, error) {
+	clientConfig := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		clientConfig.BaseURL = baseURL
+	}
+	speechModel := openai.TTSModel1
+	if model != "" {
+		speechModel = openai.SpeechModel(model)
 	}
 	if err := portaudio.Initialize(); err != nil {
 		return nil, fmt.Errorf("portaudio init error: %w", err)
 	}
 	return &Manager{
-		client: openai.NewClient(apiKey),
+		client:      openai.NewClientWithConfig(clientConfig),
+		speechModel: speechModel,
 	}, nil
 }
 
@@ -87,34 +119,197 @@ func (m *Manager) Transcribe(ctx context.Context, wavData []byte) (string, error
 }
 
 func (m *Manager) Speak(ctx context.Context, text string) error {
-	req := openai.CreateSpeechRequest{
-		Model:          openai.TTSModel1,
-		Input:          text,
-		Voice:          openai.VoiceAlloy,
-		ResponseFormat: openai.SpeechResponseFormatMp3,
+	segments := SplitSpeech(text, maxSpeechInput)
+	if len(segments) == 0 {
+		return nil
 	}
 
-	resp, err := m.client.CreateSpeech(ctx, req)
-	if err != nil {
-		return err
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type synthesized struct {
+		path string
+		err  error
+	}
+	ready := make(chan synthesized, speechLookahead)
+	go func() {
+		defer close(ready)
+		for i, segment := range segments {
+			if m.Progress != nil {
+				m.Progress(i+1, len(segments))
+			}
+			path, err := m.synthesize(ctx, segment)
+			select {
+			case ready <- synthesized{path, err}:
+			case <-ctx.Done():
+				if path != "" {
+					os.Remove(path)
+				}
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var files []string
+	defer func() {
+		cancel()
+		for s := range ready {
+			if s.path != "" {
+				os.Remove(s.path)
+			}
+		}
+		for _, f := range files {
+			os.Remove(f)
+		}
+	}()
+
+	for s := range ready {
+		if s.err != nil {
+			return s.err
+		}
+		files = append(files, s.path)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := m.playAudioFile(ctx, s.path); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
 	}
-	defer resp.Close()
 
-	tmpDir := os.TempDir()
-	tmpFile := filepath.Join(tmpDir, fmt.Sprintf("ai_speech_%d.mp3", time.Now().UnixNano()))
+	if m.Out != "" {
+		return concatFiles(m.Out, files)
+	}
+	return nil
+}
 
-	f, err := os.Create(tmpFile)
+func (m *Manager) synthesize(ctx context.Context, text string) (string, error) {
+	resp, err := m.client.CreateSpeech(ctx, openai.CreateSpeechRequest{
+		Model:          m.speechModel,
+		Input:          text,
+		Voice:          DefaultVoice,
+		ResponseFormat: openai.SpeechResponseFormatMp3,
+	})
 	if err != nil {
-		return err
+		return "", err
 	}
+	defer resp.Close()
 
+	f, err := os.CreateTemp("", "ai_speech_*.mp3")
+	if err != nil {
+		return "", err
+	}
 	if _, err := io.Copy(f, resp); err != nil {
 		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func concatFiles(path string, files []string) error {
+	out, err := os.Create(path)
+	if err != nil {
 		return err
 	}
-	f.Close()
+	for _, name := range files {
+		in, err := os.Open(name)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		_, err = io.Copy(out, in)
+		in.Close()
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
 
-	return playAudioFile(tmpFile)
+var sentenceEndRegex = regexp.MustCompile(`[.!?…]+["')\]]*\s+|\n\s*\n`)
+
+func SplitSpeech(text string, limit int) []string {
+	var sentences []string
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		loc := sentenceEndRegex.FindStringIndex(rest)
+		if loc == nil {
+			sentences = append(sentences, rest)
+			break
+		}
+		sentences = append(sentences, strings.TrimSpace(rest[:loc[1]]))
+		rest = rest[loc[1]:]
+	}
+
+	var segments []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			segments = append(segments, current.String())
+			current.Reset()
+		}
+	}
+	for _, sentence := range sentences {
+		for _, piece := range splitLong(sentence, limit) {
+			if current.Len() > 0 && utf8.RuneCountInString(current.String())+1+utf8.RuneCountInString(piece) > limit {
+				flush()
+			}
+			if current.Len() > 0 {
+				current.WriteString(" ")
+			}
+			current.WriteString(piece)
+		}
+	}
+	flush()
+	return segments
+}
+
+func splitLong(text string, limit int) []string {
+	var pieces []string
+	for utf8.RuneCountInString(text) > limit {
+		runes := []rune(text)
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+		pieces = append(pieces, strings.TrimSpace(string(runes[:cut])))
+		text = strings.TrimSpace(string(runes[cut:]))
+	}
+	if text != "" {
+		pieces = append(pieces, text)
+	}
+	return pieces
+}
+
+var (
+	codeBlockRegex  = regexp.MustCompile("(?s)```.*?(```|$)")
+	inlineCodeRegex = regexp.MustCompile("`[^`\n]*`")
+	urlRegex        = regexp.MustCompile(`https?://\S+`)
+	markupRegex     = regexp.MustCompile(`[*_#>|]+`)
+	spacesRegex     = regexp.MustCompile(`[ \t]{2,}`)
+)
+
+func SpeakableText(text string) string {
+	text = codeBlockRegex.ReplaceAllString(text, " ")
+	text = inlineCodeRegex.ReplaceAllString(text, " ")
+	text = urlRegex.ReplaceAllString(text, " ")
+	text = markupRegex.ReplaceAllString(text, " ")
+	text = spacesRegex.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
 }
 
 func encodeWAV(data []int16, sampleRate int) []byte {
@@ -143,24 +338,111 @@ func encodeWAV(data []int16, sampleRate int) []byte {
 	return buf.Bytes()
 }
 
-func playAudioFile(path string) error {
+func PlayerCandidates() []string {
+	var templates []string
+	switch runtime.GOOS {
+	case "darwin":
+		templates = []string{"afplay {file}", "mpv --no-video {file}", "ffplay -nodisp -autoexit {file}"}
+	case "linux":
+		templates = []string{"mpg123 {file}", "ffplay -nodisp -autoexit {file}", "aplay {file}", "mpv --no-video {file}"}
+	default:
+		return nil
+	}
+
+	var found []string
+	for _, t := range templates {
+		if _, err := exec.LookPath(strings.Fields(t)[0]); err == nil {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+func PlayerCommand(template, path string) ([]string, error) {
+	args, err := splitCommand(template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid player command %q: %w", template, err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("player command is empty")
+	}
+
+	substituted := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{file}") {
+			args[i] = strings.ReplaceAll(arg, "{file}", path)
+			substituted = true
+		}
+	}
+	if !substituted {
+		args = append(args, path)
+	}
+	return args, nil
+}
+
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+func (m *Manager) playAudioFile(ctx context.Context, path string) error {
+	if m.Player != "" {
+		args, err := PlayerCommand(m.Player, path)
+		if err != nil {
+			return err
+		}
+		return exec.CommandContext(ctx, args[0], args[1:]...).Run()
+	}
+
 	var cmd *exec.Cmd
 
 	switch runtime.GOOS {
 	case "darwin":
-		cmd = exec.Command("afplay", path)
+		cmd = exec.CommandContext(ctx, "afplay", path)
 	case "linux":
 		if _, err := exec.LookPath("mpg123"); err == nil {
-			cmd = exec.Command("mpg123", path)
+			cmd = exec.CommandContext(ctx, "mpg123", path)
 		} else if _, err := exec.LookPath("ffplay"); err == nil {
-			cmd = exec.Command("ffplay", "-nodisp", "-autoexit", path)
+			cmd = exec.CommandContext(ctx, "ffplay", "-nodisp", "-autoexit", path)
 		} else if _, err := exec.LookPath("aplay"); err == nil {
-			cmd = exec.Command("aplay", path)
+			cmd = exec.CommandContext(ctx, "aplay", path)
 		} else {
 			return fmt.Errorf("no audio player found (install mpg123 or ffmpeg)")
 		}
 	case "windows":
-		cmd = exec.Command("powershell", "-c", fmt.Sprintf("(New-Object Media.SoundPlayer '%s').PlaySync();", path))
+		cmd = exec.CommandContext(ctx, "powershell", "-c", fmt.Sprintf("(New-Object Media.SoundPlayer '%s').PlaySync();", path))
 	default:
 		return fmt.Errorf("unsupported OS for playback")
 	}